@@ -0,0 +1,222 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/challenge"
+	"wait-to-go/phone"
+)
+
+// Blocklist kinds
+const (
+	BlockPhone = "phone"
+	BlockIP    = "ip"
+)
+
+// VerificationHeader carries the proof-of-work solution or CAPTCHA token on /join
+const VerificationHeader = "X-Verification-Token"
+
+type Blocked struct {
+	ID        int       `json:"id"`
+	Kind      string    `json:"kind"`
+	Value     string    `json:"value"`
+	Reason    string    `json:"reason"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// JoinGuard protects the public /join endpoint
+type JoinGuard struct {
+	ipLimiter    *auth.RateLimiter
+	phoneLimiter *auth.RateLimiter
+	// verifier is nil when no human verification is required
+	verifier challenge.Verifier
+	// pow is set when verifier is a proof-of-work, so challenges can be issued
+	pow *challenge.ProofOfWork
+}
+
+func NewJoinGuard(window time.Duration, perIP, perPhone int, verifier challenge.Verifier) *JoinGuard {
+	guard := &JoinGuard{
+		ipLimiter:    auth.NewRateLimiter(window, perIP),
+		phoneLimiter: auth.NewRateLimiter(window, perPhone),
+		verifier:     verifier,
+	}
+	guard.pow, _ = verifier.(*challenge.ProofOfWork)
+	return guard
+}
+
+// checkJoinSource runs the checks that only need the request: IP blocklist,
+// per-IP rate limit and human verification. It writes the error response and
+// returns false when the join must be refused.
+func (a *App) checkJoinSource(w http.ResponseWriter, r *http.Request) bool {
+	ip := auth.ClientIP(r)
+
+	blocked, err := isBlocked(a.db, BlockIP, ip)
+	if err != nil {
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return false
+	}
+	if blocked {
+		apierror.ErrorWithCode(w, r, apierror.CodeBlocked, "Joining is not allowed from this network", http.StatusForbidden)
+		return false
+	}
+
+	if !a.joinGuard.ipLimiter.Allow(ip) {
+		apierror.Error(w, r, "Too many join attempts, please try again later", http.StatusTooManyRequests)
+		return false
+	}
+
+	if a.joinGuard.verifier != nil {
+		if err := a.joinGuard.verifier.Verify(r.Header.Get(VerificationHeader), ip); err != nil {
+			writeVerificationError(w, r, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// writeVerificationError explains a failed verification without passing on
+// what the CAPTCHA provider said, which is only logged
+func writeVerificationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, challenge.ErrMissing):
+		apierror.ErrorWithCode(w, r, apierror.CodeVerificationFailed, "Verification is required to join", http.StatusForbidden)
+	case errors.Is(err, challenge.ErrExpired):
+		apierror.ErrorWithCode(w, r, apierror.CodeVerificationFailed, "Verification has expired, please try again", http.StatusForbidden)
+	case errors.Is(err, challenge.ErrInvalid), errors.Is(err, challenge.ErrReplayed):
+		apierror.ErrorWithCode(w, r, apierror.CodeVerificationFailed, "Verification failed, please try again", http.StatusForbidden)
+	case errors.Is(err, challenge.ErrUnavailable):
+		log.Printf("Warning: join verification unavailable: %v", err)
+		apierror.Error(w, r, "Verification is unavailable, please try again later", http.StatusServiceUnavailable)
+	default:
+		log.Printf("Warning: join verification failed: %v", err)
+		apierror.ErrorWithCode(w, r, apierror.CodeVerificationFailed, "Verification failed, please try again", http.StatusForbidden)
+	}
+}
+
+// checkJoinPhone applies the phone blocklist and per-phone rate limit to a normalized number
+func (a *App) checkJoinPhone(w http.ResponseWriter, r *http.Request, phoneNumber string) bool {
+	blocked, err := isBlocked(a.db, BlockPhone, phoneNumber)
+	if err != nil {
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return false
+	}
+	if blocked {
+		apierror.ErrorWithCode(w, r, apierror.CodeBlocked, "This phone number cannot join the queue", http.StatusForbidden)
+		return false
+	}
+
+	if !a.joinGuard.phoneLimiter.Allow(phoneNumber) {
+		apierror.Error(w, r, "Too many join attempts for this phone number, please try again later", http.StatusTooManyRequests)
+		return false
+	}
+
+	return true
+}
+
+// handleJoinChallenge issues a proof-of-work challenge for /join
+func (a *App) handleJoinChallenge(w http.ResponseWriter, r *http.Request) {
+	if a.joinGuard.pow == nil {
+		apierror.Error(w, r, "Proof-of-work verification is not enabled", http.StatusNotFound)
+		return
+	}
+
+	c, err := a.joinGuard.pow.NewChallenge()
+	if err != nil {
+		apierror.Error(w, r, "Failed to create challenge", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"challenge":  c,
+		"difficulty": a.joinGuard.pow.Difficulty,
+		"expiresIn":  int(a.joinGuard.pow.TTL.Seconds()),
+	})
+}
+
+func (a *App) handleListBlocklist(w http.ResponseWriter, r *http.Request) {
+	blocklist, err := getBlocklist(a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get blocklist", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blocklist)
+}
+
+// handleAddBlocked blocks a phone number or IP: {"kind", "value", "reason"}
+func (a *App) handleAddBlocked(w http.ResponseWriter, r *http.Request) {
+	var blocked Blocked
+	if err := json.NewDecoder(r.Body).Decode(&blocked); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	switch blocked.Kind {
+	case BlockPhone:
+		normalized, err := phone.Normalize(blocked.Value, a.phoneRegion)
+		if err != nil {
+			apierror.ValidationError(w, r, map[string]string{"value": "Phone number is not a valid number"})
+			return
+		}
+		blocked.Value = normalized
+	case BlockIP:
+		ip := net.ParseIP(blocked.Value)
+		if ip == nil {
+			apierror.ValidationError(w, r, map[string]string{"value": "IP address is not valid"})
+			return
+		}
+		blocked.Value = ip.String()
+	default:
+		apierror.ValidationError(w, r, map[string]string{"kind": "Kind must be phone or ip"})
+		return
+	}
+	if len(blocked.Reason) > 200 {
+		apierror.ValidationError(w, r, map[string]string{"reason": "Reason must be at most 200 characters"})
+		return
+	}
+
+	blocked.CreatedAt = time.Now()
+	id, err := insertBlocked(a.db, blocked)
+	if err != nil {
+		apierror.Error(w, r, "Failed to update blocklist", http.StatusInternalServerError)
+		return
+	}
+	blocked.ID = id
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(blocked)
+}
+
+// handleDeleteBlocked removes a value from the blocklist
+func (a *App) handleDeleteBlocked(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		apierror.Error(w, r, "Invalid ID format", http.StatusBadRequest)
+		return
+	}
+
+	if err := deleteBlocked(a.db, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Blocklist entry not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to update blocklist", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
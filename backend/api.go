@@ -3,62 +3,140 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"wait-to-go/apierror"
 	"wait-to-go/auth"
+	"wait-to-go/queueing"
 )
 
-// CORS middleware
-func enableCors(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
-		w.Header().Set("Access-Control-Expose-Headers", "Authorization")
-
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next(w, r)
-	}
-}
-
 func (a *App) handleJoin(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	if !a.checkJoinSource(w, r) || !a.checkPoster(w, r) {
 		return
 	}
 
-	var entry Entry
-	err := json.NewDecoder(r.Body).Decode(&entry)
+	var req JoinRequest
+	err := json.NewDecoder(r.Body).Decode(&req)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
+	entry := req.entry()
 	entry.Status = StatusWaiting
 	entry.JoinTime = time.Now()
 
-	//validate we have a name and phone number
-	if (entry.FirstName == "") || (len(entry.FirstName) > 30) || (entry.LastName == "") || (len(entry.LastName) > 30) || (entry.PhoneNumber == "") {
-		http.Error(w, "Invalid name fields or missing phone number", http.StatusBadRequest)
+	//validate we have a name and a valid phone number
+	fields := queueing.ValidateEntry(&entry, a.phoneRegion)
+	if msg := validateServiceType(&entry, a.serviceTypes); msg != "" {
+		fields["serviceType"] = msg
+	}
+	if entry.PartySize == 0 {
+		entry.PartySize = 1
+	}
+	if msg := queueing.ValidatePartySize(entry.PartySize, a.party.MaxSize); msg != "" {
+		fields["partySize"] = msg
+	}
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	if !a.checkJoinPhone(w, r, entry.PhoneNumber) {
 		return
 	}
 
-	id, err := addEntry(entry, a.queue, a.db)
+	existing, err := getActiveEntriesByPhone(a.db, entry.PhoneNumber)
 	if err != nil {
-		http.Error(w, "Failed to add entry", http.StatusInternalServerError)
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) > 0 && !a.duplicatePolicy.Allows(len(existing)) {
+		// The oldest active entry is the one holding the customer's best spot
+		a.handleDuplicateJoin(w, r, existing[0], false)
+		return
+	}
+
+	if a.otp.VerifyPhone {
+		// handleJoinVerify checks the queue again before admitting them
+		if !a.checkRoom(w, r) {
+			return
+		}
+		a.startJoinVerification(w, r, entry)
+		return
+	}
+
+	if entry, ok := a.admit(w, r, entry); ok {
+		a.writeJoined(w, r, entry)
+	}
+}
+
+// checkRoom checks that the queue is open and can take one more customer,
+// directly or on the waitlist
+func (a *App) checkRoom(w http.ResponseWriter, r *http.Request) bool {
+	a.queueMu.Lock()
+	defer a.queueMu.Unlock()
+
+	if !a.checkQueueOpen(w, r) {
+		return false
+	}
+	_, ok := a.checkCapacity(w, r)
+	return ok
+}
+
+// admit adds a new or verified pending customer to the queue, or to the
+// waitlist when it is full, and returns the stored entry. Everything that
+// waits on the network runs before this: queueMu is held only from the
+// capacity check to the insert. It writes the error response and returns
+// false otherwise.
+func (a *App) admit(w http.ResponseWriter, r *http.Request, entry Entry) (Entry, bool) {
+	a.queueMu.Lock()
+	defer a.queueMu.Unlock()
+
+	if !a.checkQueueOpen(w, r) {
+		return Entry{}, false
+	}
+	waitlist, ok := a.checkCapacity(w, r)
+	if !ok {
+		return Entry{}, false
+	}
+
+	var err error
+	switch {
+	case waitlist && entry.Status == StatusPending:
+		err = waitlistEntry(&entry, a.db)
+	case waitlist:
+		entry.Status = StatusWaitlisted
+		entry, err = addWaitlistedEntry(entry, a.db)
+	case entry.Status == StatusPending:
+		err = activateEntry(&entry, a.queue, a.db)
+	default:
+		entry.ID, err = addEntry(entry, a.queue, a.db)
+	}
+	if err != nil {
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// writeJoined answers a successful join with the customer's token
+func (a *App) writeJoined(w http.ResponseWriter, r *http.Request, entry Entry) {
+	if entry.Status == StatusWaitlisted {
+		a.writeWaitlisted(w, r, entry)
 		return
 	}
 
 	// Generate JWT token
-	token, err := auth.GenerateToken(id, entry.PhoneNumber)
+	token, err := auth.GenerateToken(entry.ID, entry.PhoneNumber)
 	if err != nil {
-		http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+		apierror.Error(w, r, "Failed to generate token", http.StatusInternalServerError)
 		return
 	}
 
@@ -66,20 +144,16 @@ func (a *App) handleJoin(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status": "success",
-		"id":     id,
+		"id":     entry.ID,
 		"token":  token,
 	})
 }
 
+// handleQueue returns every waiting entry for the deprecated GET /queue
 func (a *App) handleQueue(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	entries, err := getWaitingEntry(a.db)
 	if err != nil {
-		http.Error(w, "Failed to get queue", http.StatusInternalServerError)
+		apierror.Error(w, r, "Failed to get queue", http.StatusInternalServerError)
 		return
 	}
 
@@ -87,119 +161,426 @@ func (a *App) handleQueue(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(entries)
 }
 
+// handleNext calls the customer at the head of the queue to the caller's
+// counter. An optional {"seats": 4} calls the next party of at most 4 people;
+// it defaults to the counter's seats.
 func (a *App) handleNext(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	var req struct {
+		Seats *int `json:"seats"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Seats != nil && *req.Seats < 0 {
+		apierror.ValidationError(w, r, map[string]string{"seats": "Seats must not be negative"})
+		return
+	}
+
+	counter, err := a.callerCounter(r)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get counter", http.StatusInternalServerError)
 		return
 	}
 
-	err := notifyNext(a.queue, a.history, a.db)
+	rules := queueing.CallRules{SkipLimit: a.party.SkipLimit, ArrivedOnly: a.arrivedOnly}
+	if req.Seats != nil {
+		rules.Seats = *req.Seats
+	} else if counter != nil {
+		rules.Seats = counter.Seats
+	}
+
+	notified, err := notifyNext(counter, rules, a.queue, a.history, a.db)
 	if err != nil {
-		http.Error(w, "Failed to notify next", http.StatusInternalServerError)
+		var passedOver *queueing.PassedOverError
+		switch {
+		case errors.Is(err, ErrQueueEmpty):
+			apierror.ErrorWithCode(w, r, apierror.CodeQueueEmpty, "The queue is empty", http.StatusConflict)
+		case errors.Is(err, queueing.ErrNoEligibleEntry):
+			apierror.ErrorWithCode(w, r, apierror.CodeQueueEmpty, "Nobody waiting needs this counter's services", http.StatusConflict)
+		case errors.Is(err, queueing.ErrNotArrived):
+			apierror.ErrorWithCode(w, r, apierror.CodeNoneArrived, "Nobody waiting has arrived yet", http.StatusConflict)
+		case errors.As(err, &passedOver):
+			apierror.ErrorWithDetails(w, r, apierror.CodeNoPartyFits,
+				fmt.Sprintf("A party of %d has been passed over too often and must be seated first", passedOver.Entry.PartySize),
+				http.StatusConflict,
+				map[string]string{
+					"id":        strconv.Itoa(passedOver.Entry.ID),
+					"partySize": strconv.Itoa(passedOver.Entry.PartySize),
+				})
+		case errors.Is(err, queueing.ErrNoPartyFits):
+			apierror.ErrorWithCode(w, r, apierror.CodeNoPartyFits, fmt.Sprintf("Nobody waiting fits %d seats", rules.Seats), http.StatusConflict)
+		default:
+			writeStatusError(w, r, err, "Failed to notify next")
+		}
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"entry":  notified,
+	})
 }
 
-func (a *App) handleServe(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleNotify calls a specific waiting customer out of turn:
+// POST /api/v1/entries/{id}:notify with an optional {"reason"}
+func (a *App) handleNotify(w http.ResponseWriter, r *http.Request) {
+	entry, ok := a.adminEntry(w, r)
+	if !ok {
 		return
 	}
 
-	var entry Entry
-	err := json.NewDecoder(r.Body).Decode(&entry)
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(req.Reason) > 200 {
+		apierror.ValidationError(w, r, map[string]string{"reason": "Reason must be at most 200 characters"})
+		return
+	}
+
+	if err := checkTransition(entry, StatusNotified); err != nil {
+		writeStatusError(w, r, err, "Failed to notify entry")
+		return
+	}
+
+	counter, err := a.callerCounter(r)
 	if err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		apierror.Error(w, r, "Failed to get counter", http.StatusInternalServerError)
 		return
 	}
 
-	err = markServed(&entry, a.db)
+	position := queuePosition(*a.queue, entry)
+	notified, err := notifyEntry(entry.ID, counter, a.queue, a.history, a.db)
 	if err != nil {
-		http.Error(w, "Failed to mark as served", http.StatusInternalServerError)
+		writeStatusError(w, r, err, "Failed to notify entry")
 		return
 	}
 
+	details := map[string]interface{}{"position": position}
+	if req.Reason != "" {
+		details["reason"] = req.Reason
+	}
+	if err := recordHistory(a.db, notified.ID, HistoryNotified, ActorAdmin, details); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"entry":  notified,
+	})
 }
 
-func (a *App) handleStatus(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "GET" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleMove reorders a waiting entry: POST /api/v1/entries/{id}:move with
+// either an absolute {"position"} or a relative {"offset"}, where a negative
+// offset moves the entry towards the front, and an optional {"reason"}
+func (a *App) handleMove(w http.ResponseWriter, r *http.Request) {
+	entry, ok := a.adminEntry(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Position *int   `json:"position"`
+		Offset   *int   `json:"offset"`
+		Reason   string `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Extract ID from URL path
-	id := r.URL.Path[len("/status/"):]
-	if id == "" {
-		http.Error(w, "ID is required", http.StatusBadRequest)
+	fields := FieldErrors{}
+	switch {
+	case (req.Position == nil) == (req.Offset == nil):
+		fields["position"] = "Give exactly one of position or offset"
+	case req.Position != nil && *req.Position < 1:
+		fields["position"] = "Position must be at least 1"
+	}
+	if len(req.Reason) > 200 {
+		fields["reason"] = "Reason must be at most 200 characters"
+	}
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	if entry.Status != StatusWaiting {
+		apierror.ErrorWithCode(w, r, apierror.CodeConflict, "Only waiting entries can be moved", http.StatusConflict)
 		return
 	}
 
-	entryID, err := strconv.Atoi(id)
+	from := queuePosition(*a.queue, entry)
+	to := from
+	if req.Position != nil {
+		to = *req.Position
+	} else {
+		to += *req.Offset
+	}
+
+	moved, err := moveEntry(entry.ID, max(to, 1), a.queue, a.db)
 	if err != nil {
-		http.Error(w, "Invalid ID format", http.StatusBadRequest)
+		apierror.Error(w, r, "Failed to move entry", http.StatusInternalServerError)
 		return
 	}
+	to = queuePosition(*a.queue, moved)
 
-	// Get claims from context (set by auth middleware)
-	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if to != from {
+		details := map[string]interface{}{"from": from, "to": to}
+		if req.Reason != "" {
+			details["reason"] = req.Reason
+		}
+		if err := recordHistory(a.db, moved.ID, HistoryMoved, ActorAdmin, details); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"entry":    moved,
+		"position": to,
+	})
+}
+
+// handleServe marks a notified entry served: POST /api/v1/entries/{id}:serve,
+// or the legacy POST /serve with the entry ID in the body
+func (a *App) handleServe(w http.ResponseWriter, r *http.Request) {
+	entry, ok := a.adminEntry(w, r)
 	if !ok {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Verify that the token matches the requested entry
-	if claims.ID != entryID {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	counter, err := a.callerCounter(r)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get counter", http.StatusInternalServerError)
+		return
+	}
+
+	if err := markServed(&entry, counter, a.db); err != nil {
+		writeStatusError(w, r, err, "Failed to mark as served")
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"entry":  entry,
+	})
+}
+
+// handleNoShow records that a notified customer did not turn up:
+// POST /api/v1/entries/{id}:noShow
+func (a *App) handleNoShow(w http.ResponseWriter, r *http.Request) {
+	entry, ok := a.adminEntry(w, r)
+	if !ok {
+		return
+	}
+
+	if err := markNoShow(&entry, a.db); err != nil {
+		writeStatusError(w, r, err, "Failed to mark as no-show")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"entry":  entry,
+	})
+}
+
+// adminEntry loads the entry named by the {id} path value or, on legacy
+// routes, by "id" in the JSON body. It writes the error response and returns
+// false when there is no such entry.
+func (a *App) adminEntry(w http.ResponseWriter, r *http.Request) (Entry, bool) {
+	var entryID int
+	if id := r.PathValue("id"); id != "" {
+		var err error
+		if entryID, err = strconv.Atoi(id); err != nil {
+			apierror.Error(w, r, "Invalid ID format", http.StatusBadRequest)
+			return Entry{}, false
+		}
+	} else {
+		var body struct {
+			ID int `json:"id"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return Entry{}, false
+		}
+		entryID = body.ID
+	}
+
 	entry, err := getEntryByID(a.db, entryID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Entry not found", http.StatusNotFound)
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Entry not found", http.StatusNotFound)
 		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
 		}
+		return Entry{}, false
+	}
+	return entry, true
+}
+
+// writeStatusError answers a failed status change: 409 when the state machine
+// refuses the transition, 500 otherwise
+func writeStatusError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	var transition *queueing.TransitionError
+	if errors.As(err, &transition) {
+		apierror.ErrorWithDetails(w, r, apierror.CodeInvalidTransition,
+			fmt.Sprintf("Entry is %s and cannot become %s", transition.From, transition.To), http.StatusConflict,
+			map[string]string{"from": transition.From, "to": transition.To})
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Error(w, r, "Entry not found", http.StatusNotFound)
+		return
+	}
+	log.Printf("Warning: %v", err)
+	apierror.Error(w, r, message, http.StatusInternalServerError)
+}
+
+func (a *App) handleStatus(w http.ResponseWriter, r *http.Request) {
+	entryID, ok := customerEntryID(w, r)
+	if !ok {
 		return
 	}
 
-	// Calculate position in queue
-	position := 0
-	if entry.Status == StatusWaiting {
-		for _, e := range *a.queue {
-			if e.Status == StatusWaiting && e.JoinTime.Before(entry.JoinTime) {
-				position++
-			}
+	entry, err := getEntryByID(a.db, entryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Entry not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
 		}
-		position++ // Add 1 because we want 1-based position
+		return
 	}
 
 	response := struct {
-		Entry    Entry `json:"entry"`
-		Position int   `json:"position"`
+		Entry                Entry `json:"entry"`
+		Position             int   `json:"position"`
+		EstimatedWaitMinutes int   `json:"estimatedWaitMinutes"`
+		// GuestsAhead counts the people in the parties ahead
+		GuestsAhead int `json:"guestsAhead"`
+		// WaitlistPosition is the entry's place on the waitlist while it waits for room in the queue
+		WaitlistPosition int `json:"waitlistPosition,omitempty"`
 	}{
 		Entry:    entry,
-		Position: position,
+		Position: queuePosition(*a.queue, entry),
+	}
+
+	if response.Position > 0 {
+		ahead := entriesAhead(*a.queue, entry)
+		response.EstimatedWaitMinutes = int(queueing.EstimateWait(ahead, a.serviceTime).Minutes())
+		response.GuestsAhead = guests(ahead)
+	}
+
+	if entry.Status == StatusWaitlisted {
+		if response.WaitlistPosition, err = waitlistPosition(a.db, entry); err != nil {
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(response)
 }
 
-func (a *App) handleClear(w http.ResponseWriter, r *http.Request) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// handleUpdateEntry lets a waiting customer correct their own details:
+// PATCH /api/v1/entries/{id} with any of firstName, lastName, email, notes and partySize
+func (a *App) handleUpdateEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, ok := customerEntryID(w, r)
+	if !ok {
+		return
+	}
+
+	var patch EntryPatch
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&patch); err != nil {
+		apierror.Error(w, r, "Invalid request body: only firstName, lastName, email, notes and partySize can be changed", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := getEntryByID(a.db, entryID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Entry not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if entry.Status != StatusWaiting {
+		apierror.Error(w, r, "Entry can only be changed while waiting", http.StatusConflict)
+		return
+	}
+
+	updated, changes := patch.Apply(entry)
+
+	// Same rules as /join
+	fields := queueing.ValidateEntry(&updated, a.phoneRegion)
+	if msg := queueing.ValidatePartySize(updated.PartySize, a.party.MaxSize); msg != "" {
+		fields["partySize"] = msg
+	}
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
 		return
 	}
 
-	err := clearQueueInMemory(a.queue, a.db)
+	if len(changes) > 0 {
+		if err := updateEntry(updated, a.queue, a.db); err != nil {
+			apierror.Error(w, r, "Failed to update entry", http.StatusInternalServerError)
+			return
+		}
+		if err := recordHistory(a.db, updated.ID, HistoryUpdated, ActorCustomer, changes); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"entry":  updated,
+	})
+}
+
+// customerEntryID reads the {id} path value and checks that it belongs to the
+// authenticated customer. It writes the error response and returns false
+// otherwise.
+func customerEntryID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	entryID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		apierror.Error(w, r, "Invalid ID format", http.StatusBadRequest)
+		return 0, false
+	}
+
+	// Get claims from context (set by auth middleware)
+	claims, ok := auth.GetClaimsFromContext(r.Context())
+	if !ok {
+		apierror.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	// Verify that the token matches the requested entry
+	if claims.ID != entryID {
+		apierror.Error(w, r, "Unauthorized", http.StatusUnauthorized)
+		return 0, false
+	}
+
+	return entryID, true
+}
+
+func (a *App) handleClear(w http.ResponseWriter, r *http.Request) {
+	_, err := clearQueueInMemory(a.queue, a.db)
 	if err != nil {
-		http.Error(w, "Failed to clear queue", http.StatusInternalServerError)
+		apierror.Error(w, r, "Failed to clear queue", http.StatusInternalServerError)
 		return
 	}
 
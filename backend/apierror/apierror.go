@@ -0,0 +1,164 @@
+package apierror
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"regexp"
+)
+
+// Code is a stable, machine readable error identifier. Clients should branch
+// on the code rather than the message, which may change.
+type Code string
+
+const (
+	CodeBadRequest         Code = "bad_request"
+	CodeValidation         Code = "validation_failed"
+	CodeUnauthorized       Code = "unauthorized"
+	CodeInvalidToken       Code = "invalid_token"
+	CodeInvalidAPIKey      Code = "invalid_api_key"
+	CodeInvalidSignature   Code = "invalid_signature"
+	CodeForbidden          Code = "forbidden"
+	CodeBlocked            Code = "blocked"
+	CodeVerificationFailed Code = "verification_failed"
+	CodeNotFound           Code = "not_found"
+	CodeMethodNotAllowed   Code = "method_not_allowed"
+	CodeConflict           Code = "conflict"
+	CodeInvalidTransition  Code = "invalid_transition"
+	CodeIdempotencyInUse   Code = "idempotency_key_in_use"
+	CodeIdempotencyReused  Code = "idempotency_key_reused"
+	CodeQueueEmpty         Code = "queue_empty"
+	CodeCounterClaimed     Code = "counter_claimed"
+	CodeQueuePaused        Code = "queue_paused"
+	CodeQueueClosed        Code = "queue_closed"
+	CodeQueueFull          Code = "queue_full"
+	CodeNoPartyFits        Code = "no_party_fits"
+	CodeSlotFull           Code = "slot_full"
+	CodeNoneArrived        Code = "none_arrived"
+	CodeNotOnSite          Code = "not_on_site"
+	CodePosterRequired     Code = "poster_required"
+	CodeDuplicateEntry     Code = "duplicate_entry"
+	CodeInvalidCode        Code = "invalid_code"
+	CodeCodeExpired        Code = "code_expired"
+	CodeTooManyAttempts    Code = "too_many_attempts"
+	CodeRateLimited        Code = "rate_limited"
+	CodeInternal           Code = "internal_error"
+	CodeUnavailable        Code = "service_unavailable"
+)
+
+// Body is the error object returned by every endpoint
+type Body struct {
+	Code      Code              `json:"code"`
+	Message   string            `json:"message"`
+	Details   map[string]string `json:"details,omitempty"`
+	RequestID string            `json:"requestId,omitempty"`
+}
+
+// Response is the JSON envelope: {"error": {...}}
+type Response struct {
+	Error Body `json:"error"`
+}
+
+// CodeForStatus returns the default code for an HTTP status
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return CodeBadRequest
+	case http.StatusUnauthorized:
+		return CodeUnauthorized
+	case http.StatusForbidden:
+		return CodeForbidden
+	case http.StatusNotFound:
+		return CodeNotFound
+	case http.StatusMethodNotAllowed:
+		return CodeMethodNotAllowed
+	case http.StatusConflict:
+		return CodeConflict
+	case http.StatusTooManyRequests:
+		return CodeRateLimited
+	case http.StatusServiceUnavailable:
+		return CodeUnavailable
+	}
+	if status >= 500 {
+		return CodeInternal
+	}
+	return CodeBadRequest
+}
+
+// Error writes a JSON error using the default code for status. It mirrors
+// http.Error so handlers can switch over without reordering arguments.
+func Error(w http.ResponseWriter, r *http.Request, message string, status int) {
+	ErrorWithCode(w, r, CodeForStatus(status), message, status)
+}
+
+// ErrorWithCode writes a JSON error with a specific code
+func ErrorWithCode(w http.ResponseWriter, r *http.Request, code Code, message string, status int) {
+	write(w, status, Body{
+		Code:      code,
+		Message:   message,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
+
+// ErrorWithDetails writes a JSON error with a specific code and extra context
+func ErrorWithDetails(w http.ResponseWriter, r *http.Request, code Code, message string, status int, details map[string]string) {
+	write(w, status, Body{
+		Code:      code,
+		Message:   message,
+		Details:   details,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
+
+// ValidationError writes a 400 listing the problem with each invalid field
+func ValidationError(w http.ResponseWriter, r *http.Request, details map[string]string) {
+	write(w, http.StatusBadRequest, Body{
+		Code:      CodeValidation,
+		Message:   "Validation failed",
+		Details:   details,
+		RequestID: RequestIDFromContext(r.Context()),
+	})
+}
+
+func write(w http.ResponseWriter, status int, body Body) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{Error: body})
+}
+
+type contextKey string
+
+const requestIDContextKey contextKey = "requestID"
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// WithRequestID assigns every request an ID, reusing a well formed incoming
+// X-Request-ID, and echoes it in the response so errors can be traced in logs.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+	})
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,48 @@
+// Package apiroute adapts http.ServeMux to the API's URL conventions: custom
+// methods such as POST /api/v1/entries/12:serve, and deprecated aliases that
+// point clients at their replacement.
+package apiroute
+
+import (
+	"net/http"
+	"strings"
+
+	"wait-to-go/apierror"
+)
+
+// CustomMethods dispatches custom methods such as POST /api/v1/entries/12:serve.
+// ServeMux wildcards must fill a whole path segment, so the route is registered
+// on its last wildcard, {id} or {queue}, and the action is split off here;
+// handlers see the bare value.
+func CustomMethods(actions map[string]http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.Pattern[strings.LastIndex(r.Pattern, "{")+1 : len(r.Pattern)-1]
+		value, action, ok := strings.Cut(r.PathValue(name), ":")
+		handler, found := actions[action]
+		if !ok || !found {
+			apierror.Error(w, r, "Not found", http.StatusNotFound)
+			return
+		}
+
+		r.SetPathValue(name, value)
+		handler(w, r)
+	}
+}
+
+// Deprecated marks responses from an unversioned route and links to its
+// replacement. An {id} in successor is filled from the request path when the
+// old route has one.
+func Deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link := successor
+		if id := r.PathValue("id"); id != "" {
+			link = strings.ReplaceAll(link, "{id}", id)
+		}
+
+		w.Header().Set("Deprecation", "true")
+		if !strings.Contains(link, "{") {
+			w.Header().Add("Link", "<"+link+">; rel=\"successor-version\"")
+		}
+		next(w, r)
+	}
+}
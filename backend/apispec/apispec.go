@@ -0,0 +1,48 @@
+// Package apispec embeds the OpenAPI description of the /api/v1 endpoints and
+// a docs page that renders it.
+package apispec
+
+import (
+	"bytes"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"net/http"
+)
+
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed docs.html
+var docsPage []byte
+
+// SwaggerUIURL is the exact swagger-ui-dist release the docs page loads. The
+// docs page's Content-Security-Policy only allows scripts from this path, so
+// a moved tag on the CDN cannot swap in other code.
+const SwaggerUIURL = "https://unpkg.com/swagger-ui-dist@5.17.14/"
+
+var docsPolicy = "default-src 'none'; " +
+	"script-src " + SwaggerUIURL + " 'sha256-" + inlineScriptHash(docsPage) + "'; " +
+	"style-src " + SwaggerUIURL + " 'unsafe-inline'; " +
+	"img-src 'self' data:; connect-src 'self'"
+
+// inlineScriptHash returns the CSP hash of the page's inline script
+func inlineScriptHash(page []byte) string {
+	_, rest, _ := bytes.Cut(page, []byte("<script>"))
+	script, _, _ := bytes.Cut(rest, []byte("</script>"))
+	sum := sha256.Sum256(script)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// SpecHandler serves the OpenAPI document
+func SpecHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(Spec)
+}
+
+// DocsHandler serves an HTML page rendering the spec from /openapi.json
+func DocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", docsPolicy)
+	w.Write(docsPage)
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/queueing"
+)
+
+// ErrNotBooked means an entry has no appointment waiting to be checked in
+var ErrNotBooked = errors.New("entry has no booked appointment")
+
+// The appointment policy and placement live in queueing
+type AppointmentPolicy = queueing.AppointmentPolicy
+
+const (
+	PriorityFirst     = queueing.PriorityFirst
+	PriorityAlternate = queueing.PriorityAlternate
+	PriorityNone      = queueing.PriorityNone
+)
+
+// bookAppointment stores a new booked entry
+func bookAppointment(entry Entry, db *sql.DB) (Entry, error) {
+	if entry.Status != StatusBooked {
+		return Entry{}, fmt.Errorf("entry must be in booked status")
+	}
+
+	if err := insertAppointment(db, &entry); err != nil {
+		return Entry{}, fmt.Errorf("failed to insert entry: %w", err)
+	}
+
+	queueEvents.emit(EventBooked, entry)
+	return entry, nil
+}
+
+// admitAppointment moves a booked entry into the queue, placed among the
+// walk-ins as the policy says
+func admitAppointment(entry *Entry, policy AppointmentPolicy, queue *[]Entry, history []Entry, db *sql.DB) error {
+	sort.Sort(ByQueueOrder(*queue))
+	target := policy.PlaceFor(*queue, history)
+
+	now := time.Now()
+	if err := moveToBack(db, entry, StatusWaiting, &now); err != nil {
+		return err
+	}
+
+	// The entry joined at the back, so it sorts last
+	*queue = append(*queue, *entry)
+	sort.Sort(ByQueueOrder(*queue))
+	placed, _, err := placeEntry(len(*queue)-1, target, queue, db)
+	if err != nil {
+		return err
+	}
+
+	*entry = placed
+	queueEvents.emit(EventJoined, *entry)
+	return nil
+}
+
+// checkIn records that a booked customer has arrived. An appointment whose
+// slot has started enters the queue straight away if it is open; an early
+// one, or one checked in while the queue is paused or closed, enters when
+// admitDueAppointments next finds the queue open. The caller must hold queueMu.
+func (a *App) checkIn(entry *Entry, now time.Time) error {
+	if entry.Status != StatusBooked {
+		return ErrNotBooked
+	}
+
+	if entry.CheckedInAt == nil {
+		entry.CheckedInAt = &now
+		if err := updateCheckedIn(a.db, *entry); err != nil {
+			return err
+		}
+		queueEvents.emit(EventCheckedIn, *entry)
+	}
+
+	open, err := a.queueOpen()
+	if err != nil {
+		return err
+	}
+	if a.appointments.Due(*entry, now, open) != queueing.DueAdmit {
+		return nil
+	}
+	return admitAppointment(entry, a.appointments, a.queue, *a.history, a.db)
+}
+
+// admitDueAppointments lets checked in appointments whose slot has started
+// into the queue, and marks those still not checked in NoShowAfter into their
+// slot as no-shows. While the queue is paused or closed nobody is let in;
+// checked in appointments wait for it to open again.
+func (a *App) admitDueAppointments(now time.Time) error {
+	a.queueMu.Lock()
+	defer a.queueMu.Unlock()
+
+	open, err := a.queueOpen()
+	if err != nil {
+		return err
+	}
+	due, err := getDueAppointments(a.db, now)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range due {
+		switch a.appointments.Due(entry, now, open) {
+		case queueing.DueAdmit:
+			err = admitAppointment(&entry, a.appointments, a.queue, *a.history, a.db)
+		case queueing.DueNoShow:
+			err = markNoShow(&entry, a.db)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update appointment %d: %w", entry.ID, err)
+		}
+	}
+	return nil
+}
+
+// queueOpen reports whether the queue is open, so appointments may enter it
+func (a *App) queueOpen() (bool, error) {
+	settings, err := getQueueSettings(a.db)
+	if err != nil {
+		return false, err
+	}
+	return settings.State == QueueOpen, nil
+}
+
+// handleBook books an appointment: POST /api/v1/queues/{queue}/appointments
+// with the same fields as a join plus appointmentAt, the start of a slot
+func (a *App) handleBook(w http.ResponseWriter, r *http.Request) {
+	if !a.checkJoinSource(w, r) || !a.checkPoster(w, r) {
+		return
+	}
+
+	var req AppointmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	entry := req.entry()
+	entry.AppointmentAt = req.AppointmentAt
+	entry.Status = StatusBooked
+	entry.JoinTime = now
+
+	fields := queueing.ValidateEntry(&entry, a.phoneRegion)
+	if msg := validateServiceType(&entry, a.serviceTypes); msg != "" {
+		fields["serviceType"] = msg
+	}
+	if entry.PartySize == 0 {
+		entry.PartySize = 1
+	}
+	if msg := queueing.ValidatePartySize(entry.PartySize, a.party.MaxSize); msg != "" {
+		fields["partySize"] = msg
+	}
+	if msg := a.appointments.ValidateSlot(entry.AppointmentAt, now); msg != "" {
+		fields["appointmentAt"] = msg
+	}
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+	// Stored without a time zone, like every timestamp here, so keep slots in UTC
+	slot := entry.AppointmentAt.UTC()
+	entry.AppointmentAt = &slot
+
+	settings, err := getQueueSettings(a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to book appointment", http.StatusInternalServerError)
+		return
+	}
+	if settings.Schedule != nil {
+		holidays, err := getHolidays(a.db)
+		if err != nil {
+			apierror.Error(w, r, "Failed to book appointment", http.StatusInternalServerError)
+			return
+		}
+		if !settings.Schedule.OpenAt(*entry.AppointmentAt, holidays) {
+			apierror.ValidationError(w, r, map[string]string{"appointmentAt": "The queue is not open at that time"})
+			return
+		}
+	}
+
+	if !a.checkJoinPhone(w, r, entry.PhoneNumber) || !a.checkBookingDuplicate(w, r, entry.PhoneNumber, false) {
+		return
+	}
+
+	if a.otp.VerifyPhone {
+		// completeBooking checks the slot and the queue again once the phone is verified
+		if !a.checkBookingRoom(w, r, *entry.AppointmentAt) {
+			return
+		}
+		a.startJoinVerification(w, r, entry)
+		return
+	}
+
+	if entry, ok := a.admitBooking(w, r, entry); ok {
+		a.writeBooked(w, r, entry)
+	}
+}
+
+// completeBooking books a pending appointment once its phone is verified,
+// checking again that the phone, the slot and the queue still have room
+func (a *App) completeBooking(w http.ResponseWriter, r *http.Request, entry Entry) {
+	if !a.checkBookingDuplicate(w, r, entry.PhoneNumber, true) {
+		return
+	}
+	if entry, ok := a.admitBooking(w, r, entry); ok {
+		a.writeBooked(w, r, entry)
+	}
+}
+
+// checkBookingRoom checks that the slot and the queue can take one more
+// appointment
+func (a *App) checkBookingRoom(w http.ResponseWriter, r *http.Request, at time.Time) bool {
+	a.queueMu.Lock()
+	defer a.queueMu.Unlock()
+
+	return a.checkSlotFree(w, r, at) && a.checkBookingCapacity(w, r)
+}
+
+// admitBooking books a new appointment, or a verified pending one, and
+// returns the stored entry. queueMu is held from the slot and capacity checks
+// to the insert, so two bookings cannot both take the last place. It writes
+// the error response and returns false otherwise.
+func (a *App) admitBooking(w http.ResponseWriter, r *http.Request, entry Entry) (Entry, bool) {
+	a.queueMu.Lock()
+	defer a.queueMu.Unlock()
+
+	if !a.checkSlotFree(w, r, *entry.AppointmentAt) || !a.checkBookingCapacity(w, r) {
+		return Entry{}, false
+	}
+
+	if entry.Status == StatusPending {
+		if err := setStatus(a.db, &entry, StatusBooked); err != nil {
+			writeStatusError(w, r, err, "Failed to book appointment")
+			return Entry{}, false
+		}
+		queueEvents.emit(EventBooked, entry)
+		return entry, true
+	}
+
+	booked, err := bookAppointment(entry, a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to book appointment", http.StatusInternalServerError)
+		return Entry{}, false
+	}
+	return booked, true
+}
+
+// checkBookingDuplicate applies the duplicate policy to a booking, counting
+// the phone's other appointments as well as its places in the queue. verified
+// says whether the caller has proved they hold the phone.
+func (a *App) checkBookingDuplicate(w http.ResponseWriter, r *http.Request, phoneNumber string, verified bool) bool {
+	existing, err := getEntriesByPhone(a.db, phoneNumber, StatusBooked, StatusWaitlisted, StatusWaiting, StatusNotified)
+	if err != nil {
+		apierror.Error(w, r, "Failed to book appointment", http.StatusInternalServerError)
+		return false
+	}
+	if len(existing) > 0 && !a.duplicatePolicy.Allows(len(existing)) {
+		a.handleDuplicateJoin(w, r, existing[0], verified)
+		return false
+	}
+	return true
+}
+
+// checkSlotFree writes a 409 and returns false when the slot is fully booked
+func (a *App) checkSlotFree(w http.ResponseWriter, r *http.Request, at time.Time) bool {
+	booked, err := countAppointments(a.db, at)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		apierror.Error(w, r, "Failed to book appointment", http.StatusInternalServerError)
+		return false
+	}
+	if booked >= a.appointments.PerSlot {
+		apierror.ErrorWithCode(w, r, apierror.CodeSlotFull, "That slot is fully booked, please choose another time", http.StatusConflict)
+		return false
+	}
+	return true
+}
+
+// checkBookingCapacity turns bookings away while the queue is full. An
+// appointment has a slot to keep, so unlike a join it cannot be waitlisted.
+func (a *App) checkBookingCapacity(w http.ResponseWriter, r *http.Request) bool {
+	waitlist, ok := a.checkCapacity(w, r)
+	if ok && waitlist {
+		apierror.ErrorWithCode(w, r, apierror.CodeQueueFull, "The queue is full right now, please try booking again later", http.StatusConflict)
+		return false
+	}
+	return ok
+}
+
+func (a *App) writeBooked(w http.ResponseWriter, r *http.Request, entry Entry) {
+	token, err := auth.GenerateToken(entry.ID, entry.PhoneNumber)
+	if err != nil {
+		apierror.Error(w, r, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":        "success",
+		"id":            entry.ID,
+		"token":         token,
+		"appointmentAt": entry.AppointmentAt,
+	})
+}
+
+// handleCheckIn records that a customer with an appointment has arrived:
+// POST /api/v1/entries/{id}:checkIn
+func (a *App) handleCheckIn(w http.ResponseWriter, r *http.Request) {
+	entryID, ok := customerEntryID(w, r)
+	if !ok {
+		return
+	}
+
+	entry, err := getEntryByID(a.db, entryID)
+	if err != nil {
+		writeStatusError(w, r, err, "Failed to check in")
+		return
+	}
+
+	if err := a.checkIn(&entry, time.Now()); err != nil {
+		if errors.Is(err, ErrNotBooked) {
+			apierror.ErrorWithCode(w, r, apierror.CodeConflict, "Entry has no appointment waiting to be checked in", http.StatusConflict)
+			return
+		}
+		writeStatusError(w, r, err, "Failed to check in")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"entry":    entry,
+		"position": queuePosition(*a.queue, entry),
+	})
+}
+
+// handleCancel lets a customer leave the queue or the waitlist, or cancel an
+// appointment: POST /api/v1/entries/{id}:cancel
+func (a *App) handleCancel(w http.ResponseWriter, r *http.Request) {
+	entryID, ok := customerEntryID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := cancelEntry(entryID, a.queue, a.db); err != nil {
+		writeStatusError(w, r, err, "Failed to cancel")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
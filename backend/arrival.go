@@ -0,0 +1,264 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"wait-to-go/apierror"
+)
+
+var (
+	// ErrNotInQueue means an entry is neither waiting nor booked, so arriving means nothing
+	ErrNotInQueue = errors.New("entry is not in the queue")
+
+	errArrivalCodeInvalid = errors.New("arrival code is not valid")
+	errArrivalCodeExpired = errors.New("arrival code has expired")
+)
+
+// ArrivalCodes issues the codes customers scan on site to confirm they have
+// arrived. Codes are signed with Secret and rotate every Period, so a photo
+// of one soon stops working. The previous code is still accepted, so a scan
+// just before it changes is not lost.
+type ArrivalCodes struct {
+	Secret []byte
+	Period time.Duration
+	// URL, if set, is the page the QR code opens, with the code as ?arrival=
+	URL string
+}
+
+// ArrivalCode is the code to show on site until ExpiresAt
+type ArrivalCode struct {
+	Code      string    `json:"code"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	URL       string    `json:"url,omitempty"`
+}
+
+func (c ArrivalCodes) window(t time.Time) int64 {
+	return t.UnixNano() / int64(c.Period)
+}
+
+// sign returns the code for a window
+func (c ArrivalCodes) sign(window int64) string {
+	return signCode(c.Secret, "arrival", window)
+}
+
+// at returns the code shown at t
+func (c ArrivalCodes) at(t time.Time) ArrivalCode {
+	window := c.window(t)
+	code := ArrivalCode{
+		Code:      c.sign(window),
+		ExpiresAt: time.Unix(0, (window+1)*int64(c.Period)),
+	}
+
+	if u, err := url.Parse(c.URL); err == nil && c.URL != "" {
+		query := u.Query()
+		query.Set("arrival", code.Code)
+		u.RawQuery = query.Encode()
+		code.URL = u.String()
+	}
+	return code
+}
+
+// check accepts the current and the previous code
+func (c ArrivalCodes) check(code string, now time.Time) error {
+	window, ok := signedValue(c.Secret, "arrival", code)
+	if !ok {
+		return errArrivalCodeInvalid
+	}
+
+	current := c.window(now)
+	switch {
+	case window > current:
+		return errArrivalCodeInvalid
+	case window < current-1:
+		return errArrivalCodeExpired
+	}
+	return nil
+}
+
+// Geofence is a circle around the site. Customers whose phone reports a
+// location inside it may confirm they have arrived without scanning a code.
+type Geofence struct {
+	Latitude  float64
+	Longitude float64
+	// Radius is in meters
+	Radius float64
+}
+
+// parseGeofence reads ARRIVAL_GEOFENCE, "latitude,longitude,radius" with the
+// radius in meters, such as "40.7484,-73.9857,150"
+func parseGeofence(value string) (*Geofence, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("geofence must be latitude,longitude,radius, got %q", value)
+	}
+	var numbers [3]float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("geofence must be latitude,longitude,radius, got %q", value)
+		}
+		numbers[i] = n
+	}
+
+	fence := Geofence{Latitude: numbers[0], Longitude: numbers[1], Radius: numbers[2]}
+	if !validCoordinates(fence.Latitude, fence.Longitude) || fence.Radius <= 0 {
+		return nil, fmt.Errorf("geofence %q is out of range", value)
+	}
+	return &fence, nil
+}
+
+func validCoordinates(latitude, longitude float64) bool {
+	return latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180
+}
+
+// contains reports whether a point is within the fence, by great-circle distance
+func (g Geofence) contains(latitude, longitude float64) bool {
+	const earthRadius = 6371000 // meters
+	rad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := rad(latitude - g.Latitude)
+	dLon := rad(longitude - g.Longitude)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(rad(g.Latitude))*math.Cos(rad(latitude))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2*earthRadius*math.Asin(math.Sqrt(h)) <= g.Radius
+}
+
+// arrive records that a waiting or booked customer is on site. A booked
+// customer is checked in as well. Arriving again changes nothing. The caller
+// must hold queueMu.
+func (a *App) arrive(entry *Entry, now time.Time) error {
+	if entry.Status == StatusBooked {
+		if err := a.checkIn(entry, now); err != nil {
+			return err
+		}
+	}
+	if entry.Status != StatusWaiting && entry.Status != StatusBooked {
+		return ErrNotInQueue
+	}
+	if entry.ArrivedAt != nil {
+		return nil
+	}
+
+	entry.ArrivedAt = &now
+	if err := updateArrivedAt(a.db, *entry); err != nil {
+		return err
+	}
+	if index := slices.IndexFunc(*a.queue, func(e Entry) bool { return e.ID == entry.ID }); index != -1 {
+		(*a.queue)[index].ArrivedAt = &now
+	}
+
+	queueEvents.emit(EventArrived, *entry)
+	return nil
+}
+
+// handleArrive confirms that a customer is on site:
+// POST /api/v1/entries/{id}:arrive with the {"code"} from the QR code or,
+// when there is a geofence, the phone's {"latitude", "longitude"}
+func (a *App) handleArrive(w http.ResponseWriter, r *http.Request) {
+	entryID, ok := customerEntryID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Code      string   `json:"code"`
+		Latitude  *float64 `json:"latitude"`
+		Longitude *float64 `json:"longitude"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case req.Code != "":
+		switch a.arrivalCodes.check(req.Code, now) {
+		case errArrivalCodeExpired:
+			apierror.ErrorWithCode(w, r, apierror.CodeCodeExpired, "The arrival code has expired; please scan the code on site again", http.StatusGone)
+			return
+		case errArrivalCodeInvalid:
+			apierror.ErrorWithCode(w, r, apierror.CodeInvalidCode, "The arrival code is not valid", http.StatusBadRequest)
+			return
+		}
+	case a.geofence == nil:
+		apierror.ValidationError(w, r, map[string]string{"code": "Scan the code on site to confirm you have arrived"})
+		return
+	case req.Latitude == nil || req.Longitude == nil || !validCoordinates(*req.Latitude, *req.Longitude):
+		apierror.ValidationError(w, r, map[string]string{"latitude": "A code, or a latitude and longitude, is required"})
+		return
+	case !a.geofence.contains(*req.Latitude, *req.Longitude):
+		apierror.ErrorWithCode(w, r, apierror.CodeNotOnSite, "You don't seem to be here yet; scan the code on site instead", http.StatusForbidden)
+		return
+	}
+
+	entry, err := getEntryByID(a.db, entryID)
+	if err != nil {
+		writeStatusError(w, r, err, "Failed to confirm arrival")
+		return
+	}
+
+	if err := a.arrive(&entry, now); err != nil {
+		switch {
+		case errors.Is(err, ErrNotInQueue):
+			apierror.ErrorWithCode(w, r, apierror.CodeConflict, "Only customers waiting in the queue can confirm they have arrived", http.StatusConflict)
+		default:
+			writeStatusError(w, r, err, "Failed to confirm arrival")
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"entry":    entry,
+		"position": queuePosition(*a.queue, entry),
+	})
+}
+
+// handleArrivalCode returns the code to show on site, to be refreshed at
+// expiresAt: GET /api/v1/queues/{queue}/arrivalCode?format=json|png|svg.
+// The png and svg formats render it as a QR code of the URL, or of the code
+// without ARRIVAL_URL, and give expiresAt in X-Arrival-Expires.
+func (a *App) handleArrivalCode(w http.ResponseWriter, r *http.Request) {
+	fields := map[string]string{}
+	image := parseQRImage(r.URL.Query(), fields, "json", "png", "svg")
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	code := a.arrivalCodes.at(time.Now())
+	w.Header().Set("Cache-Control", "no-store")
+	if image.format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(code)
+		return
+	}
+
+	text := code.URL
+	if text == "" {
+		text = code.Code
+	}
+	body, contentType, err := image.render(text)
+	if err != nil {
+		apierror.Error(w, r, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Arrival-Expires", code.ExpiresAt.UTC().Format(time.RFC3339))
+	w.Write(body)
+}
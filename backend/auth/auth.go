@@ -3,12 +3,15 @@ package auth
 import (
 	"encoding/base64"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
 	"time"
 
+	"wait-to-go/apierror"
+
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -174,27 +177,27 @@ func ValidateToken(tokenString string) (*Claims, error) {
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Rate limiting based on IP
-		clientIP := r.RemoteAddr
+		clientIP := ClientIP(r)
 		if !authLimiter.Allow(clientIP) {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			apierror.Error(w, r, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
 
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			http.Error(w, "Authorization header required", http.StatusUnauthorized)
+			apierror.Error(w, r, "Authorization header required", http.StatusUnauthorized)
 			return
 		}
 
 		parts := strings.Split(authHeader, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			http.Error(w, "Invalid authorization format", http.StatusUnauthorized)
+			apierror.Error(w, r, "Invalid authorization format", http.StatusUnauthorized)
 			return
 		}
 
 		claims, err := ValidateToken(parts[1])
 		if err != nil {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			apierror.ErrorWithCode(w, r, apierror.CodeInvalidToken, "Invalid token", http.StatusUnauthorized)
 			return
 		}
 
@@ -207,20 +210,20 @@ func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 func AdminAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Rate limiting based on IP
-		clientIP := r.RemoteAddr
+		clientIP := ClientIP(r)
 		if !adminLimiter.Allow(clientIP) {
-			http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+			apierror.Error(w, r, "Rate limit exceeded", http.StatusTooManyRequests)
 			return
 		}
 
 		apiKey := r.Header.Get("X-API-Key")
 		if apiKey == "" {
-			http.Error(w, "API key required", http.StatusUnauthorized)
+			apierror.Error(w, r, "API key required", http.StatusUnauthorized)
 			return
 		}
 
 		if !ValidateAdminKey(apiKey) {
-			http.Error(w, "Invalid API key", http.StatusUnauthorized)
+			apierror.ErrorWithCode(w, r, apierror.CodeInvalidAPIKey, "Invalid API key", http.StatusUnauthorized)
 			return
 		}
 
@@ -228,6 +231,16 @@ func AdminAuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// ClientIP returns the remote address without its port, so every connection
+// from the same host shares one rate limit bucket
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureTimestampHeader carries the Unix time in seconds an inbound
+// callback was signed at
+const SignatureTimestampHeader = "X-Signature-Timestamp"
+
+// SignatureTolerance is how far a signed timestamp may be from the receiver's
+// clock. Older requests are refused, so a captured one cannot be replayed later.
+const SignatureTolerance = 5 * time.Minute
+
+// SignPayload returns the HMAC-SHA256 of body, used for both inbound and outbound webhooks
+func SignPayload(secret, body []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// SignTimestamped signs "timestamp.body", tying the body to the Unix time in
+// seconds it was sent at
+func SignTimestamped(secret []byte, timestamp int64, body []byte) []byte {
+	return SignPayload(secret, timestamped(strconv.FormatInt(timestamp, 10), body))
+}
+
+// VerifySignature checks the hex encoded HMAC-SHA256 of the raw request body,
+// with or without a "sha256=" prefix
+func VerifySignature(secret, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	return hmac.Equal(SignPayload(secret, body), expected)
+}
+
+// VerifyTimestamped checks a signature made by SignTimestamped, and that the
+// timestamp is within SignatureTolerance of now
+func VerifyTimestamped(secret, body []byte, timestamp, signature string, now time.Time) bool {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > SignatureTolerance || skew < -SignatureTolerance {
+		return false
+	}
+
+	return VerifySignature(secret, timestamped(timestamp, body), signature)
+}
+
+func timestamped(timestamp string, body []byte) []byte {
+	return append([]byte(timestamp+"."), body...)
+}
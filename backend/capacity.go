@@ -0,0 +1,165 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/queueing"
+)
+
+// Capacity and its full queue policies live in queueing
+type Capacity = queueing.Capacity
+
+const (
+	FullReject   = queueing.FullReject
+	FullWaitlist = queueing.FullWaitlist
+)
+
+// queueFull reports whether a new customer cannot join the queue directly.
+// While anyone is waitlisted the queue counts as full, so newcomers never
+// overtake them. The caller must hold queueMu.
+func (a *App) queueFull() (bool, error) {
+	if a.capacity.Full(*a.queue, a.serviceTime) {
+		return true, nil
+	}
+	waitlisted, err := countWaitlisted(a.db)
+	return waitlisted > 0, err
+}
+
+// checkCapacity returns whether a joining customer goes on the waitlist. If
+// the queue is full and has no waitlist it writes a 409 with the current wait
+// and returns ok false.
+func (a *App) checkCapacity(w http.ResponseWriter, r *http.Request) (waitlist bool, ok bool) {
+	full, err := a.queueFull()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return false, false
+	}
+	if !full {
+		return false, true
+	}
+	if a.capacity.WhenFull == FullWaitlist {
+		return true, true
+	}
+
+	waiting := len(*a.queue)
+	minutes := int(queueing.EstimateWait(*a.queue, a.serviceTime).Minutes())
+	apierror.ErrorWithDetails(w, r, apierror.CodeQueueFull,
+		fmt.Sprintf("The queue is full right now. The current wait is about %d minutes; please try again later.", minutes),
+		http.StatusConflict,
+		map[string]string{
+			"waiting":              strconv.Itoa(waiting),
+			"estimatedWaitMinutes": strconv.Itoa(minutes),
+		})
+	return false, false
+}
+
+// addWaitlistedEntry stores a new entry on the waitlist
+func addWaitlistedEntry(entry Entry, db *sql.DB) (Entry, error) {
+	if entry.Status != StatusWaitlisted {
+		return Entry{}, fmt.Errorf("entry must be in waitlisted status")
+	}
+
+	if err := insertEntry(db, &entry); err != nil {
+		return Entry{}, fmt.Errorf("failed to insert entry: %w", err)
+	}
+
+	queueEvents.emit(EventWaitlisted, entry)
+	return entry, nil
+}
+
+// waitlistEntry moves a verified pending entry onto the back of the waitlist
+func waitlistEntry(entry *Entry, db *sql.DB) error {
+	now := time.Now()
+	if err := moveToBack(db, entry, StatusWaitlisted, &now); err != nil {
+		return err
+	}
+
+	queueEvents.emit(EventWaitlisted, *entry)
+	return nil
+}
+
+// promoteEntry moves a waitlisted entry to the back of the queue. It keeps
+// its join time.
+func promoteEntry(entry *Entry, queue *[]Entry, db *sql.DB) error {
+	if err := moveToBack(db, entry, StatusWaiting, nil); err != nil {
+		return err
+	}
+
+	*queue = append(*queue, *entry)
+	sort.Sort(ByQueueOrder(*queue))
+	queueEvents.emit(EventPromoted, *entry)
+	return nil
+}
+
+// promoteWaitlisted moves waitlisted customers into the queue, oldest first,
+// while there is room, and tells each one. The caller must hold queueMu.
+func (a *App) promoteWaitlisted() error {
+	for !a.capacity.Full(*a.queue, a.serviceTime) {
+		entry, err := getFirstWaitlisted(a.db)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := promoteEntry(&entry, a.queue, a.db); err != nil {
+			return err
+		}
+
+		body := fmt.Sprintf("Hi %s, a place has opened up and you're now number %d in the queue.", entry.FirstName, queuePosition(*a.queue, entry))
+		msg := customerMessage(entry, "You're in the queue", body)
+		go func() {
+			if err := a.notifier.Send(msg); err != nil {
+				log.Printf("Warning: failed to notify entry %d of promotion: %v", entry.ID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// registerCapacity promotes waitlisted customers whenever a place opens up
+func (a *App) registerCapacity() {
+	queueEvents.subscribe(func(event QueueEvent) {
+		switch event.Type {
+		case EventNotified, EventCancelled, EventCleared:
+			if err := a.promoteWaitlisted(); err != nil {
+				log.Printf("Warning: failed to promote waitlisted entries: %v", err)
+			}
+		}
+	})
+}
+
+// writeWaitlisted answers a join that was put on the waitlist
+func (a *App) writeWaitlisted(w http.ResponseWriter, r *http.Request, entry Entry) {
+	token, err := auth.GenerateToken(entry.ID, entry.PhoneNumber)
+	if err != nil {
+		apierror.Error(w, r, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	position, err := waitlistPosition(a.db, entry)
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":           "waitlisted",
+		"id":               entry.ID,
+		"token":            token,
+		"waitlistPosition": position,
+	})
+}
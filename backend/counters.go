@@ -0,0 +1,263 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/queueing"
+)
+
+// AdminSessionHeader tells apart staff sharing one API key, so each browser
+// or device can hold its own counter
+const AdminSessionHeader = "X-Admin-Session"
+
+var (
+	ErrCounterClaimed   = queueing.ErrCounterClaimed
+	ErrDuplicateCounter = errors.New("counter name is already in use")
+)
+
+// Counter lives in queueing with the rules for whom it calls
+type Counter = queueing.Counter
+
+// operatorID identifies the member of staff making an admin request by their
+// API key and, if sent, their session header. Only a hash is stored.
+func operatorID(r *http.Request) string {
+	return hashBytes([]byte(r.Header.Get("X-API-Key") + "|" + r.Header.Get(AdminSessionHeader)))[:32]
+}
+
+// callerCounter returns the counter held by the operator making the request,
+// or nil if they have not claimed one
+func (a *App) callerCounter(r *http.Request) (*Counter, error) {
+	counter, err := getCounterByOperator(a.db, operatorID(r))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &counter, nil
+}
+
+func counterID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		apierror.Error(w, r, "Invalid ID format", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// handleListCounters lists counters with the customer each one is serving
+func (a *App) handleListCounters(w http.ResponseWriter, r *http.Request) {
+	counters, err := getCounters(a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get counters", http.StatusInternalServerError)
+		return
+	}
+	current, err := getCounterCustomers(a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get counters", http.StatusInternalServerError)
+		return
+	}
+
+	operator := operatorID(r)
+	for i, counter := range counters {
+		counters[i].Mine = counter.ClaimedBy == operator
+		if entry, ok := current[counter.ID]; ok {
+			counters[i].Current = &entry
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counters)
+}
+
+// handleCreateCounter adds a counter: {"name": "Desk 3", "skills": ["returns"], "overflow": true, "seats": 4}
+func (a *App) handleCreateCounter(w http.ResponseWriter, r *http.Request) {
+	var counter Counter
+	if err := json.NewDecoder(r.Body).Decode(&counter); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if counter.Skills == nil {
+		counter.Skills = []string{}
+	}
+	if fields := a.validateCounter(&counter); len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	counter.CreatedAt = time.Now()
+	id, err := insertCounter(a.db, counter)
+	if errors.Is(err, ErrDuplicateCounter) {
+		apierror.ErrorWithCode(w, r, apierror.CodeConflict, "A counter with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, r, "Failed to create counter", http.StatusInternalServerError)
+		return
+	}
+	counter.ID = id
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(counter)
+}
+
+// handleUpdateCounter changes any of a counter's name, skills, overflow and seats:
+// PATCH /api/v1/counters/{id}
+func (a *App) handleUpdateCounter(w http.ResponseWriter, r *http.Request) {
+	id, ok := counterID(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Name     *string   `json:"name"`
+		Skills   *[]string `json:"skills"`
+		Overflow *bool     `json:"overflow"`
+		Seats    *int      `json:"seats"`
+	}
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	counter, err := getCounterByID(a.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Counter not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to get counter", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if req.Name != nil {
+		counter.Name = *req.Name
+	}
+	if req.Skills != nil {
+		counter.Skills = *req.Skills
+	}
+	if req.Overflow != nil {
+		counter.Overflow = *req.Overflow
+	}
+	if req.Seats != nil {
+		counter.Seats = *req.Seats
+	}
+	if fields := a.validateCounter(&counter); len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	err = updateCounter(a.db, counter)
+	if errors.Is(err, ErrDuplicateCounter) {
+		apierror.ErrorWithCode(w, r, apierror.CodeConflict, "A counter with this name already exists", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		apierror.Error(w, r, "Failed to update counter", http.StatusInternalServerError)
+		return
+	}
+	counter.Mine = counter.ClaimedBy == operatorID(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counter)
+}
+
+// validateCounter trims the name and checks the skills are service types
+func (a *App) validateCounter(counter *Counter) FieldErrors {
+	fields := FieldErrors{}
+
+	counter.Name = strings.TrimSpace(counter.Name)
+	if counter.Name == "" || len(counter.Name) > 50 {
+		fields["name"] = "Name is required and must be at most 50 characters"
+	}
+	if counter.Skills == nil {
+		counter.Skills = []string{}
+	}
+	if msg := validateSkills(counter.Skills, a.serviceTypes); msg != "" {
+		fields["skills"] = msg
+	}
+	if counter.Seats < 0 {
+		fields["seats"] = "Seats must not be negative"
+	}
+	return fields
+}
+
+// handleDeleteCounter removes a counter. Entries it called keep its name.
+func (a *App) handleDeleteCounter(w http.ResponseWriter, r *http.Request) {
+	id, ok := counterID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := deleteCounter(a.db, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Counter not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to delete counter", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleClaimCounter assigns a counter to the caller, releasing any other
+// counter they held: POST /api/v1/counters/{id}:claim
+func (a *App) handleClaimCounter(w http.ResponseWriter, r *http.Request) {
+	id, ok := counterID(w, r)
+	if !ok {
+		return
+	}
+
+	counter, err := claimCounter(a.db, id, operatorID(r), time.Now())
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrCounterClaimed):
+			apierror.ErrorWithCode(w, r, apierror.CodeCounterClaimed, "Counter is in use by someone else; release it first", http.StatusConflict)
+		case errors.Is(err, sql.ErrNoRows):
+			apierror.Error(w, r, "Counter not found", http.StatusNotFound)
+		default:
+			apierror.Error(w, r, "Failed to claim counter", http.StatusInternalServerError)
+		}
+		return
+	}
+	counter.Mine = true
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counter)
+}
+
+// handleReleaseCounter frees a counter, whoever holds it, so a desk left
+// claimed by a closed browser can be taken over: POST /api/v1/counters/{id}:release
+func (a *App) handleReleaseCounter(w http.ResponseWriter, r *http.Request) {
+	id, ok := counterID(w, r)
+	if !ok {
+		return
+	}
+
+	counter, err := releaseCounter(a.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Counter not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to release counter", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(counter)
+}
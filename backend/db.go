@@ -2,17 +2,87 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+
+	"wait-to-go/idempotency"
+	"wait-to-go/phone"
+	"wait-to-go/queueing"
 )
 
+const entryColumns = `id, firstName, lastName, email, phoneNumber, status, joinTime, notificationChannel, confirmedAt, notes, queueOrder, calledAt, counterId, counterName, serviceType, partySize, skips, appointmentAt, checkedInAt, arrivedAt`
+
+var entryMigrations = []string{
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS notificationChannel VARCHAR(10) NOT NULL DEFAULT 'sms'`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS confirmedAt timestamp`,
+	// E.164 numbers are up to 15 digits plus the leading "+"
+	`ALTER TABLE entry ALTER COLUMN phoneNumber TYPE VARCHAR(16)`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS notes VARCHAR(200) NOT NULL DEFAULT ''`,
+	// Existing entries keep their join order
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS queueOrder BIGINT`,
+	`UPDATE entry SET queueOrder = o.n * 1024 FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY joinTime, id) AS n FROM entry) o
+		WHERE entry.id = o.id AND entry.queueOrder IS NULL`,
+	`ALTER TABLE entry ALTER COLUMN queueOrder SET NOT NULL`,
+	`CREATE INDEX IF NOT EXISTS entry_queue_order ON entry (queueOrder)`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS calledAt timestamp`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS counterId INTEGER`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS counterName VARCHAR(50) NOT NULL DEFAULT ''`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS serviceType VARCHAR(30) NOT NULL DEFAULT ''`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS partySize INTEGER NOT NULL DEFAULT 1`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS skips INTEGER NOT NULL DEFAULT 0`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS appointmentAt timestamp`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS checkedInAt timestamp`,
+	`CREATE INDEX IF NOT EXISTS entry_appointment ON entry (appointmentAt) WHERE appointmentAt IS NOT NULL`,
+	`ALTER TABLE entry ADD COLUMN IF NOT EXISTS arrivedAt timestamp`,
+}
+
+// nextQueueOrder is the ordering key for an entry joining the back of the queue
+const nextQueueOrder = `(SELECT COALESCE(MAX(queueOrder), 0) + 1024 FROM entry)`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanEntry(row rowScanner) (Entry, error) {
+	var entry Entry
+	err := row.Scan(
+		&entry.ID,
+		&entry.FirstName,
+		&entry.LastName,
+		&entry.Email,
+		&entry.PhoneNumber,
+		&entry.Status,
+		&entry.JoinTime,
+		&entry.NotificationChannel,
+		&entry.ConfirmedAt,
+		&entry.Notes,
+		&entry.QueueOrder,
+		&entry.CalledAt,
+		&entry.CounterID,
+		&entry.Counter,
+		&entry.ServiceType,
+		&entry.PartySize,
+		&entry.Skips,
+		&entry.AppointmentAt,
+		&entry.CheckedInAt,
+		&entry.ArrivedAt,
+	)
+	return entry, err
+}
+
 func createEntryTable(db *sql.DB) error {
 	query := `CREATE TABLE IF NOT EXISTS entry (
 		id SERIAL PRIMARY KEY,
 		firstName VARCHAR(30) NOT NULL,
 		lastName VARCHAR(30) NOT NULL,
 		email VARCHAR(50),
-		phoneNumber VARCHAR(10),
+		phoneNumber VARCHAR(16),
 		status VARCHAR(20) NOT NULL,
 		joinTime timestamp DEFAULT NOW()
 	)`
@@ -22,6 +92,13 @@ func createEntryTable(db *sql.DB) error {
 		return fmt.Errorf("failed to create table: %w", err)
 	}
 
+	// Columns added after the initial schema; safe to re-run on existing databases
+	for _, migration := range entryMigrations {
+		if _, err = db.Exec(migration); err != nil {
+			return fmt.Errorf("failed to migrate table: %w", err)
+		}
+	}
+
 	if err = db.Ping(); err != nil {
 		return fmt.Errorf("failed to ping database: %w", err)
 	}
@@ -29,23 +106,304 @@ func createEntryTable(db *sql.DB) error {
 	return nil
 }
 
-func insertEntry(db *sql.DB, entry Entry) (int, error) {
-	query := `INSERT INTO entry (firstName, lastName, email, phoneNumber, status, joinTime) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+// normalizePhoneNumbers rewrites phone numbers stored before numbers were
+// kept in E.164 form, and numbers that kept their trunk prefix after the
+// country code, such as "+490301234567". Numbers that cannot be read are left
+// as they are and counted. Safe to re-run.
+func normalizePhoneNumbers(db *sql.DB, defaultRegion string) (fixed, unreadable int, err error) {
+	region := phone.Regions[defaultRegion]
+	trunked := "+" + region.CountryCode + region.TrunkPrefix
+	if region.TrunkPrefix == "" {
+		trunked = ""
+	}
 
-	var pk int
-	err := db.QueryRow(query, entry.FirstName, entry.LastName, entry.Email, entry.PhoneNumber, entry.Status, entry.JoinTime).Scan(&pk)
+	rows, err := db.Query(`SELECT id, phoneNumber FROM entry WHERE phoneNumber NOT LIKE '+%' OR ($1 <> '' AND phoneNumber LIKE $1 || '%')`, trunked)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to query phone numbers: %w", err)
+	}
+	normalized := map[int]string{}
+	for rows.Next() {
+		var id int
+		var number string
+		if err := rows.Scan(&id, &number); err != nil {
+			rows.Close()
+			return 0, 0, fmt.Errorf("failed to scan row: %w", err)
+		}
+		if trunked != "" && strings.HasPrefix(number, trunked) {
+			number = strings.TrimPrefix(number, "+"+region.CountryCode)
+		}
+		if n, err := phone.Normalize(number, defaultRegion); err == nil {
+			normalized[id] = n
+		} else {
+			unreadable++
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, 0, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	tx, err := db.Begin()
 	if err != nil {
-		return 0, fmt.Errorf("failed to insert entry: %w", err)
+		return 0, 0, fmt.Errorf("failed to normalize phone numbers: %w", err)
+	}
+	defer tx.Rollback()
+	for id, number := range normalized {
+		if _, err := tx.Exec(`UPDATE entry SET phoneNumber = $1 WHERE id = $2`, number, id); err != nil {
+			return 0, 0, fmt.Errorf("failed to normalize phone numbers: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, 0, fmt.Errorf("failed to normalize phone numbers: %w", err)
 	}
+	return len(normalized), unreadable, nil
+}
 
-	return pk, nil
+// insertEntry stores a new walk-in at the back of the queue, setting its ID
+// and QueueOrder. Any AppointmentAt is dropped; only insertAppointment sets it.
+func insertEntry(db *sql.DB, entry *Entry) error {
+	entry.AppointmentAt = nil
+	return insertEntryAt(db, entry)
+}
+
+// insertAppointment stores a new booked or pending appointment with its slot
+func insertAppointment(db *sql.DB, entry *Entry) error {
+	if entry.AppointmentAt == nil {
+		return fmt.Errorf("appointment has no slot")
+	}
+	return insertEntryAt(db, entry)
+}
+
+func insertEntryAt(db *sql.DB, entry *Entry) error {
+	query := `INSERT INTO entry (firstName, lastName, email, phoneNumber, status, joinTime, notificationChannel, notes, serviceType, partySize, appointmentAt, queueOrder)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, ` + nextQueueOrder + `) RETURNING id, queueOrder`
+
+	err := db.QueryRow(query, entry.FirstName, entry.LastName, entry.Email, entry.PhoneNumber, entry.Status, entry.JoinTime, entry.NotificationChannel, entry.Notes, entry.ServiceType, entry.PartySize, entry.AppointmentAt).Scan(&entry.ID, &entry.QueueOrder)
+	if err != nil {
+		return fmt.Errorf("failed to insert entry: %w", err)
+	}
+
+	return nil
+}
+
+// updateStatusFrom changes an entry's status only if it is still from. It
+// returns the status the entry had, which differs from from when nothing was
+// changed, or sql.ErrNoRows when the entry does not exist.
+func updateStatusFrom(db *sql.DB, id int, from, to string) (string, error) {
+	query := `UPDATE entry SET status = $1 WHERE id = $2 AND status = $3`
+	result, err := db.Exec(query, to, id, from)
+	if err != nil {
+		return "", fmt.Errorf("failed to update status: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return from, nil
+	}
+	return getEntryStatus(db, id)
+}
+
+// getEntryStatus returns the stored status of an entry
+func getEntryStatus(db *sql.DB, id int) (string, error) {
+	var status string
+	if err := db.QueryRow(`SELECT status FROM entry WHERE id = $1`, id).Scan(&status); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// updateEntryDetails saves the customer editable fields of an entry
+func updateEntryDetails(db *sql.DB, entry Entry) error {
+	query := `UPDATE entry SET firstName = $1, lastName = $2, email = $3, notes = $4, partySize = $5 WHERE id = $6`
+	_, err := db.Exec(query, entry.FirstName, entry.LastName, entry.Email, entry.Notes, entry.PartySize, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update entry: %w", err)
+	}
+	return nil
+}
+
+func updateQueueOrder(db *sql.DB, entry Entry) error {
+	query := `UPDATE entry SET queueOrder = $1 WHERE id = $2`
+	_, err := db.Exec(query, entry.QueueOrder, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update queue order: %w", err)
+	}
+	return nil
+}
+
+// renumberQueue saves new ordering keys for a whole queue at once
+func renumberQueue(db *sql.DB, entries []Entry) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to renumber queue: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, entry := range entries {
+		if _, err := tx.Exec(`UPDATE entry SET queueOrder = $1 WHERE id = $2`, entry.QueueOrder, entry.ID); err != nil {
+			return fmt.Errorf("failed to renumber queue: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// addSkips records that the parties with the given IDs were passed over once more
+func addSkips(db *sql.DB, ids []int) error {
+	_, err := db.Exec(`UPDATE entry SET skips = skips + 1 WHERE id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("failed to record skipped parties: %w", err)
+	}
+	return nil
+}
+
+// updateCallFrom saves an entry's status, call time and counter together,
+// only if its status is still from. It returns the status the entry had,
+// which differs from from when nothing was changed.
+func updateCallFrom(db *sql.DB, entry Entry, from string) (string, error) {
+	query := `UPDATE entry SET status = $1, calledAt = $2, counterId = $3, counterName = $4 WHERE id = $5 AND status = $6`
+	result, err := db.Exec(query, entry.Status, entry.CalledAt, entry.CounterID, entry.Counter, entry.ID, from)
+	if err != nil {
+		return "", fmt.Errorf("failed to update entry call: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return from, nil
+	}
+	return getEntryStatus(db, entry.ID)
+}
+
+func updateConfirmedAt(db *sql.DB, entry Entry) error {
+	query := `UPDATE entry SET confirmedAt = $1 WHERE id = $2`
+	_, err := db.Exec(query, entry.ConfirmedAt, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update confirmation: %w", err)
+	}
+	return nil
+}
+
+// getActiveEntryByPhone returns the most recent waitlisted, waiting or notified entry for a phone number
+func getActiveEntryByPhone(db *sql.DB, phoneNumber string) (Entry, error) {
+	return getLatestEntryByPhone(db, phoneNumber, StatusWaitlisted, StatusWaiting, StatusNotified)
+}
+
+// getRecoverableEntryByPhone returns the most recent entry for a phone number
+// that session recovery may issue a token for
+func getRecoverableEntryByPhone(db *sql.DB, phoneNumber string) (Entry, error) {
+	return getLatestEntryByPhone(db, phoneNumber, queueing.RecoverableStatuses...)
+}
+
+// getLatestEntryByPhone returns a phone's most recent entry in the given statuses
+func getLatestEntryByPhone(db *sql.DB, phoneNumber string, statuses ...string) (Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM entry WHERE phoneNumber = $1 AND status = ANY($2) ORDER BY joinTime DESC LIMIT 1`
+	entry, err := scanEntry(db.QueryRow(query, phoneNumber, pq.Array(statuses)))
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to get entry by phone: %w", err)
+	}
+	return entry, nil
+}
+
+// getActiveEntriesByPhone returns every waitlisted, waiting or notified entry for a phone number, oldest first
+func getActiveEntriesByPhone(db *sql.DB, phoneNumber string) ([]Entry, error) {
+	return getEntriesByPhone(db, phoneNumber, StatusWaitlisted, StatusWaiting, StatusNotified)
+}
+
+// getEntriesByPhone returns a phone's entries in the given statuses, oldest first
+func getEntriesByPhone(db *sql.DB, phoneNumber string, statuses ...string) ([]Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM entry WHERE phoneNumber = $1 AND status = ANY($2) ORDER BY joinTime`
+	rows, err := db.Query(query, phoneNumber, pq.Array(statuses))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query entries by phone: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return entries, nil
+}
+
+func countWaitlisted(db *sql.DB) (int, error) {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM entry WHERE status = $1`, StatusWaitlisted).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count waitlisted entries: %w", err)
+	}
+	return count, nil
+}
+
+// getFirstWaitlisted returns the entry that has been waitlisted longest, or
+// sql.ErrNoRows if there is none
+func getFirstWaitlisted(db *sql.DB) (Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM entry WHERE status = $1 ORDER BY queueOrder, id LIMIT 1`
+	return scanEntry(db.QueryRow(query, StatusWaitlisted))
+}
+
+// waitlistPosition returns the 1-based place of an entry on the waitlist
+func waitlistPosition(db *sql.DB, entry Entry) (int, error) {
+	query := `SELECT COUNT(*) FROM entry WHERE status = $1 AND (queueOrder < $2 OR queueOrder = $2 AND id <= $3)`
+	var position int
+	if err := db.QueryRow(query, StatusWaitlisted, entry.QueueOrder, entry.ID).Scan(&position); err != nil {
+		return 0, fmt.Errorf("failed to get waitlist position: %w", err)
+	}
+	return position, nil
+}
+
+// countAppointments returns how many appointments, other than cancelled ones,
+// are booked for the slot starting at
+func countAppointments(db *sql.DB, at time.Time) (int, error) {
+	var count int
+	// Pending bookings hold no slot until they verify
+	query := `SELECT COUNT(*) FROM entry WHERE appointmentAt = $1 AND status NOT IN ($2, $3)`
+	if err := db.QueryRow(query, at, StatusCancelled, StatusPending).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to count appointments: %w", err)
+	}
+	return count, nil
+}
+
+// getDueAppointments returns the booked entries whose slot started by now,
+// earliest first
+func getDueAppointments(db *sql.DB, now time.Time) ([]Entry, error) {
+	query := `SELECT ` + entryColumns + ` FROM entry WHERE status = $1 AND appointmentAt <= $2 ORDER BY appointmentAt, id`
+	rows, err := db.Query(query, StatusBooked, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query due appointments: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return entries, nil
+}
+
+func updateCheckedIn(db *sql.DB, entry Entry) error {
+	query := `UPDATE entry SET checkedInAt = $1 WHERE id = $2`
+	_, err := db.Exec(query, entry.CheckedInAt, entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to update check-in: %w", err)
+	}
+	return nil
 }
 
-func updateStatusByEntry(db *sql.DB, entry Entry) error {
-	query := `UPDATE entry SET status = $1 WHERE id = $2`
-	_, err := db.Exec(query, entry.Status, entry.ID)
+func updateArrivedAt(db *sql.DB, entry Entry) error {
+	query := `UPDATE entry SET arrivedAt = $1 WHERE id = $2`
+	_, err := db.Exec(query, entry.ArrivedAt, entry.ID)
 	if err != nil {
-		return fmt.Errorf("failed to update status: %w", err)
+		return fmt.Errorf("failed to update arrival: %w", err)
 	}
 	return nil
 }
@@ -53,7 +411,7 @@ func updateStatusByEntry(db *sql.DB, entry Entry) error {
 func getWaitingEntry(db *sql.DB) ([]Entry, error) {
 	var entries []Entry
 
-	query := `SELECT id, firstName, lastName, email, phoneNumber, status, joinTime FROM entry WHERE status = 'waiting'`
+	query := `SELECT ` + entryColumns + ` FROM entry WHERE status = 'waiting' ORDER BY queueOrder, id`
 	rows, err := db.Query(query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query waiting entries: %w", err)
@@ -61,16 +419,12 @@ func getWaitingEntry(db *sql.DB) ([]Entry, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int
-		var firstName, lastName, email, phoneNumber, status string
-		var joinTime time.Time
-
-		err := rows.Scan(&id, &firstName, &lastName, &email, &phoneNumber, &status, &joinTime)
+		entry, err := scanEntry(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		entries = append(entries, Entry{id, firstName, lastName, email, phoneNumber, status, joinTime})
+		entries = append(entries, entry)
 	}
 
 	if err = rows.Err(); err != nil {
@@ -80,10 +434,82 @@ func getWaitingEntry(db *sql.DB) ([]Entry, error) {
 	return entries, nil
 }
 
+// listEntries returns one page of entries matching filter, with the total
+// number of matches across all pages
+func listEntries(db *sql.DB, filter EntryFilter) (EntryPage, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	conditions = append(conditions, `status = ANY(`+arg(pq.Array(filter.Statuses))+`)`)
+	if filter.Search != "" {
+		pattern := arg("%" + escapeLike(filter.Search) + "%")
+		conditions = append(conditions, `(firstName || ' ' || lastName ILIKE `+pattern+
+			` OR phoneNumber ILIKE `+pattern+` OR email ILIKE `+pattern+`)`)
+	}
+	if len(filter.ServiceTypes) > 0 {
+		conditions = append(conditions, `serviceType = ANY(`+arg(pq.Array(filter.ServiceTypes))+`)`)
+	}
+	if !filter.JoinedFrom.IsZero() {
+		conditions = append(conditions, `joinTime >= `+arg(filter.JoinedFrom))
+	}
+	if !filter.JoinedTo.IsZero() {
+		conditions = append(conditions, `joinTime < `+arg(filter.JoinedTo))
+	}
+	where := ` WHERE ` + strings.Join(conditions, ` AND `)
+
+	var total int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM entry`+where, args...).Scan(&total); err != nil {
+		return EntryPage{}, fmt.Errorf("failed to count entries: %w", err)
+	}
+
+	// filter.Sort is a column name, never user input
+	order, compare := "ASC", ">"
+	if filter.Descending {
+		order, compare = "DESC", "<"
+	}
+	if filter.After != nil {
+		value, err := filter.CursorValue()
+		if err != nil {
+			return EntryPage{}, err
+		}
+		where += ` AND (` + filter.Sort + `, id) ` + compare + ` (` + arg(value) + `, ` + arg(filter.After.ID) + `)`
+	}
+
+	query := `SELECT ` + entryColumns + ` FROM entry` + where +
+		` ORDER BY ` + filter.Sort + ` ` + order + `, id ` + order + ` LIMIT ` + arg(filter.Limit+1)
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return EntryPage{}, fmt.Errorf("failed to query entries: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return EntryPage{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		return EntryPage{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return filter.Page(entries, total), nil
+}
+
+// escapeLike makes a search term match literally in a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
 func backupHistory(db *sql.DB, historyList *[]Entry) error {
 	for _, entry := range *historyList {
-		_, err := insertEntry(db, entry)
-		if err != nil {
+		if err := insertEntryAt(db, &entry); err != nil {
 			return fmt.Errorf("failed to backup history entry: %w", err)
 		}
 	}
@@ -93,28 +519,759 @@ func backupHistory(db *sql.DB, historyList *[]Entry) error {
 }
 
 func getEntryByID(db *sql.DB, id int) (Entry, error) {
-	query := `SELECT id, firstName, lastName, email, phoneNumber, status, joinTime FROM entry WHERE id = $1`
-	var entry Entry
-	err := db.QueryRow(query, id).Scan(
-		&entry.ID,
-		&entry.FirstName,
-		&entry.LastName,
-		&entry.Email,
-		&entry.PhoneNumber,
-		&entry.Status,
-		&entry.JoinTime,
-	)
+	query := `SELECT ` + entryColumns + ` FROM entry WHERE id = $1`
+	entry, err := scanEntry(db.QueryRow(query, id))
 	if err != nil {
 		return Entry{}, fmt.Errorf("failed to get entry: %w", err)
 	}
 	return entry, nil
 }
 
-func clearQueue(db *sql.DB) error {
-	query := `UPDATE entry SET status = $1 WHERE status = $2`
-	_, err := db.Exec(query, StatusServed, StatusWaiting)
+// updateStatusesTo moves every entry with one of statuses to status to and
+// returns the changed entries
+func updateStatusesTo(db *sql.DB, statuses []string, to string) ([]Entry, error) {
+	query := `UPDATE entry SET status = $1 WHERE status = ANY($2) RETURNING ` + entryColumns
+	rows, err := db.Query(query, to, pq.Array(statuses))
+	if err != nil {
+		return nil, fmt.Errorf("failed to update statuses: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return entries, nil
+}
+
+func createReminderTable(db *sql.DB) error {
+	query := `CREATE TABLE IF NOT EXISTS reminder_sent (
+		entry_id INTEGER NOT NULL REFERENCES entry(id),
+		rule VARCHAR(50) NOT NULL,
+		sentAt timestamp DEFAULT NOW(),
+		PRIMARY KEY (entry_id, rule)
+	)`
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create reminder table: %w", err)
+	}
+	return nil
+}
+
+// claimReminder records that a rule fired for an entry. It returns false when the
+// reminder was already recorded, so each rule is delivered at most once per entry.
+func claimReminder(db *sql.DB, entryID int, rule string) (bool, error) {
+	query := `INSERT INTO reminder_sent (entry_id, rule) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+	result, err := db.Exec(query, entryID, rule)
+	if err != nil {
+		return false, fmt.Errorf("failed to record reminder: %w", err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to record reminder: %w", err)
+	}
+	return n == 1, nil
+}
+
+func createWebhookTables(db *sql.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS webhook (
+			id SERIAL PRIMARY KEY,
+			url VARCHAR(500) NOT NULL,
+			secret VARCHAR(100) NOT NULL,
+			events VARCHAR(500) NOT NULL DEFAULT '',
+			active BOOLEAN NOT NULL DEFAULT TRUE,
+			createdAt timestamp DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS webhook_delivery (
+			id SERIAL PRIMARY KEY,
+			webhook_id INTEGER NOT NULL REFERENCES webhook(id) ON DELETE CASCADE,
+			event VARCHAR(50) NOT NULL,
+			payload TEXT NOT NULL,
+			attempt INTEGER NOT NULL,
+			statusCode INTEGER NOT NULL DEFAULT 0,
+			success BOOLEAN NOT NULL,
+			error TEXT NOT NULL DEFAULT '',
+			createdAt timestamp DEFAULT NOW()
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create webhook tables: %w", err)
+		}
+	}
+	return nil
+}
+
+func insertWebhook(db *sql.DB, hook Webhook) (int, error) {
+	query := `INSERT INTO webhook (url, secret, events, active, createdAt) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+
+	var pk int
+	err := db.QueryRow(query, hook.URL, hook.Secret, strings.Join(hook.Events, ","), hook.Active, hook.CreatedAt).Scan(&pk)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert webhook: %w", err)
+	}
+	return pk, nil
+}
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var hook Webhook
+	var events string
+	err := row.Scan(&hook.ID, &hook.URL, &hook.Secret, &events, &hook.Active, &hook.CreatedAt)
+	if events != "" {
+		hook.Events = strings.Split(events, ",")
+	}
+	return hook, err
+}
+
+func getWebhooks(db *sql.DB, activeOnly bool) ([]Webhook, error) {
+	query := `SELECT id, url, secret, events, active, createdAt FROM webhook`
+	if activeOnly {
+		query += ` WHERE active`
+	}
+	query += ` ORDER BY id`
+
+	rows, err := db.Query(query)
 	if err != nil {
-		return fmt.Errorf("failed to clear queue: %w", err)
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	hooks := []Webhook{}
+	for rows.Next() {
+		hook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		hooks = append(hooks, hook)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return hooks, nil
+}
+
+func getWebhookByID(db *sql.DB, id int) (Webhook, error) {
+	query := `SELECT id, url, secret, events, active, createdAt FROM webhook WHERE id = $1`
+	hook, err := scanWebhook(db.QueryRow(query, id))
+	if err != nil {
+		return Webhook{}, fmt.Errorf("failed to get webhook: %w", err)
+	}
+	return hook, nil
+}
+
+func deleteWebhook(db *sql.DB, id int) error {
+	result, err := db.Exec(`DELETE FROM webhook WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func insertWebhookDelivery(db *sql.DB, delivery WebhookDelivery) error {
+	query := `INSERT INTO webhook_delivery (webhook_id, event, payload, attempt, statusCode, success, error, createdAt) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+	_, err := db.Exec(query, delivery.WebhookID, delivery.Event, delivery.Payload, delivery.Attempt, delivery.StatusCode, delivery.Success, delivery.Error, delivery.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+	return nil
+}
+
+func getWebhookDeliveries(db *sql.DB, webhookID int, limit int) ([]WebhookDelivery, error) {
+	query := `SELECT id, webhook_id, event, payload, attempt, statusCode, success, error, createdAt FROM webhook_delivery WHERE webhook_id = $1 ORDER BY id DESC LIMIT $2`
+	rows, err := db.Query(query, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []WebhookDelivery{}
+	for rows.Next() {
+		var d WebhookDelivery
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.Event, &d.Payload, &d.Attempt, &d.StatusCode, &d.Success, &d.Error, &d.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return deliveries, nil
+}
+
+func createBlocklistTable(db *sql.DB) error {
+	query := `CREATE TABLE IF NOT EXISTS blocklist (
+		id SERIAL PRIMARY KEY,
+		kind VARCHAR(10) NOT NULL,
+		value VARCHAR(64) NOT NULL,
+		reason VARCHAR(200) NOT NULL DEFAULT '',
+		createdAt timestamp DEFAULT NOW(),
+		UNIQUE (kind, value)
+	)`
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create blocklist table: %w", err)
+	}
+	return nil
+}
+
+func insertBlocked(db *sql.DB, blocked Blocked) (int, error) {
+	query := `INSERT INTO blocklist (kind, value, reason, createdAt) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (kind, value) DO UPDATE SET reason = EXCLUDED.reason RETURNING id`
+
+	var pk int
+	err := db.QueryRow(query, blocked.Kind, blocked.Value, blocked.Reason, blocked.CreatedAt).Scan(&pk)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert blocklist entry: %w", err)
+	}
+	return pk, nil
+}
+
+func getBlocklist(db *sql.DB) ([]Blocked, error) {
+	rows, err := db.Query(`SELECT id, kind, value, reason, createdAt FROM blocklist ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query blocklist: %w", err)
+	}
+	defer rows.Close()
+
+	blocklist := []Blocked{}
+	for rows.Next() {
+		var b Blocked
+		if err := rows.Scan(&b.ID, &b.Kind, &b.Value, &b.Reason, &b.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		blocklist = append(blocklist, b)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return blocklist, nil
+}
+
+func deleteBlocked(db *sql.DB, id int) error {
+	result, err := db.Exec(`DELETE FROM blocklist WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete blocklist entry: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func isBlocked(db *sql.DB, kind, value string) (bool, error) {
+	var blocked bool
+	err := db.QueryRow(`SELECT EXISTS (SELECT 1 FROM blocklist WHERE kind = $1 AND value = $2)`, kind, value).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed to check blocklist: %w", err)
+	}
+	return blocked, nil
+}
+
+func createOTPTable(db *sql.DB) error {
+	query := `CREATE TABLE IF NOT EXISTS otp_code (
+		purpose VARCHAR(20) NOT NULL,
+		subject VARCHAR(64) NOT NULL,
+		codeHash VARCHAR(64) NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		expiresAt timestamp NOT NULL,
+		PRIMARY KEY (purpose, subject)
+	)`
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create otp table: %w", err)
+	}
+	return nil
+}
+
+// saveOTP stores a code, replacing any earlier code for the same purpose and subject
+func saveOTP(db *sql.DB, otp OTPCode) error {
+	query := `INSERT INTO otp_code (purpose, subject, codeHash, attempts, expiresAt) VALUES ($1, $2, $3, 0, $4)
+		ON CONFLICT (purpose, subject) DO UPDATE SET codeHash = EXCLUDED.codeHash, attempts = 0, expiresAt = EXCLUDED.expiresAt`
+	_, err := db.Exec(query, otp.Purpose, otp.Subject, otp.CodeHash, otp.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save otp: %w", err)
+	}
+	return nil
+}
+
+func getOTP(db *sql.DB, purpose, subject string) (OTPCode, error) {
+	query := `SELECT purpose, subject, codeHash, attempts, expiresAt FROM otp_code WHERE purpose = $1 AND subject = $2`
+	var otp OTPCode
+	err := db.QueryRow(query, purpose, subject).Scan(&otp.Purpose, &otp.Subject, &otp.CodeHash, &otp.Attempts, &otp.ExpiresAt)
+	if err != nil {
+		return OTPCode{}, fmt.Errorf("failed to get otp: %w", err)
+	}
+	return otp, nil
+}
+
+// claimOTPAttempt counts an attempt and returns the code with the new count,
+// in one statement so parallel guesses cannot exceed maxAttempts. It returns
+// sql.ErrNoRows when there is no code or no attempts are left.
+func claimOTPAttempt(db *sql.DB, purpose, subject string, maxAttempts int) (OTPCode, error) {
+	query := `UPDATE otp_code SET attempts = attempts + 1
+		WHERE purpose = $1 AND subject = $2 AND attempts < $3
+		RETURNING purpose, subject, codeHash, attempts, expiresAt`
+	var otp OTPCode
+	err := db.QueryRow(query, purpose, subject, maxAttempts).Scan(&otp.Purpose, &otp.Subject, &otp.CodeHash, &otp.Attempts, &otp.ExpiresAt)
+	if err != nil {
+		return OTPCode{}, fmt.Errorf("failed to claim otp attempt: %w", err)
+	}
+	return otp, nil
+}
+
+// deleteOTP removes a code and reports whether it was still there, so only
+// one of several parallel checks can consume it
+func deleteOTP(db *sql.DB, purpose, subject string) (bool, error) {
+	res, err := db.Exec(`DELETE FROM otp_code WHERE purpose = $1 AND subject = $2`, purpose, subject)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete otp: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete otp: %w", err)
+	}
+	return n > 0, nil
+}
+
+// updateStatusToBack moves an entry from status from to status to at the back
+// of the queue, returning its new QueueOrder. A nil joinTime keeps the join
+// time. It returns sql.ErrNoRows if the entry no longer has status from.
+func updateStatusToBack(db *sql.DB, id int, from, to string, joinTime *time.Time) (int64, error) {
+	query := `UPDATE entry SET status = $1, joinTime = COALESCE($2, joinTime), queueOrder = ` + nextQueueOrder + `
+		WHERE id = $3 AND status = $4 RETURNING queueOrder`
+	var order int64
+	err := db.QueryRow(query, to, joinTime, id, from).Scan(&order)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to update entry: %w", err)
+	}
+	return order, nil
+}
+
+func createHistoryTable(db *sql.DB) error {
+	query := `CREATE TABLE IF NOT EXISTS entry_history (
+		id SERIAL PRIMARY KEY,
+		entry_id INTEGER NOT NULL REFERENCES entry(id),
+		action VARCHAR(30) NOT NULL,
+		actor VARCHAR(30) NOT NULL,
+		details TEXT NOT NULL DEFAULT '{}',
+		createdAt timestamp DEFAULT NOW()
+	)`
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create history table: %w", err)
+	}
+	return nil
+}
+
+func insertHistory(db *sql.DB, record HistoryRecord) error {
+	query := `INSERT INTO entry_history (entry_id, action, actor, details, createdAt) VALUES ($1, $2, $3, $4, $5)`
+	_, err := db.Exec(query, record.EntryID, record.Action, record.Actor, string(record.Details), record.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert history: %w", err)
+	}
+	return nil
+}
+
+func createIdempotencyTable(db *sql.DB) error {
+	query := `CREATE TABLE IF NOT EXISTS idempotency_key (
+		scope VARCHAR(300) NOT NULL,
+		key VARCHAR(255) NOT NULL,
+		requestHash VARCHAR(64) NOT NULL,
+		statusCode INTEGER NOT NULL DEFAULT 0,
+		contentType VARCHAR(100) NOT NULL DEFAULT '',
+		body BYTEA,
+		createdAt timestamp NOT NULL,
+		PRIMARY KEY (scope, key)
+	)`
+
+	if _, err := db.Exec(query); err != nil {
+		return fmt.Errorf("failed to create idempotency table: %w", err)
+	}
+	if _, err := db.Exec(`CREATE INDEX IF NOT EXISTS idempotency_key_created ON idempotency_key (createdAt)`); err != nil {
+		return fmt.Errorf("failed to create idempotency index: %w", err)
+	}
+	return nil
+}
+
+// claimIdempotencyKey records that a request with this key has started. When
+// the key is already taken it returns false and the stored row. Keys created
+// before expiredBefore are purged first, so they can be used again.
+func claimIdempotencyKey(db *sql.DB, claim idempotency.Response, expiredBefore time.Time) (bool, idempotency.Response, error) {
+	if _, err := db.Exec(`DELETE FROM idempotency_key WHERE createdAt < $1`, expiredBefore); err != nil {
+		return false, idempotency.Response{}, fmt.Errorf("failed to purge idempotency keys: %w", err)
+	}
+
+	query := `INSERT INTO idempotency_key (scope, key, requestHash, createdAt) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (scope, key) DO NOTHING`
+	result, err := db.Exec(query, claim.Scope, claim.Key, claim.RequestHash, claim.CreatedAt)
+	if err != nil {
+		return false, idempotency.Response{}, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 1 {
+		return true, claim, nil
+	}
+
+	existing := idempotency.Response{Key: claim.Key, Scope: claim.Scope}
+	query = `SELECT requestHash, statusCode, contentType, body, createdAt FROM idempotency_key WHERE scope = $1 AND key = $2`
+	err = db.QueryRow(query, claim.Scope, claim.Key).Scan(&existing.RequestHash, &existing.StatusCode, &existing.ContentType, &existing.Body, &existing.CreatedAt)
+	if err != nil {
+		return false, idempotency.Response{}, fmt.Errorf("failed to get idempotency key: %w", err)
+	}
+	return false, existing, nil
+}
+
+// saveIdempotentResponse stores the response for a claimed key
+func saveIdempotentResponse(db *sql.DB, response idempotency.Response) error {
+	query := `UPDATE idempotency_key SET statusCode = $1, contentType = $2, body = $3 WHERE scope = $4 AND key = $5`
+	if _, err := db.Exec(query, response.StatusCode, response.ContentType, response.Body, response.Scope, response.Key); err != nil {
+		return fmt.Errorf("failed to save idempotent response: %w", err)
+	}
+	return nil
+}
+
+// releaseIdempotencyKey forgets a claimed key so the request can be retried
+func releaseIdempotencyKey(db *sql.DB, scope, key string) error {
+	if _, err := db.Exec(`DELETE FROM idempotency_key WHERE scope = $1 AND key = $2`, scope, key); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func createQueueSettingsTables(db *sql.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS queue_settings (
+			queueId VARCHAR(20) PRIMARY KEY,
+			state VARCHAR(10) NOT NULL DEFAULT 'open',
+			message VARCHAR(200) NOT NULL DEFAULT '',
+			changedAt timestamp DEFAULT NOW(),
+			schedule TEXT NOT NULL DEFAULT '',
+			scheduledState VARCHAR(10) NOT NULL DEFAULT ''
+		)`,
+		`INSERT INTO queue_settings (queueId) VALUES ('` + DefaultQueueID + `') ON CONFLICT DO NOTHING`,
+		`CREATE TABLE IF NOT EXISTS queue_holiday (
+			day DATE PRIMARY KEY,
+			reason VARCHAR(200) NOT NULL DEFAULT ''
+		)`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create queue settings tables: %w", err)
+		}
+	}
+	return nil
+}
+
+func getQueueSettings(db *sql.DB) (QueueSettings, error) {
+	var settings QueueSettings
+	var schedule string
+	query := `SELECT state, message, changedAt, schedule, scheduledState FROM queue_settings WHERE queueId = $1`
+	err := db.QueryRow(query, DefaultQueueID).Scan(&settings.State, &settings.Message, &settings.ChangedAt, &schedule, &settings.ScheduledState)
+	if err != nil {
+		return QueueSettings{}, fmt.Errorf("failed to get queue settings: %w", err)
+	}
+
+	if schedule != "" {
+		settings.Schedule = &Schedule{}
+		if err := json.Unmarshal([]byte(schedule), settings.Schedule); err != nil {
+			return QueueSettings{}, fmt.Errorf("failed to read queue schedule: %w", err)
+		}
+	}
+	return settings, nil
+}
+
+func setQueueState(db *sql.DB, state, message string, at time.Time) error {
+	query := `UPDATE queue_settings SET state = $1, message = $2, changedAt = $3 WHERE queueId = $4`
+	if _, err := db.Exec(query, state, message, at, DefaultQueueID); err != nil {
+		return fmt.Errorf("failed to set queue state: %w", err)
+	}
+	return nil
+}
+
+// setScheduledState records the state the schedule last applied
+func setScheduledState(db *sql.DB, state string) error {
+	query := `UPDATE queue_settings SET scheduledState = $1 WHERE queueId = $2`
+	if _, err := db.Exec(query, state, DefaultQueueID); err != nil {
+		return fmt.Errorf("failed to set scheduled state: %w", err)
+	}
+	return nil
+}
+
+// saveSchedule replaces the schedule, or removes it when schedule is nil. The
+// scheduler then applies the new schedule's current state on its next check.
+func saveSchedule(db *sql.DB, schedule *Schedule) error {
+	var value string
+	if schedule != nil {
+		b, err := json.Marshal(schedule)
+		if err != nil {
+			return fmt.Errorf("failed to save schedule: %w", err)
+		}
+		value = string(b)
+	}
+
+	query := `UPDATE queue_settings SET schedule = $1, scheduledState = '' WHERE queueId = $2`
+	if _, err := db.Exec(query, value, DefaultQueueID); err != nil {
+		return fmt.Errorf("failed to save schedule: %w", err)
+	}
+	return nil
+}
+
+func getHolidays(db *sql.DB) ([]Holiday, error) {
+	rows, err := db.Query(`SELECT to_char(day, 'YYYY-MM-DD'), reason FROM queue_holiday ORDER BY day`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query holidays: %w", err)
+	}
+	defer rows.Close()
+
+	holidays := []Holiday{}
+	for rows.Next() {
+		var h Holiday
+		if err := rows.Scan(&h.Date, &h.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		holidays = append(holidays, h)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return holidays, nil
+}
+
+func insertHoliday(db *sql.DB, holiday Holiday) error {
+	query := `INSERT INTO queue_holiday (day, reason) VALUES ($1, $2) ON CONFLICT (day) DO UPDATE SET reason = EXCLUDED.reason`
+	if _, err := db.Exec(query, holiday.Date, holiday.Reason); err != nil {
+		return fmt.Errorf("failed to insert holiday: %w", err)
+	}
+	return nil
+}
+
+func deleteHoliday(db *sql.DB, date string) error {
+	result, err := db.Exec(`DELETE FROM queue_holiday WHERE day = $1`, date)
+	if err != nil {
+		return fmt.Errorf("failed to delete holiday: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func createCounterTable(db *sql.DB) error {
+	queries := []string{
+		`CREATE TABLE IF NOT EXISTS counter (
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(50) NOT NULL UNIQUE,
+			claimedBy VARCHAR(64),
+			claimedAt timestamp,
+			createdAt timestamp DEFAULT NOW()
+		)`,
+		`ALTER TABLE counter ADD COLUMN IF NOT EXISTS skills VARCHAR(500) NOT NULL DEFAULT ''`,
+		`ALTER TABLE counter ADD COLUMN IF NOT EXISTS overflow BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE counter ADD COLUMN IF NOT EXISTS seats INTEGER NOT NULL DEFAULT 0`,
+	}
+
+	for _, query := range queries {
+		if _, err := db.Exec(query); err != nil {
+			return fmt.Errorf("failed to create counter table: %w", err)
+		}
+	}
+	return nil
+}
+
+const counterColumns = `id, name, skills, overflow, seats, claimedBy, claimedAt, createdAt`
+
+func scanCounter(row rowScanner) (Counter, error) {
+	var counter Counter
+	var skills string
+	var claimedBy sql.NullString
+	err := row.Scan(&counter.ID, &counter.Name, &skills, &counter.Overflow, &counter.Seats, &claimedBy, &counter.ClaimedAt, &counter.CreatedAt)
+	counter.Skills = []string{}
+	if skills != "" {
+		counter.Skills = strings.Split(skills, ",")
+	}
+	counter.ClaimedBy = claimedBy.String
+	counter.Claimed = claimedBy.Valid
+	return counter, err
+}
+
+// insertCounter returns ErrDuplicateCounter if the name is taken
+func insertCounter(db *sql.DB, counter Counter) (int, error) {
+	query := `INSERT INTO counter (name, skills, overflow, seats, createdAt) VALUES ($1, $2, $3, $4, $5) ON CONFLICT (name) DO NOTHING RETURNING id`
+
+	var pk int
+	err := db.QueryRow(query, counter.Name, strings.Join(counter.Skills, ","), counter.Overflow, counter.Seats, counter.CreatedAt).Scan(&pk)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrDuplicateCounter
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert counter: %w", err)
+	}
+	return pk, nil
+}
+
+func getCounters(db *sql.DB) ([]Counter, error) {
+	rows, err := db.Query(`SELECT ` + counterColumns + ` FROM counter ORDER BY name, id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query counters: %w", err)
+	}
+	defer rows.Close()
+
+	counters := []Counter{}
+	for rows.Next() {
+		counter, err := scanCounter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		counters = append(counters, counter)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return counters, nil
+}
+
+func getCounterByID(db *sql.DB, id int) (Counter, error) {
+	return scanCounter(db.QueryRow(`SELECT `+counterColumns+` FROM counter WHERE id = $1`, id))
+}
+
+// updateCounter saves a counter's settings, returning ErrDuplicateCounter if
+// the new name is taken
+func updateCounter(db *sql.DB, counter Counter) error {
+	query := `UPDATE counter SET name = $1, skills = $2, overflow = $3, seats = $4 WHERE id = $5`
+	_, err := db.Exec(query, counter.Name, strings.Join(counter.Skills, ","), counter.Overflow, counter.Seats, counter.ID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrDuplicateCounter
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update counter: %w", err)
+	}
+	return nil
+}
+
+// getCounterByOperator returns sql.ErrNoRows if the operator holds no counter
+func getCounterByOperator(db *sql.DB, operator string) (Counter, error) {
+	return scanCounter(db.QueryRow(`SELECT `+counterColumns+` FROM counter WHERE claimedBy = $1`, operator))
+}
+
+// getCounterCustomers maps each counter to the notified entry it called most recently
+func getCounterCustomers(db *sql.DB) (map[int]Entry, error) {
+	query := `SELECT DISTINCT ON (counterId) ` + entryColumns + ` FROM entry
+		WHERE status = 'notified' AND counterId IS NOT NULL ORDER BY counterId, calledAt DESC`
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query counter customers: %w", err)
+	}
+	defer rows.Close()
+
+	current := map[int]Entry{}
+	for rows.Next() {
+		entry, err := scanEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		current[*entry.CounterID] = entry
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return current, nil
+}
+
+// claimCounter gives a counter to an operator and frees any other counter
+// they held. It returns ErrCounterClaimed if someone else holds it.
+func claimCounter(db *sql.DB, id int, operator string, now time.Time) (Counter, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return Counter{}, fmt.Errorf("failed to claim counter: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the counter and any the operator already holds in id order first,
+	// so two claims by the same operator cannot deadlock
+	rows, err := tx.Query(`SELECT `+counterColumns+` FROM counter WHERE id = $1 OR claimedBy = $2 ORDER BY id FOR UPDATE`, id, operator)
+	if err != nil {
+		return Counter{}, fmt.Errorf("failed to claim counter: %w", err)
+	}
+	var locked []Counter
+	for rows.Next() {
+		counter, err := scanCounter(rows)
+		if err != nil {
+			rows.Close()
+			return Counter{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		locked = append(locked, counter)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return Counter{}, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	counter, release, err := queueing.Claim(locked, id, operator, now)
+	if errors.Is(err, queueing.ErrCounterNotFound) {
+		return Counter{}, sql.ErrNoRows
+	}
+	if err != nil {
+		return Counter{}, err
+	}
+
+	if _, err := tx.Exec(`UPDATE counter SET claimedBy = $1, claimedAt = $2 WHERE id = $3`, operator, now, id); err != nil {
+		return Counter{}, fmt.Errorf("failed to claim counter: %w", err)
+	}
+	if len(release) > 0 {
+		if _, err := tx.Exec(`UPDATE counter SET claimedBy = NULL, claimedAt = NULL WHERE id = ANY($1)`, pq.Array(release)); err != nil {
+			return Counter{}, fmt.Errorf("failed to claim counter: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return Counter{}, fmt.Errorf("failed to claim counter: %w", err)
+	}
+	return counter, nil
+}
+
+func releaseCounter(db *sql.DB, id int) (Counter, error) {
+	query := `UPDATE counter SET claimedBy = NULL, claimedAt = NULL WHERE id = $1 RETURNING ` + counterColumns
+	counter, err := scanCounter(db.QueryRow(query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Counter{}, err
+	}
+	if err != nil {
+		return Counter{}, fmt.Errorf("failed to release counter: %w", err)
+	}
+	return counter, nil
+}
+
+func deleteCounter(db *sql.DB, id int) error {
+	result, err := db.Exec(`DELETE FROM counter WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete counter: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
 	}
 	return nil
 }
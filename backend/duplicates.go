@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/queueing"
+)
+
+// The duplicate join policy lives in queueing
+type DuplicatePolicy = queueing.DuplicatePolicy
+
+const (
+	DuplicateReject = queueing.DuplicateReject
+	DuplicateReturn = queueing.DuplicateReturn
+	DuplicateAllow  = queueing.DuplicateAllow
+)
+
+// handleDuplicateJoin answers a /join or booking for a phone that already has
+// an active entry. Only a caller who has verified the phone gets a token for
+// it; anyone else could be typing in someone else's number, so under
+// DuplicateReturn they are texted a recovery code when PHONE_VERIFICATION is
+// on, or pointed to session recovery when it is off.
+func (a *App) handleDuplicateJoin(w http.ResponseWriter, r *http.Request, existing Entry, verified bool) {
+	a.queueMu.Lock()
+	position := queuePosition(*a.queue, existing)
+	a.queueMu.Unlock()
+
+	if a.duplicatePolicy.Mode != DuplicateReturn {
+		apierror.ErrorWithDetails(w, r, apierror.CodeDuplicateEntry,
+			"This phone number is already in the queue", http.StatusConflict,
+			map[string]string{
+				"id":       strconv.Itoa(existing.ID),
+				"position": strconv.Itoa(position),
+			})
+		return
+	}
+
+	response := map[string]interface{}{
+		"status":   "existing",
+		"id":       existing.ID,
+		"position": position,
+	}
+	status := http.StatusOK
+
+	switch {
+	case verified:
+		token, err := auth.GenerateToken(existing.ID, existing.PhoneNumber)
+		if err != nil {
+			apierror.Error(w, r, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+		response["token"] = token
+	case a.otp.VerifyPhone:
+		if err := a.sendRecoveryCode(existing); err != nil {
+			log.Printf("Warning: %v", err)
+			apierror.Error(w, r, "Failed to send recovery code", http.StatusInternalServerError)
+			return
+		}
+		// The token comes from POST /api/v1/recovery:verify with the code
+		response["expiresIn"] = int(a.otp.TTL.Seconds())
+		status = http.StatusAccepted
+	default:
+		// The customer gets back to their entry with POST /api/v1/recovery
+		response["recovery"] = "/api/v1/recovery"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Queue event types emitted by the queue operations
+const (
+	EventNotified   = "entry.notified"
+	EventServed     = "entry.served"
+	EventNoShow     = "entry.no_show"
+	EventCancelled  = "entry.cancelled"
+	EventDelayed    = "entry.delayed"
+	EventMoved      = "entry.moved"
+	EventConfirmed  = "entry.confirmed"
+	EventJoined     = "entry.joined"
+	EventBooked     = "entry.booked"
+	EventCheckedIn  = "entry.checked_in"
+	EventArrived    = "entry.arrived"
+	EventWaitlisted = "entry.waitlisted"
+	EventPromoted   = "entry.promoted"
+	EventUpdated    = "entry.updated"
+	EventCleared    = "queue.cleared"
+	EventOpened     = "queue.opened"
+	EventPaused     = "queue.paused"
+	EventClosed     = "queue.closed"
+)
+
+// queueStateEvents maps each queue state to the event announcing it
+var queueStateEvents = map[string]string{
+	QueueOpen:   EventOpened,
+	QueuePaused: EventPaused,
+	QueueClosed: EventClosed,
+}
+
+// QueueEvent describes a change to the queue. Entry events carry the entry;
+// queue level events such as EventCleared carry a count instead.
+type QueueEvent struct {
+	Type  string    `json:"type"`
+	Entry *Entry    `json:"entry,omitempty"`
+	Count int       `json:"count,omitempty"`
+	Time  time.Time `json:"time"`
+}
+
+type EventHandler func(event QueueEvent)
+
+// eventBus fans queue events out to subscribers. Handlers run synchronously on
+// the caller's goroutine, so anything slow should hand off to its own goroutine.
+type eventBus struct {
+	handlers []EventHandler
+	mu       sync.RWMutex
+}
+
+var queueEvents = &eventBus{}
+
+func (b *eventBus) subscribe(handler EventHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers = append(b.handlers, handler)
+}
+
+func (b *eventBus) emit(eventType string, entry Entry) {
+	b.publish(QueueEvent{
+		Type:  eventType,
+		Entry: &entry,
+		Time:  time.Now(),
+	})
+}
+
+func (b *eventBus) publish(event QueueEvent) {
+	b.mu.RLock()
+	handlers := b.handlers
+	b.mu.RUnlock()
+
+	for _, handler := range handlers {
+		handler(event)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+// History actions recorded against an entry
+const (
+	HistoryUpdated  = "updated"
+	HistoryNotified = "notified"
+	HistoryMoved    = "moved"
+)
+
+// Who made a change
+const (
+	ActorCustomer = "customer"
+	ActorAdmin    = "admin"
+)
+
+// HistoryRecord is one audited change to an entry
+type HistoryRecord struct {
+	ID        int             `json:"id"`
+	EntryID   int             `json:"entryId"`
+	Action    string          `json:"action"`
+	Actor     string          `json:"actor"`
+	Details   json.RawMessage `json:"details"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// EntryPatch and the FieldChange history details live in queueing
+type (
+	EntryPatch  = queueing.EntryPatch
+	FieldChange = queueing.FieldChange
+)
+
+// recordHistory stores an audit record; details is encoded as JSON
+func recordHistory(db *sql.DB, entryID int, action, actor string, details any) error {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return fmt.Errorf("failed to encode history details: %w", err)
+	}
+
+	return insertHistory(db, HistoryRecord{
+		EntryID:   entryID,
+		Action:    action,
+		Actor:     actor,
+		Details:   encoded,
+		CreatedAt: time.Now(),
+	})
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"wait-to-go/auth"
+	"wait-to-go/idempotency"
+)
+
+// idempotencyStore keeps idempotency keys in the database
+type idempotencyStore struct {
+	db *sql.DB
+}
+
+func (s idempotencyStore) Claim(claim idempotency.Response, expiredBefore time.Time) (bool, idempotency.Response, error) {
+	return claimIdempotencyKey(s.db, claim, expiredBefore)
+}
+
+func (s idempotencyStore) Save(response idempotency.Response) error {
+	return saveIdempotentResponse(s.db, response)
+}
+
+func (s idempotencyStore) Release(scope, key string) error {
+	return releaseIdempotencyKey(s.db, scope, key)
+}
+
+// idempotent lets requests carrying an Idempotency-Key be retried safely;
+// see idempotency.Middleware
+func (a *App) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	m := &idempotency.Middleware{
+		Store: idempotencyStore{db: a.db},
+		TTL:   a.idempotencyTTL,
+		Scope: idempotencyScope,
+	}
+	return m.Wrap(next)
+}
+
+// idempotencyScope ties a key to the route and to who is calling, so one
+// client cannot replay another's response. Anonymous callers are told apart
+// by IP address.
+func idempotencyScope(r *http.Request) string {
+	caller := r.Header.Get("X-API-Key") + "|" + r.Header.Get(AdminSessionHeader) + "|" + r.Header.Get("Authorization")
+	if caller == "||" {
+		caller = auth.ClientIP(r)
+	}
+	return r.Method + " " + r.URL.Path + " " + hashBytes([]byte(caller))[:16]
+}
+
+func hashBytes(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
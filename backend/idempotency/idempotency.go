@@ -0,0 +1,174 @@
+// Package idempotency lets clients retry mutations safely: repeats of a
+// request with the same Idempotency-Key get the stored response instead of
+// running again.
+package idempotency
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wait-to-go/apierror"
+)
+
+// KeyHeader carries the client's key for a request
+const KeyHeader = "Idempotency-Key"
+
+// ReplayHeader is set on responses replayed from an earlier request
+const ReplayHeader = "Idempotent-Replayed"
+
+const maxKeyLength = 255
+
+// Response is a stored result for an idempotency key. StatusCode is zero
+// while the first request is still being handled.
+type Response struct {
+	Key         string
+	Scope       string
+	RequestHash string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+// Store keeps claimed keys and their responses
+type Store interface {
+	// Claim records that a request with this key has started. When the key is
+	// already taken it returns false and the stored response. Keys created
+	// before expiredBefore are forgotten first, so they can be used again.
+	Claim(claim Response, expiredBefore time.Time) (bool, Response, error)
+	// Save stores the response for a claimed key
+	Save(response Response) error
+	// Release forgets a claimed key so the request can be retried
+	Release(scope, key string) error
+}
+
+// Middleware stores the response of requests carrying an Idempotency-Key and
+// replays it for repeats within TTL. Keys are scoped by Scope, usually the
+// route and the caller's credentials, and a key reused with a different body
+// is rejected.
+type Middleware struct {
+	Store Store
+	TTL   time.Duration
+	Scope func(r *http.Request) string
+}
+
+// Stored reports whether a response with this status is kept for replay.
+// Only successes and validation errors are: they would come out the same if
+// the request ran again. Anything that depends on the moment, such as a full
+// or paused queue, a conflict or rate limiting, is not stored, so a retry
+// runs the request again.
+func Stored(status int) bool {
+	switch {
+	case status >= 200 && status < 300:
+		return true
+	case status == http.StatusBadRequest, status == http.StatusUnprocessableEntity:
+		return true
+	}
+	return false
+}
+
+// recorder passes a response through while keeping a copy of it
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Wrap applies the middleware to next
+func (m *Middleware) Wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(KeyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			apierror.ValidationError(w, r, map[string]string{KeyHeader: "Must be at most " + strconv.Itoa(maxKeyLength) + " characters"})
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		claim := Response{
+			Key:         key,
+			Scope:       m.Scope(r),
+			RequestHash: hex.EncodeToString(sum[:]),
+			CreatedAt:   time.Now(),
+		}
+		claimed, existing, err := m.Store.Claim(claim, time.Now().Add(-m.TTL))
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		if !claimed {
+			switch {
+			case existing.RequestHash != claim.RequestHash:
+				apierror.ErrorWithCode(w, r, apierror.CodeIdempotencyReused,
+					"Idempotency-Key was already used for a different request", http.StatusUnprocessableEntity)
+			case existing.StatusCode == 0:
+				apierror.ErrorWithCode(w, r, apierror.CodeIdempotencyInUse,
+					"A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			default:
+				w.Header().Set("Content-Type", existing.ContentType)
+				w.Header().Set(ReplayHeader, "true")
+				w.WriteHeader(existing.StatusCode)
+				w.Write(existing.Body)
+			}
+			return
+		}
+
+		// Unless the response is saved, release the key, also when next
+		// panics, so the request can be retried
+		saved := false
+		defer func() {
+			if saved {
+				return
+			}
+			if err := m.Store.Release(claim.Scope, key); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}()
+
+		rec := &recorder{ResponseWriter: w}
+		next(rec, r)
+
+		if !Stored(rec.status) {
+			return
+		}
+		claim.StatusCode = rec.status
+		claim.ContentType = w.Header().Get("Content-Type")
+		claim.Body = rec.body.Bytes()
+		if err := m.Store.Save(claim); err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		saved = true
+	}
+}
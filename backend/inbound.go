@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/notify"
+	"wait-to-go/phone"
+)
+
+const maxInboundBody = 64 << 10
+
+func (a *App) handleInboundSMS(w http.ResponseWriter, r *http.Request) {
+	if len(a.smsWebhookSecret) == 0 {
+		apierror.Error(w, r, "Inbound SMS is not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxInboundBody))
+	if err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	timestamp := r.Header.Get(auth.SignatureTimestampHeader)
+	if !auth.VerifyTimestamped(a.smsWebhookSecret, body, timestamp, r.Header.Get("X-Signature"), time.Now()) {
+		apierror.ErrorWithCode(w, r, apierror.CodeInvalidSignature, "Invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	msg, err := notify.DecodeInboundSMS(r.Header.Get("Content-Type"), body)
+	if err != nil || msg.From == "" {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	from, err := phone.Normalize(msg.From, a.phoneRegion)
+	if err != nil {
+		apierror.Error(w, r, "Invalid sender phone number", http.StatusBadRequest)
+		return
+	}
+
+	entry, err := getActiveEntryByPhone(a.db, from)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "No active entry for phone number", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	action, ok := notify.ParseAction(msg.Body)
+	if !ok {
+		a.sendSMS(entry, `Reply 1 to confirm you're coming, DELAY to move back a few spots, or CANCEL to leave the queue.`)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"status": "ignored"})
+		return
+	}
+
+	var reply string
+	switch action {
+	case notify.ActionConfirm:
+		err = confirmEntry(&entry, a.db)
+		reply = "Thanks, we'll see you soon!"
+	case notify.ActionDelay:
+		err = delayEntry(entry.ID, a.delaySpots, a.queue, a.db)
+		reply = fmt.Sprintf("No problem, you've been moved back up to %d spots.", a.delaySpots)
+	case notify.ActionCancel:
+		err = cancelEntry(entry.ID, a.queue, a.db)
+		reply = "You've left the queue. Hope to see you another time."
+	}
+	if err != nil {
+		log.Printf("Warning: failed to apply SMS action %s to entry %d: %v", action, entry.ID, err)
+		apierror.Error(w, r, "Failed to apply action", http.StatusConflict)
+		return
+	}
+
+	a.sendSMS(entry, reply)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"id":     entry.ID,
+		"action": action,
+	})
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wait-to-go/apierror"
+	"wait-to-go/queueing"
+)
+
+// The admin entry listing lives in queueing
+type (
+	EntryFilter = queueing.EntryFilter
+	EntryPage   = queueing.EntryPage
+)
+
+// handleListEntries pages through entries for staff:
+// GET /api/v1/queues/{queue}/entries?status=&serviceType=&q=&from=&to=&sort=&limit=&cursor=
+func (a *App) handleListEntries(w http.ResponseWriter, r *http.Request) {
+	filter, fields := queueing.ParseEntryFilter(r.URL.Query())
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	page, err := listEntries(a.db, filter)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
@@ -1,13 +1,22 @@
 package main
 
 import (
+	"crypto/rand"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"wait-to-go/auth"
+	"wait-to-go/challenge"
+	"wait-to-go/notify"
+	"wait-to-go/phone"
+	"wait-to-go/queueing"
+	"wait-to-go/webhook"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -20,6 +29,46 @@ type Config struct {
 	DBPassword string
 	DBName     string
 	DBSSLMode  string
+
+	SMSGatewayURL   string
+	EmailGatewayURL string
+	ReminderRules   []ReminderRule
+	ServiceTime     ServiceTime
+
+	SMSWebhookSecret string
+	SMSDelaySpots    int
+
+	PhoneRegion string
+
+	DuplicatePolicy DuplicatePolicy
+
+	JoinRateWindow    time.Duration
+	JoinLimitPerIP    int
+	JoinLimitPerPhone int
+	JoinVerifier      string
+	POWDifficulty     int
+	CaptchaVerifyURL  string
+	CaptchaSecret     string
+	StaticVerifyToken string
+
+	OTP OTPSettings
+
+	RecoveryURL string
+
+	IdempotencyTTL time.Duration
+
+	ServiceTypes []string
+
+	Capacity Capacity
+	Party    PartyPolicy
+
+	Appointments AppointmentPolicy
+
+	ArrivalCodes ArrivalCodes
+	Geofence     *Geofence
+	ArrivedOnly  bool
+
+	Posters Posters
 }
 
 func loadConfig() (*Config, error) {
@@ -30,11 +79,175 @@ func loadConfig() (*Config, error) {
 		DBPassword: getEnvOrDefault("DB_PASSWORD", "sicreto"),
 		DBName:     getEnvOrDefault("DB_NAME", "gopgtest"),
 		DBSSLMode:  getEnvOrDefault("DB_SSL_MODE", "disable"),
+
+		SMSGatewayURL:   os.Getenv("SMS_GATEWAY_URL"),
+		EmailGatewayURL: os.Getenv("EMAIL_GATEWAY_URL"),
+
+		SMSWebhookSecret: os.Getenv("SMS_WEBHOOK_SECRET"),
+
+		PhoneRegion: strings.ToUpper(getEnvOrDefault("PHONE_DEFAULT_REGION", "US")),
+
+		JoinVerifier:      os.Getenv("JOIN_VERIFIER"),
+		CaptchaVerifyURL:  os.Getenv("CAPTCHA_VERIFY_URL"),
+		CaptchaSecret:     os.Getenv("CAPTCHA_SECRET"),
+		StaticVerifyToken: os.Getenv("JOIN_VERIFY_STATIC_TOKEN"),
+
+		RecoveryURL: os.Getenv("RECOVERY_URL"),
+	}
+
+	if _, ok := phone.Regions[config.PhoneRegion]; !ok {
+		return nil, fmt.Errorf("unsupported PHONE_DEFAULT_REGION: %q", config.PhoneRegion)
+	}
+
+	rules, err := parseReminderRules(getEnvOrDefault("REMINDER_RULES", "position<=3"))
+	if err != nil {
+		return nil, err
+	}
+	config.ReminderRules = rules
+
+	config.ServiceTime.Party, err = time.ParseDuration(getEnvOrDefault("AVG_SERVICE_TIME", "5m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVG_SERVICE_TIME: %w", err)
+	}
+	config.ServiceTime.Guest, err = time.ParseDuration(getEnvOrDefault("AVG_SERVICE_TIME_PER_GUEST", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid AVG_SERVICE_TIME_PER_GUEST: %w", err)
+	}
+
+	maxActive, err := strconv.Atoi(getEnvOrDefault("DUPLICATE_JOIN_MAX", "1"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DUPLICATE_JOIN_MAX: %w", err)
+	}
+	config.DuplicatePolicy, err = queueing.ParseDuplicatePolicy(getEnvOrDefault("DUPLICATE_JOIN_POLICY", DuplicateReject), maxActive)
+	if err != nil {
+		return nil, err
+	}
+
+	config.JoinRateWindow, err = time.ParseDuration(getEnvOrDefault("JOIN_RATE_WINDOW", "1h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid JOIN_RATE_WINDOW: %w", err)
+	}
+	if config.JoinLimitPerIP, err = strconv.Atoi(getEnvOrDefault("JOIN_LIMIT_PER_IP", "20")); err != nil {
+		return nil, fmt.Errorf("invalid JOIN_LIMIT_PER_IP: %w", err)
+	}
+	if config.JoinLimitPerPhone, err = strconv.Atoi(getEnvOrDefault("JOIN_LIMIT_PER_PHONE", "5")); err != nil {
+		return nil, fmt.Errorf("invalid JOIN_LIMIT_PER_PHONE: %w", err)
+	}
+	if config.POWDifficulty, err = strconv.Atoi(getEnvOrDefault("POW_DIFFICULTY", "18")); err != nil {
+		return nil, fmt.Errorf("invalid POW_DIFFICULTY: %w", err)
+	}
+
+	config.OTP.VerifyPhone = getEnvOrDefault("PHONE_VERIFICATION", "false") == "true"
+	if config.OTP.TTL, err = time.ParseDuration(getEnvOrDefault("OTP_TTL", "10m")); err != nil {
+		return nil, fmt.Errorf("invalid OTP_TTL: %w", err)
+	}
+	if config.OTP.MaxAttempts, err = strconv.Atoi(getEnvOrDefault("OTP_MAX_ATTEMPTS", "5")); err != nil || config.OTP.MaxAttempts < 1 {
+		return nil, fmt.Errorf("invalid OTP_MAX_ATTEMPTS: %q", os.Getenv("OTP_MAX_ATTEMPTS"))
+	}
+
+	config.SMSDelaySpots, err = strconv.Atoi(getEnvOrDefault("SMS_DELAY_SPOTS", "3"))
+	if err != nil || config.SMSDelaySpots <= 0 {
+		return nil, fmt.Errorf("invalid SMS_DELAY_SPOTS: %q", os.Getenv("SMS_DELAY_SPOTS"))
+	}
+
+	if config.IdempotencyTTL, err = time.ParseDuration(getEnvOrDefault("IDEMPOTENCY_TTL", "24h")); err != nil {
+		return nil, fmt.Errorf("invalid IDEMPOTENCY_TTL: %w", err)
+	}
+
+	if config.ServiceTypes, err = parseServiceTypes(os.Getenv("SERVICE_TYPES")); err != nil {
+		return nil, fmt.Errorf("invalid SERVICE_TYPES: %w", err)
+	}
+
+	maxWaiting, err := strconv.Atoi(getEnvOrDefault("MAX_WAITING", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_WAITING: %w", err)
+	}
+	maxWait, err := time.ParseDuration(getEnvOrDefault("MAX_WAIT", "0"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_WAIT: %w", err)
+	}
+	if config.Capacity, err = queueing.ParseCapacity(maxWaiting, maxWait, getEnvOrDefault("WHEN_FULL", FullReject)); err != nil {
+		return nil, err
+	}
+
+	maxPartySize, err := strconv.Atoi(getEnvOrDefault("MAX_PARTY_SIZE", "12"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAX_PARTY_SIZE: %w", err)
+	}
+	partySkipLimit, err := strconv.Atoi(getEnvOrDefault("PARTY_SKIP_LIMIT", "3"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid PARTY_SKIP_LIMIT: %w", err)
+	}
+	if config.Party, err = parsePartyPolicy(maxPartySize, partySkipLimit); err != nil {
+		return nil, err
+	}
+
+	slotLength, err := time.ParseDuration(getEnvOrDefault("APPOINTMENT_SLOT_LENGTH", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPOINTMENT_SLOT_LENGTH: %w", err)
+	}
+	perSlot, err := strconv.Atoi(getEnvOrDefault("APPOINTMENTS_PER_SLOT", "1"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPOINTMENTS_PER_SLOT: %w", err)
+	}
+	walkIns, err := strconv.Atoi(getEnvOrDefault("APPOINTMENT_WALK_INS", "2"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPOINTMENT_WALK_INS: %w", err)
+	}
+	noShowAfter, err := time.ParseDuration(getEnvOrDefault("APPOINTMENT_NO_SHOW_AFTER", "15m"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid APPOINTMENT_NO_SHOW_AFTER: %w", err)
+	}
+	config.Appointments, err = queueing.ParseAppointmentPolicy(slotLength, perSlot, getEnvOrDefault("APPOINTMENT_PRIORITY", PriorityFirst), walkIns, noShowAfter)
+	if err != nil {
+		return nil, err
+	}
+
+	config.ArrivalCodes.Secret = []byte(os.Getenv("ARRIVAL_SECRET"))
+	if len(config.ArrivalCodes.Secret) == 0 {
+		log.Printf("ARRIVAL_SECRET is not set; arrival codes will change on restart")
+		config.ArrivalCodes.Secret = make([]byte, 32)
+		if _, err := rand.Read(config.ArrivalCodes.Secret); err != nil {
+			return nil, fmt.Errorf("failed to generate arrival secret: %w", err)
+		}
+	}
+	config.ArrivalCodes.Period, err = time.ParseDuration(getEnvOrDefault("ARRIVAL_CODE_PERIOD", "5m"))
+	if err != nil || config.ArrivalCodes.Period < time.Second {
+		return nil, fmt.Errorf("invalid ARRIVAL_CODE_PERIOD: %q", os.Getenv("ARRIVAL_CODE_PERIOD"))
+	}
+	config.ArrivalCodes.URL = os.Getenv("ARRIVAL_URL")
+	if config.Geofence, err = parseGeofence(os.Getenv("ARRIVAL_GEOFENCE")); err != nil {
+		return nil, fmt.Errorf("invalid ARRIVAL_GEOFENCE: %w", err)
+	}
+	config.ArrivedOnly = getEnvOrDefault("CALL_ARRIVED_ONLY", "false") == "true"
+
+	// Printed posters must outlive restarts, so there is no random default
+	config.Posters.Secret = []byte(os.Getenv("POSTER_SECRET"))
+	config.Posters.URL = os.Getenv("JOIN_URL")
+	config.Posters.Required = getEnvOrDefault("POSTER_REQUIRED", "false") == "true"
+	if config.Posters.Required && (len(config.Posters.Secret) == 0 || config.Posters.URL == "") {
+		return nil, fmt.Errorf("POSTER_REQUIRED needs POSTER_SECRET and JOIN_URL to be set")
 	}
 
 	return config, nil
 }
 
+// newNotifier uses the configured gateways, falling back to logging messages
+func newNotifier(config *Config) *notify.Notifier {
+	notifier := notify.NewNotifier()
+	notifier.Register(notify.ChannelSMS, notify.LogSender{})
+	notifier.Register(notify.ChannelEmail, notify.LogSender{})
+
+	if config.SMSGatewayURL != "" {
+		notifier.Register(notify.ChannelSMS, notify.NewHTTPSender(config.SMSGatewayURL))
+	}
+	if config.EmailGatewayURL != "" {
+		notifier.Register(notify.ChannelEmail, notify.NewHTTPSender(config.EmailGatewayURL))
+	}
+
+	return notifier
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -42,6 +255,27 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// newJoinVerifier builds the human verification configured by JOIN_VERIFIER
+func newJoinVerifier(config *Config) (challenge.Verifier, error) {
+	switch config.JoinVerifier {
+	case "":
+		return nil, nil
+	case "pow":
+		return challenge.NewProofOfWork(config.POWDifficulty, 5*time.Minute)
+	case "captcha":
+		if config.CaptchaVerifyURL == "" || config.CaptchaSecret == "" {
+			return nil, fmt.Errorf("CAPTCHA_VERIFY_URL and CAPTCHA_SECRET are required for the captcha verifier")
+		}
+		return challenge.NewCaptchaVerifier(config.CaptchaVerifyURL, config.CaptchaSecret), nil
+	case "static":
+		if config.StaticVerifyToken == "" {
+			return nil, fmt.Errorf("JOIN_VERIFY_STATIC_TOKEN is required for the static verifier")
+		}
+		return challenge.StaticVerifier{Token: config.StaticVerifyToken}, nil
+	}
+	return nil, fmt.Errorf("unknown JOIN_VERIFIER: %q", config.JoinVerifier)
+}
+
 func main() {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
@@ -76,6 +310,38 @@ func main() {
 	if err = createEntryTable(db); err != nil {
 		log.Fatalf("Failed to create table: %v", err)
 	}
+	// Inbound SMS, duplicate checks and recovery look numbers up in E.164 form
+	fixed, unreadable, err := normalizePhoneNumbers(db, config.PhoneRegion)
+	if err != nil {
+		log.Fatalf("Failed to normalize phone numbers: %v", err)
+	}
+	if fixed > 0 || unreadable > 0 {
+		log.Printf("Normalized %d stored phone numbers; %d could not be read and were left as they are", fixed, unreadable)
+	}
+	if err = createReminderTable(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createWebhookTables(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createBlocklistTable(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createOTPTable(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createHistoryTable(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createIdempotencyTable(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createCounterTable(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
+	if err = createQueueSettingsTables(db); err != nil {
+		log.Fatalf("Failed to create table: %v", err)
+	}
 
 	// Initialize queue and history
 	entryQueue := []Entry{}
@@ -89,29 +355,58 @@ func main() {
 		entryQueue = waitingEntries
 	}
 
-	app := App{
-		db:      db,
-		queue:   &entryQueue,
-		history: &historySlice,
+	notifier := newNotifier(config)
+
+	verifier, err := newJoinVerifier(config)
+	if err != nil {
+		log.Fatalf("Failed to configure join verification: %v", err)
 	}
 
-	// Setup routes with CORS and authentication middleware
-	mux := http.NewServeMux()
+	app := App{
+		db:          db,
+		queue:       &entryQueue,
+		history:     &historySlice,
+		notifier:    notifier,
+		reminders:   NewReminderEngine(db, notifier, config.ReminderRules, config.ServiceTime),
+		webhooks:    webhook.NewDispatcher(webhookStore{db}),
+		serviceTime: config.ServiceTime,
+		phoneRegion: config.PhoneRegion,
+
+		duplicatePolicy: config.DuplicatePolicy,
+		joinGuard:       NewJoinGuard(config.JoinRateWindow, config.JoinLimitPerIP, config.JoinLimitPerPhone, verifier),
+		otp:             config.OTP,
+		recoveryLimiter: auth.NewRateLimiter(15*time.Minute, 3), // 3 recovery requests per 15 minutes per IP and per phone
+		recoveryURL:     config.RecoveryURL,
+
+		smsWebhookSecret: []byte(config.SMSWebhookSecret),
+		delaySpots:       config.SMSDelaySpots,
+
+		idempotencyTTL: config.IdempotencyTTL,
+
+		serviceTypes: config.ServiceTypes,
 
-	// Public endpoint
-	mux.HandleFunc("/join", enableCors(app.handleJoin))
+		capacity: config.Capacity,
+		party:    config.Party,
 
-	// Customer endpoints (require JWT)
-	mux.HandleFunc("/status/", enableCors(auth.AuthMiddleware(app.handleStatus)))
+		appointments: config.Appointments,
 
-	// Admin endpoints (require API key)
-	mux.HandleFunc("/queue", enableCors(auth.AdminAuthMiddleware(app.handleQueue)))
-	mux.HandleFunc("/next", enableCors(auth.AdminAuthMiddleware(app.handleNext)))
-	mux.HandleFunc("/serve", enableCors(auth.AdminAuthMiddleware(app.handleServe)))
-	mux.HandleFunc("/clear", enableCors(auth.AdminAuthMiddleware(app.handleClear)))
+		arrivalCodes: config.ArrivalCodes,
+		geofence:     config.Geofence,
+		arrivedOnly:  config.ArrivedOnly,
+
+		posters: config.Posters,
+	}
+	app.registerNotifications()
+	app.registerCapacity()
+	queueEvents.subscribe(app.publishWebhook)
+	// Room may have opened up while the server was down, or the limits changed
+	if err := app.promoteWaitlisted(); err != nil {
+		log.Printf("Warning: failed to promote waitlisted entries: %v", err)
+	}
+	go app.runScheduler()
 
 	log.Println("Starting server on port 8080")
-	if err := http.ListenAndServe(":8080", mux); err != nil {
+	if err := http.ListenAndServe(":8080", app.routes()); err != nil {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
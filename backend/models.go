@@ -2,27 +2,97 @@ package main
 
 import (
 	"database/sql"
+	"sync"
 	"time"
+
+	"wait-to-go/auth"
+	"wait-to-go/notify"
+	"wait-to-go/queueing"
+	"wait-to-go/webhook"
 )
 
 type App struct {
-	db      *sql.DB
-	queue   *[]Entry
-	history *[]Entry
-}
+	db *sql.DB
+	// queueMu guards queue and history; see lockQueue
+	queueMu     sync.Mutex
+	queue       *[]Entry
+	history     *[]Entry
+	notifier    *notify.Notifier
+	reminders   *ReminderEngine
+	webhooks    *webhook.Dispatcher
+	serviceTime ServiceTime
+	phoneRegion string
+
+	duplicatePolicy DuplicatePolicy
+	joinGuard       *JoinGuard
+	otp             OTPSettings
+	recoveryLimiter *auth.RateLimiter
+	recoveryURL     string
+
+	smsWebhookSecret []byte
+	delaySpots       int
+
+	idempotencyTTL time.Duration
+
+	serviceTypes []string
+
+	capacity Capacity
+	party    PartyPolicy
+
+	appointments AppointmentPolicy
 
-type Entry struct {
-	ID          int       `json:"id"`
-	FirstName   string    `json:"firstName"`
-	LastName    string    `json:"lastName"`
-	Email       string    `json:"email"`
-	PhoneNumber string    `json:"phoneNumber"`
-	Status      string    `json:"status"`
-	JoinTime    time.Time `json:"joinTime"`
+	arrivalCodes ArrivalCodes
+	geofence     *Geofence
+	arrivedOnly  bool
+
+	posters Posters
 }
 
+// Entry and its statuses live in queueing, so the queue rules can be tested
+// without a database
+type Entry = queueing.Entry
+
 const (
-	StatusWaiting  = "waiting"
-	StatusNotified = "notified"
-	StatusServed   = "served"
+	StatusPending    = queueing.StatusPending
+	StatusBooked     = queueing.StatusBooked
+	StatusWaitlisted = queueing.StatusWaitlisted
+	StatusWaiting    = queueing.StatusWaiting
+	StatusNotified   = queueing.StatusNotified
+	StatusServed     = queueing.StatusServed
+	StatusCancelled  = queueing.StatusCancelled
+	StatusNoShow     = queueing.StatusNoShow
 )
+
+// JoinRequest holds the fields a customer sets when joining. Everything else
+// on an entry, such as its arrival, counter or slot, is set by the server, so
+// join requests never decode straight into Entry.
+type JoinRequest struct {
+	FirstName           string         `json:"firstName"`
+	LastName            string         `json:"lastName"`
+	Email               string         `json:"email"`
+	PhoneNumber         string         `json:"phoneNumber"`
+	NotificationChannel notify.Channel `json:"notificationChannel"`
+	Notes               string         `json:"notes"`
+	ServiceType         string         `json:"serviceType"`
+	PartySize           int            `json:"partySize"`
+}
+
+// entry returns a new entry with the request's fields
+func (req JoinRequest) entry() Entry {
+	return Entry{
+		FirstName:           req.FirstName,
+		LastName:            req.LastName,
+		Email:               req.Email,
+		PhoneNumber:         req.PhoneNumber,
+		NotificationChannel: req.NotificationChannel,
+		Notes:               req.Notes,
+		ServiceType:         req.ServiceType,
+		PartySize:           req.PartySize,
+	}
+}
+
+// AppointmentRequest is a JoinRequest for a booked slot
+type AppointmentRequest struct {
+	JoinRequest
+	AppointmentAt *time.Time `json:"appointmentAt"`
+}
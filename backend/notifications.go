@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"wait-to-go/notify"
+)
+
+// customerMessage addresses a message to the channel the customer chose at /join
+func customerMessage(entry Entry, subject, body string) notify.Message {
+	msg := notify.Message{
+		Channel: entry.NotificationChannel,
+		Subject: subject,
+		Body:    body,
+	}
+
+	switch entry.NotificationChannel {
+	case notify.ChannelEmail:
+		msg.To = entry.Email
+	case notify.ChannelNone:
+	default:
+		msg.Channel = notify.ChannelSMS
+		msg.To = entry.PhoneNumber
+	}
+
+	return msg
+}
+
+// sendSMS messages the entry's phone regardless of their preferred channel.
+// Used for replies to inbound SMS and for codes proving phone possession.
+func (a *App) sendSMS(entry Entry, body string) {
+	entry.NotificationChannel = notify.ChannelSMS
+	msg := customerMessage(entry, "", body)
+	go func() {
+		if err := a.notifier.Send(msg); err != nil {
+			log.Printf("Warning: failed to send SMS to entry %d: %v", entry.ID, err)
+		}
+	}()
+}
+
+// turnMessage tells a called customer where to go
+func turnMessage(entry Entry) string {
+	if entry.Counter != "" {
+		return fmt.Sprintf("Hi %s, it's your turn! Please go to %s.", entry.FirstName, entry.Counter)
+	}
+	return fmt.Sprintf("Hi %s, it's your turn! Please come to the front.", entry.FirstName)
+}
+
+// registerNotifications wires customer messaging and reminders to queue events
+func (a *App) registerNotifications() {
+	queueEvents.subscribe(func(event QueueEvent) {
+		switch event.Type {
+		case EventNotified:
+			msg := customerMessage(*event.Entry, "It's your turn", turnMessage(*event.Entry))
+			go func() {
+				if err := a.notifier.Send(msg); err != nil {
+					log.Printf("Warning: failed to notify entry %d: %v", event.Entry.ID, err)
+				}
+			}()
+		}
+
+		switch event.Type {
+		case EventNotified, EventServed, EventCancelled, EventDelayed, EventMoved, EventNoShow, EventCleared:
+			a.reminders.Evaluate(*a.queue)
+		}
+	})
+}
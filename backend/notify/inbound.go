@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// Inbound SMS commands customers can reply with
+const (
+	ActionConfirm = "confirm"
+	ActionDelay   = "delay"
+	ActionCancel  = "cancel"
+)
+
+// InboundSMS is a reply forwarded by the SMS provider
+type InboundSMS struct {
+	From string `json:"from"`
+	Body string `json:"body"`
+}
+
+// ParseAction maps a reply body to an action, ignoring case and whitespace
+func ParseAction(body string) (string, bool) {
+	switch strings.ToUpper(strings.TrimSpace(body)) {
+	case "1", "YES", "Y":
+		return ActionConfirm, true
+	case "DELAY":
+		return ActionDelay, true
+	case "STOP", "CANCEL":
+		return ActionCancel, true
+	}
+	return "", false
+}
+
+// DecodeInboundSMS accepts either JSON ({"from", "body"}) or provider style
+// form posts (From, Body)
+func DecodeInboundSMS(contentType string, body []byte) (InboundSMS, error) {
+	var msg InboundSMS
+
+	if strings.HasPrefix(contentType, "application/json") {
+		if err := json.NewDecoder(bytes.NewReader(body)).Decode(&msg); err != nil {
+			return msg, err
+		}
+		return msg, nil
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return msg, err
+	}
+	msg.From = form.Get("From")
+	msg.Body = form.Get("Body")
+	return msg, nil
+}
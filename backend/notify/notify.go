@@ -0,0 +1,121 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Channel identifies how a customer wants to be contacted
+type Channel string
+
+const (
+	ChannelSMS   Channel = "sms"
+	ChannelEmail Channel = "email"
+	ChannelNone  Channel = "none"
+)
+
+// ParseChannel validates a channel name, defaulting to SMS when empty
+func ParseChannel(name string) (Channel, bool) {
+	switch Channel(name) {
+	case "":
+		return ChannelSMS, true
+	case ChannelSMS, ChannelEmail, ChannelNone:
+		return Channel(name), true
+	}
+	return "", false
+}
+
+// Message is a single outbound notification
+type Message struct {
+	Channel Channel `json:"channel"`
+	To      string  `json:"to"`
+	Subject string  `json:"subject,omitempty"`
+	Body    string  `json:"body"`
+}
+
+// Sender delivers messages for one channel
+type Sender interface {
+	Send(msg Message) error
+}
+
+// LogSender writes messages to the server log instead of delivering them
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("notify: [%s] to %s: %s", msg.Channel, msg.To, msg.Body)
+	return nil
+}
+
+// HTTPSender posts messages as JSON to a gateway URL (SMS or email provider bridge)
+type HTTPSender struct {
+	URL    string
+	Client *http.Client
+}
+
+func NewHTTPSender(url string) *HTTPSender {
+	return &HTTPSender{
+		URL:    url,
+		Client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (s *HTTPSender) Send(msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	resp, err := s.Client.Post(s.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post message: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("gateway returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Notifier routes messages to the sender registered for their channel
+type Notifier struct {
+	senders map[Channel]Sender
+	mu      sync.RWMutex
+}
+
+func NewNotifier() *Notifier {
+	return &Notifier{
+		senders: make(map[Channel]Sender),
+	}
+}
+
+// Register sets the sender used for a channel
+func (n *Notifier) Register(channel Channel, sender Sender) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.senders[channel] = sender
+}
+
+// Send delivers a message through its channel. Messages for ChannelNone are dropped.
+func (n *Notifier) Send(msg Message) error {
+	if msg.Channel == ChannelNone {
+		return nil
+	}
+	if msg.To == "" {
+		return fmt.Errorf("no %s recipient", msg.Channel)
+	}
+
+	n.mu.RLock()
+	sender, ok := n.senders[msg.Channel]
+	n.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no sender registered for channel %q", msg.Channel)
+	}
+
+	return sender.Send(msg)
+}
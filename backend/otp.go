@@ -0,0 +1,205 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/queueing"
+)
+
+// OTP purposes; a subject may hold one live code per purpose
+const (
+	OTPPurposeJoin = "join"
+)
+
+var (
+	errOTPNotFound        = errors.New("no code was issued")
+	errOTPExpired         = errors.New("code has expired")
+	errOTPTooManyAttempts = errors.New("too many incorrect attempts")
+	errOTPMismatch        = errors.New("code is incorrect")
+)
+
+type OTPCode struct {
+	Purpose   string
+	Subject   string
+	CodeHash  string
+	Attempts  int
+	ExpiresAt time.Time
+}
+
+type OTPSettings struct {
+	// VerifyPhone makes /join hold entries as pending until the phone is verified
+	VerifyPhone bool
+	TTL         time.Duration
+	MaxAttempts int
+}
+
+// issueOTP stores a new code for the subject and returns it for delivery
+func (a *App) issueOTP(purpose, subject string) (string, error) {
+	code, err := auth.GenerateOTP()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate code: %w", err)
+	}
+
+	err = saveOTP(a.db, OTPCode{
+		Purpose:   purpose,
+		Subject:   subject,
+		CodeHash:  auth.HashOTP(purpose+"/"+subject, code),
+		ExpiresAt: time.Now().Add(a.otp.TTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// checkOTP verifies a submitted code, counting every attempt before the
+// comparison so parallel guesses share the limit. A code is consumed by the
+// first successful check.
+func (a *App) checkOTP(purpose, subject, code string) (remaining int, err error) {
+	otp, err := claimOTPAttempt(a.db, purpose, subject, a.otp.MaxAttempts)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either there is no code or its attempts are used up
+		if _, err := getOTP(a.db, purpose, subject); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return 0, errOTPNotFound
+			}
+			return 0, err
+		}
+		return 0, errOTPTooManyAttempts
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if time.Now().After(otp.ExpiresAt) {
+		return 0, errOTPExpired
+	}
+
+	if !auth.CheckOTP(purpose+"/"+subject, code, otp.CodeHash) {
+		return a.otp.MaxAttempts - otp.Attempts, errOTPMismatch
+	}
+
+	deleted, err := deleteOTP(a.db, purpose, subject)
+	if err != nil {
+		return 0, err
+	}
+	if !deleted {
+		return 0, errOTPNotFound
+	}
+	return 0, nil
+}
+
+// writeOTPError maps checkOTP failures to API errors
+func writeOTPError(w http.ResponseWriter, r *http.Request, remaining int, err error) {
+	switch {
+	case errors.Is(err, errOTPNotFound):
+		apierror.Error(w, r, "No verification code was issued", http.StatusNotFound)
+	case errors.Is(err, errOTPExpired):
+		apierror.ErrorWithCode(w, r, apierror.CodeCodeExpired, "The verification code has expired", http.StatusGone)
+	case errors.Is(err, errOTPTooManyAttempts):
+		apierror.ErrorWithCode(w, r, apierror.CodeTooManyAttempts, "Too many incorrect attempts", http.StatusTooManyRequests)
+	case errors.Is(err, errOTPMismatch):
+		apierror.ErrorWithDetails(w, r, apierror.CodeInvalidCode, "The verification code is incorrect", http.StatusBadRequest,
+			map[string]string{"attemptsRemaining": strconv.Itoa(remaining)})
+	default:
+		apierror.Error(w, r, "Failed to verify code", http.StatusInternalServerError)
+	}
+}
+
+// startJoinVerification stores the entry as pending and texts it a code
+func (a *App) startJoinVerification(w http.ResponseWriter, r *http.Request, entry Entry) {
+	entry.Status = StatusPending
+	id, err := addPendingEntry(entry, a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return
+	}
+	entry.ID = id
+
+	code, err := a.issueOTP(OTPPurposeJoin, strconv.Itoa(id))
+	if err != nil {
+		log.Printf("Warning: %v", err)
+		apierror.Error(w, r, "Failed to send verification code", http.StatusInternalServerError)
+		return
+	}
+	a.sendSMS(entry, fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(a.otp.TTL.Minutes())))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "pending_verification",
+		"id":        id,
+		"expiresIn": int(a.otp.TTL.Seconds()),
+	})
+}
+
+// handleJoinVerify completes a pending join: POST /api/v1/entries/{id}:verify
+// {"code"}, or the legacy POST /join/verify {"id", "code"}
+func (a *App) handleJoinVerify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID   int    `json:"id"`
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if id := r.PathValue("id"); id != "" {
+		var err error
+		if req.ID, err = strconv.Atoi(id); err != nil {
+			apierror.Error(w, r, "Invalid ID format", http.StatusBadRequest)
+			return
+		}
+	}
+	if len(req.Code) != auth.OTPDigits {
+		apierror.ValidationError(w, r, map[string]string{"code": fmt.Sprintf("Code must be %d digits", auth.OTPDigits)})
+		return
+	}
+
+	entry, err := getEntryByID(a.db, req.ID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Entry not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+	if entry.Status != StatusPending {
+		apierror.Error(w, r, "Entry is not awaiting verification", http.StatusConflict)
+		return
+	}
+
+	if remaining, err := a.checkOTP(OTPPurposeJoin, strconv.Itoa(entry.ID), req.Code); err != nil {
+		writeOTPError(w, r, remaining, err)
+		return
+	}
+
+	if queueing.IsAppointment(entry) {
+		a.completeBooking(w, r, entry)
+		return
+	}
+
+	// The phone may have joined by another route while this entry was pending
+	existing, err := getActiveEntriesByPhone(a.db, entry.PhoneNumber)
+	if err != nil {
+		apierror.Error(w, r, "Failed to add entry", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) > 0 && !a.duplicatePolicy.Allows(len(existing)) {
+		a.handleDuplicateJoin(w, r, existing[0], true)
+		return
+	}
+	if entry, ok := a.admit(w, r, entry); ok {
+		a.writeJoined(w, r, entry)
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+)
+
+// PartyPolicy limits party sizes and how far calling by seats may skip ahead
+type PartyPolicy struct {
+	MaxSize int
+	// SkipLimit is how many times a party too large for the seats being
+	// filled may be passed over by smaller parties behind it. After that the
+	// seats wait for it, so large parties are not starved.
+	SkipLimit int
+}
+
+func parsePartyPolicy(maxSize, skipLimit int) (PartyPolicy, error) {
+	if maxSize < 1 {
+		return PartyPolicy{}, fmt.Errorf("maximum party size must be at least 1, got %d", maxSize)
+	}
+	if skipLimit < 0 {
+		return PartyPolicy{}, fmt.Errorf("party skip limit must not be negative, got %d", skipLimit)
+	}
+	return PartyPolicy{MaxSize: maxSize, SkipLimit: skipLimit}, nil
+}
@@ -0,0 +1,103 @@
+package phone
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	ErrEmpty         = errors.New("phone number is required")
+	ErrInvalidChars  = errors.New("phone number contains invalid characters")
+	ErrUnknownRegion = errors.New("unknown default region")
+	ErrInvalidLength = errors.New("phone number has an invalid length")
+)
+
+// Region describes how national numbers are written in a country
+type Region struct {
+	CountryCode string
+	// TrunkPrefix is dropped from national numbers before adding the country code
+	TrunkPrefix string
+	// NationalLengths lists the accepted significant number lengths
+	NationalLengths []int
+}
+
+// Regions supported as a default region, keyed by ISO 3166-1 alpha-2 code
+var Regions = map[string]Region{
+	"US": {CountryCode: "1", TrunkPrefix: "1", NationalLengths: []int{10}},
+	"CA": {CountryCode: "1", TrunkPrefix: "1", NationalLengths: []int{10}},
+	"DO": {CountryCode: "1", TrunkPrefix: "1", NationalLengths: []int{10}},
+	"PR": {CountryCode: "1", TrunkPrefix: "1", NationalLengths: []int{10}},
+	"MX": {CountryCode: "52", NationalLengths: []int{10}},
+	"GB": {CountryCode: "44", TrunkPrefix: "0", NationalLengths: []int{9, 10}},
+	"ES": {CountryCode: "34", NationalLengths: []int{9}},
+	"FR": {CountryCode: "33", TrunkPrefix: "0", NationalLengths: []int{9}},
+	"DE": {CountryCode: "49", TrunkPrefix: "0", NationalLengths: []int{6, 7, 8, 9, 10, 11}},
+}
+
+const (
+	minE164Digits = 8
+	maxE164Digits = 15
+)
+
+// Normalize parses a phone number as typed by a user and returns it in E.164
+// form (e.g. "+15550100123"). Numbers starting with "+" or "00" are treated as
+// international; anything else is read as a national number in defaultRegion.
+// Spaces, dots, dashes, slashes and parentheses are ignored.
+func Normalize(raw, defaultRegion string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", ErrEmpty
+	}
+
+	international := false
+	if strings.HasPrefix(raw, "+") {
+		international = true
+		raw = raw[1:]
+	}
+
+	var digits strings.Builder
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case strings.ContainsRune(" .-/()", r):
+		default:
+			return "", ErrInvalidChars
+		}
+	}
+
+	number := digits.String()
+	if !international && strings.HasPrefix(number, "00") {
+		international = true
+		number = number[2:]
+	}
+
+	if international {
+		if len(number) < minE164Digits || len(number) > maxE164Digits || number[0] == '0' {
+			return "", ErrInvalidLength
+		}
+		return "+" + number, nil
+	}
+
+	region, ok := Regions[strings.ToUpper(defaultRegion)]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownRegion, defaultRegion)
+	}
+
+	number = strings.TrimPrefix(number, region.TrunkPrefix)
+	if !validLength(region, len(number)) {
+		return "", ErrInvalidLength
+	}
+
+	return "+" + region.CountryCode + number, nil
+}
+
+func validLength(region Region, n int) bool {
+	for _, length := range region.NationalLengths {
+		if n == length {
+			return true
+		}
+	}
+	return false
+}
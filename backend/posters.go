@@ -0,0 +1,220 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/qrcode"
+)
+
+// PosterHeader carries the token from the join link on a poster
+const PosterHeader = "X-Poster-Token"
+
+// maxPosterValidity bounds how long a signed join link can work
+const maxPosterValidity = 366 * 24 * time.Hour
+
+var (
+	errPosterInvalid = errors.New("poster token is not valid")
+	errPosterExpired = errors.New("poster token has expired")
+)
+
+// Posters makes the join links printed as QR codes on site. A signed link
+// carries a token with an expiry; with Required set, joining needs a valid
+// token, so only people who saw a poster can join.
+type Posters struct {
+	Secret []byte
+	// URL is the page the QR code opens, with the queue as ?queue= and the
+	// token as ?poster=
+	URL      string
+	Required bool
+}
+
+// signCode returns value in base 36, a dot and a truncated HMAC of it.
+// purpose keeps a code made for one use from being accepted for another.
+func signCode(secret []byte, purpose string, value int64) string {
+	mac := hmac.New(sha256.New, secret)
+	fmt.Fprintf(mac, "%s:%d", purpose, value)
+	return strconv.FormatInt(value, 36) + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:12])
+}
+
+// signedValue returns the value in a code made by signCode, or false if the
+// signature does not match
+func signedValue(secret []byte, purpose string, code string) (int64, bool) {
+	prefix, _, _ := strings.Cut(code, ".")
+	value, err := strconv.ParseInt(prefix, 36, 64)
+	if err != nil || !hmac.Equal([]byte(code), []byte(signCode(secret, purpose, value))) {
+		return 0, false
+	}
+	return value, true
+}
+
+// token returns a token that works until expires
+func (p Posters) token(expires time.Time) string {
+	return signCode(p.Secret, "poster", expires.Unix())
+}
+
+func (p Posters) check(token string, now time.Time) error {
+	expires, ok := signedValue(p.Secret, "poster", token)
+	switch {
+	case !ok:
+		return errPosterInvalid
+	case now.Unix() >= expires:
+		return errPosterExpired
+	}
+	return nil
+}
+
+// link returns the join URL for a queue, with a token if expires is set
+func (p Posters) link(queueID string, expires *time.Time) (string, error) {
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	query.Set("queue", queueID)
+	if expires != nil {
+		query.Set("poster", p.token(*expires))
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// checkPoster rejects joins and bookings without a valid poster token when
+// one is required
+func (a *App) checkPoster(w http.ResponseWriter, r *http.Request) bool {
+	if !a.posters.Required {
+		return true
+	}
+
+	token := r.Header.Get(PosterHeader)
+	if token == "" {
+		apierror.ErrorWithCode(w, r, apierror.CodePosterRequired, "Scan the QR code on site to join the queue", http.StatusForbidden)
+		return false
+	}
+	switch a.posters.check(token, time.Now()) {
+	case errPosterExpired:
+		apierror.ErrorWithCode(w, r, apierror.CodeCodeExpired, "This poster has expired; please scan the current one on site", http.StatusGone)
+		return false
+	case errPosterInvalid:
+		apierror.ErrorWithCode(w, r, apierror.CodeInvalidCode, "The poster code is not valid", http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// handlePosterQRCode renders the join link as a QR code to print:
+// GET /api/v1/queues/{queue}/qrcode?format=png|svg&scale=10&level=M&validFor=720h.
+// With validFor, or when POSTER_REQUIRED is set, the link is signed and
+// stops working after validFor (30 days by default).
+func (a *App) handlePosterQRCode(w http.ResponseWriter, r *http.Request) {
+	if a.posters.URL == "" {
+		apierror.Error(w, r, "Join posters are not enabled", http.StatusNotFound)
+		return
+	}
+
+	query := r.URL.Query()
+	fields := map[string]string{}
+	image := parseQRImage(query, fields, "png", "svg")
+
+	var expires *time.Time
+	if s := query.Get("validFor"); s != "" || a.posters.Required {
+		validFor := 30 * 24 * time.Hour
+		if s != "" {
+			d, err := time.ParseDuration(s)
+			if err != nil || d <= 0 || d > maxPosterValidity {
+				fields["validFor"] = "validFor must be a duration such as 720h, up to 8784h"
+			}
+			validFor = d
+		}
+		if len(a.posters.Secret) == 0 {
+			fields["validFor"] = "Signed posters need POSTER_SECRET to be set"
+		}
+		t := time.Now().Add(validFor).Truncate(time.Second)
+		expires = &t
+	}
+
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	queueID := r.PathValue("queue")
+	link, err := a.posters.link(queueID, expires)
+	if err != nil {
+		apierror.Error(w, r, "Failed to build join link", http.StatusInternalServerError)
+		return
+	}
+	body, contentType, err := image.render(link)
+	if err != nil {
+		apierror.Error(w, r, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="queue-%s.%s"`, queueID, image.format))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Join-URL", link)
+	if expires != nil {
+		w.Header().Set("X-Poster-Expires", expires.UTC().Format(time.RFC3339))
+	}
+	w.Write(body)
+}
+
+// qrImage is how a QR code endpoint renders its code
+type qrImage struct {
+	format string
+	scale  int
+	level  qrcode.Level
+}
+
+// parseQRImage reads the format, scale and level query parameters, adding any
+// problems to fields. formats lists the formats allowed, the default first.
+func parseQRImage(query url.Values, fields map[string]string, formats ...string) qrImage {
+	image := qrImage{format: query.Get("format"), scale: 10, level: qrcode.Medium}
+	if image.format == "" {
+		image.format = formats[0]
+	}
+	if !slices.Contains(formats, image.format) {
+		fields["format"] = "Format must be " + strings.Join(formats, " or ")
+	}
+
+	if s := query.Get("scale"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 || n > 40 {
+			fields["scale"] = "Scale must be a whole number of pixels per module from 1 to 40"
+		}
+		image.scale = n
+	}
+
+	if s := query.Get("level"); s != "" {
+		var ok bool
+		if image.level, ok = qrcode.ParseLevel(s); !ok {
+			fields["level"] = "Level must be L, M, Q or H"
+		}
+	}
+	return image
+}
+
+// render encodes text as a png or svg QR code and returns its content type
+func (image qrImage) render(text string) ([]byte, string, error) {
+	code, err := qrcode.Encode(text, image.level)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if image.format == "svg" {
+		return code.SVG(), "image/svg+xml", nil
+	}
+	body, err := code.PNG(image.scale)
+	return body, "image/png", err
+}
@@ -0,0 +1,293 @@
+package qrcode
+
+// matrix is a code being built. function marks the modules of the finder,
+// timing and alignment patterns and the format and version information,
+// which hold no data and are never masked.
+type matrix struct {
+	version  int
+	size     int
+	dark     [][]bool
+	function [][]bool
+}
+
+func newMatrix(version int) *matrix {
+	size := 17 + 4*version
+	m := &matrix{version: version, size: size}
+	m.dark = make([][]bool, size)
+	m.function = make([][]bool, size)
+	for y := range size {
+		m.dark[y] = make([]bool, size)
+		m.function[y] = make([]bool, size)
+	}
+	return m
+}
+
+func (m *matrix) set(x, y int, dark bool) {
+	m.dark[y][x] = dark
+	m.function[y][x] = true
+}
+
+func (m *matrix) drawFunctionPatterns() {
+	// Timing patterns
+	for i := range m.size {
+		m.set(6, i, i%2 == 0)
+		m.set(i, 6, i%2 == 0)
+	}
+
+	// Finder patterns, with their separators, in three corners
+	m.drawFinder(3, 3)
+	m.drawFinder(m.size-4, 3)
+	m.drawFinder(3, m.size-4)
+
+	// Alignment patterns, except where they would overlap a finder
+	positions := alignmentPositions(m.version)
+	last := len(positions) - 1
+	for i, x := range positions {
+		for j, y := range positions {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			m.drawAlignment(x, y)
+		}
+	}
+
+	// Reserve the format information; drawFormat fills it in per mask
+	m.drawFormat(Medium, 0)
+	m.drawVersion()
+}
+
+// drawFinder draws a finder pattern centred on (x, y) with the light
+// separator around it
+func (m *matrix) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || xx >= m.size || yy < 0 || yy >= m.size {
+				continue
+			}
+			dist := max(abs(dx), abs(dy))
+			m.set(xx, yy, dist != 2 && dist != 4)
+		}
+	}
+}
+
+// drawAlignment draws a 5×5 alignment pattern centred on (x, y)
+func (m *matrix) drawAlignment(x, y int) {
+	for dy := -2; dy <= 2; dy++ {
+		for dx := -2; dx <= 2; dx++ {
+			m.set(x+dx, y+dy, max(abs(dx), abs(dy)) != 1)
+		}
+	}
+}
+
+// alignmentPositions returns the rows and columns of the alignment pattern
+// centres, in ascending order
+func alignmentPositions(version int) []int {
+	if version == 1 {
+		return nil
+	}
+	count := version/7 + 2
+	step := (version*4 + count*2 + 1) / (count*2 - 2) * 2
+	if version == 32 {
+		step = 26
+	}
+	positions := make([]int, count)
+	positions[0] = 6
+	for i, pos := count-1, 17+4*version-7; i >= 1; i, pos = i-1, pos-step {
+		positions[i] = pos
+	}
+	return positions
+}
+
+// drawFormat writes the level and mask, protected by a BCH code, in both
+// copies of the format information
+func (m *matrix) drawFormat(level Level, mask int) {
+	data := formatBits[level]<<3 | mask
+	rem := data
+	for range 10 {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 == 1 }
+
+	// Around the top left finder
+	for i := 0; i <= 5; i++ {
+		m.set(8, i, bit(i))
+	}
+	m.set(8, 7, bit(6))
+	m.set(8, 8, bit(7))
+	m.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		m.set(14-i, 8, bit(i))
+	}
+
+	// Split between the other two finders
+	for i := range 8 {
+		m.set(m.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		m.set(8, m.size-15+i, bit(i))
+	}
+	m.set(8, m.size-8, true) // always dark
+}
+
+// drawVersion writes the version, protected by a BCH code, next to the two
+// finders that share it, from version 7 up
+func (m *matrix) drawVersion() {
+	if m.version < 7 {
+		return
+	}
+	rem := m.version
+	for range 12 {
+		rem = rem<<1 ^ (rem>>11)*0x1F25
+	}
+	bits := m.version<<12 | rem
+	for i := range 18 {
+		dark := bits>>i&1 == 1
+		a, b := m.size-11+i%3, i/3
+		m.set(a, b, dark)
+		m.set(b, a, dark)
+	}
+}
+
+// drawCodewords fills the data modules two columns at a time, zigzagging up
+// and down from the bottom right and skipping the vertical timing pattern.
+// Leftover modules stay light.
+func (m *matrix) drawCodewords(codewords []byte) {
+	i := 0
+	for right := m.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range m.size {
+			y := vert
+			if upward {
+				y = m.size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if m.function[y][x] || i >= len(codewords)*8 {
+					continue
+				}
+				m.dark[y][x] = codewords[i/8]>>(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by one of the eight masks
+func (m *matrix) applyMask(mask int) {
+	for y := range m.size {
+		for x := range m.size {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !m.function[y][x] {
+				m.dark[y][x] = !m.dark[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the patterns that make a code hard to read: long runs of one
+// colour, 2×2 blocks, shapes like a finder and an uneven balance of dark and
+// light
+func (m *matrix) penalty() int {
+	score := 0
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return m.dark[x][y]
+		}
+		return m.dark[y][x]
+	}
+
+	for _, vertical := range []bool{false, true} {
+		for y := range m.size {
+			run := 0
+			for x := range m.size {
+				if x > 0 && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					score += 3
+				} else if run > 5 {
+					score++
+				}
+
+				// 1:1:3:1:1 dark to light, with four light modules on one side
+				if x+7 <= m.size && m.finderLike(x, y, vertical, at) {
+					before := x >= 4 && m.light(x-4, x, y, vertical, at)
+					after := x+11 <= m.size && m.light(x+7, x+11, y, vertical, at)
+					if before || after {
+						score += 40
+					}
+				}
+			}
+		}
+	}
+
+	dark := 0
+	for y := range m.size {
+		for x := range m.size {
+			if m.dark[y][x] {
+				dark++
+			}
+			if x+1 < m.size && y+1 < m.size {
+				c := m.dark[y][x]
+				if m.dark[y][x+1] == c && m.dark[y+1][x] == c && m.dark[y+1][x+1] == c {
+					score += 3
+				}
+			}
+		}
+	}
+	total := m.size * m.size
+	score += abs(dark*20-total*10) / total * 10
+	return score
+}
+
+var finderRun = [7]bool{true, false, true, true, true, false, true}
+
+func (m *matrix) finderLike(x, y int, vertical bool, at func(x, y int, vertical bool) bool) bool {
+	for i, dark := range finderRun {
+		if at(x+i, y, vertical) != dark {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *matrix) light(from, to, y int, vertical bool, at func(x, y int, vertical bool) bool) bool {
+	for x := from; x < to; x++ {
+		if at(x, y, vertical) {
+			return false
+		}
+	}
+	return true
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,68 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+)
+
+// QuietZone is the light border, in modules, readers need around a code
+const QuietZone = 4
+
+// PNG renders the code with scale pixels per module and the quiet zone
+func (c *Code) PNG(scale int) ([]byte, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("qrcode: scale must be at least 1, got %d", scale)
+	}
+
+	width := (c.Size + 2*QuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, width, width), color.Palette{color.White, color.Black})
+	for y := range c.Size {
+		for x := range c.Size {
+			if !c.Dark(x, y) {
+				continue
+			}
+			for dy := range scale {
+				row := (y+QuietZone)*scale + dy
+				for dx := range scale {
+					img.SetColorIndex((x+QuietZone)*scale+dx, row, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code with one user unit per module and the quiet zone, so
+// it scales to any size
+func (c *Code) SVG() []byte {
+	width := c.Size + 2*QuietZone
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`, width, width)
+	fmt.Fprintf(&buf, `<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="`, width, width)
+	for y := range c.Size {
+		for x := 0; x < c.Size; x++ {
+			if !c.Dark(x, y) {
+				continue
+			}
+			// One rectangle per horizontal run of dark modules
+			run := 1
+			for x+run < c.Size && c.Dark(x+run, y) {
+				run++
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", x+QuietZone, y+QuietZone, run, run)
+			x += run - 1
+		}
+	}
+	buf.WriteString(`"/></svg>`)
+	buf.WriteString("\n")
+	return buf.Bytes()
+}
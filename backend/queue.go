@@ -2,45 +2,134 @@ package main
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"slices"
 	"sort"
+	"time"
+
+	"wait-to-go/queueing"
 )
 
-type ByJoinTime []Entry
+var ErrQueueEmpty = errors.New("queue is empty")
+
+// queueOrderGap spaces the ordering keys of entries so one can be moved
+// between two others without renumbering the queue
+const queueOrderGap = queueing.QueueOrderGap
 
-func (q ByJoinTime) Len() int           { return len(q) }
-func (q ByJoinTime) Less(i, j int) bool { return q[i].JoinTime.Before(q[j].JoinTime) }
-func (q ByJoinTime) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+// ByQueueOrder sorts entries in the order they will be called
+type ByQueueOrder = queueing.ByQueueOrder
 
-func notifyNext(queue *[]Entry, history *[]Entry, db *sql.DB) error {
+// notifyNext calls the next entry to counter, which may be nil. A counter
+// calls the first entry it has the skills for that the rules allow (see
+// nextFor). Appointments are placed among the walk-ins as they enter the
+// queue (see AppointmentPolicy), so calling in queue order interleaves the two.
+func notifyNext(counter *Counter, rules queueing.CallRules, queue *[]Entry, history *[]Entry, db *sql.DB) (Entry, error) {
 	if len(*queue) == 0 {
-		return fmt.Errorf("queue is empty")
+		return Entry{}, ErrQueueEmpty
 	}
 
-	sort.Sort(ByJoinTime(*queue))
+	sort.Sort(ByQueueOrder(*queue))
+	index, passed, err := queueing.NextFor(counter, rules, *queue)
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := passOver(passed, *queue, db); err != nil {
+		return Entry{}, err
+	}
+	return notifyEntry((*queue)[index].ID, counter, queue, history, db)
+}
 
-	if (*queue)[0].Status == StatusWaiting {
-		(*queue)[0].Status = StatusNotified
-		// Update status in database
-		if err := updateStatusByEntry(db, (*queue)[0]); err != nil {
-			return fmt.Errorf("failed to update status in database: %w", err)
-		}
-		*history = append(*history, (*queue)[0])
-		*queue = slices.Delete(*queue, 0, 1)
+// passOver counts one more skip for each party at the given queue indexes
+func passOver(indexes []int, queue []Entry, db *sql.DB) error {
+	if len(indexes) == 0 {
 		return nil
 	}
 
-	return fmt.Errorf("next entry is not in waiting status")
+	ids := make([]int, len(indexes))
+	for i, index := range indexes {
+		ids[i] = queue[index].ID
+	}
+	if err := addSkips(db, ids); err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		queue[index].Skips++
+	}
+	return nil
+}
+
+// notifyEntry calls a specific waiting entry, wherever it is in the queue
+func notifyEntry(id int, counter *Counter, queue *[]Entry, history *[]Entry, db *sql.DB) (Entry, error) {
+	notified, rest, err := queueing.Call(*queue, id, counter, time.Now())
+	if err != nil {
+		return Entry{}, err
+	}
+	if err := saveCall(db, StatusWaiting, notified); err != nil {
+		return Entry{}, err
+	}
+
+	*history = append(*history, notified)
+	*queue = rest
+	queueEvents.emit(EventNotified, notified)
+	return notified, nil
+}
+
+// markServed records that a notified customer has been served, at counter if it is not nil
+func markServed(entry *Entry, counter *Counter, db *sql.DB) error {
+	if err := setCallStatus(db, entry, StatusServed, nil, counter); err != nil {
+		return err
+	}
+
+	queueEvents.emit(EventServed, *entry)
+	return nil
+}
+
+// markNoShow records that a notified customer never came to be served
+func markNoShow(entry *Entry, db *sql.DB) error {
+	if err := setStatus(db, entry, StatusNoShow); err != nil {
+		return err
+	}
+
+	queueEvents.emit(EventNoShow, *entry)
+	return nil
 }
 
-func markServed(entry *Entry, db *sql.DB) error {
-	if entry == nil {
-		return fmt.Errorf("entry is nil")
+// cancelEntry takes a customer out of the queue or off the waitlist, or
+// cancels their appointment
+func cancelEntry(id int, queue *[]Entry, db *sql.DB) error {
+	index := slices.IndexFunc(*queue, func(e Entry) bool { return e.ID == id })
+	if index == -1 {
+		return cancelOutsideQueue(id, db)
+	}
+
+	cancelled := (*queue)[index]
+	if err := setStatus(db, &cancelled, StatusCancelled); err != nil {
+		return err
+	}
+
+	*queue = slices.Delete(*queue, index, index+1)
+	queueEvents.emit(EventCancelled, cancelled)
+	return nil
+}
+
+// cancelOutsideQueue cancels a waitlisted or booked entry
+func cancelOutsideQueue(id int, db *sql.DB) error {
+	entry, err := getEntryByID(db, id)
+	if err != nil {
+		return err
+	}
+	if entry.Status != StatusWaitlisted && entry.Status != StatusBooked {
+		return &queueing.TransitionError{ID: id, From: entry.Status, To: StatusCancelled}
+	}
+
+	if err := setStatus(db, &entry, StatusCancelled); err != nil {
+		return err
 	}
 
-	entry.Status = StatusServed
-	return updateStatusByEntry(db, *entry)
+	queueEvents.emit(EventCancelled, entry)
+	return nil
 }
 
 func addEntry(entry Entry, queue *[]Entry, db *sql.DB) (int, error) {
@@ -48,22 +137,182 @@ func addEntry(entry Entry, queue *[]Entry, db *sql.DB) (int, error) {
 		return 0, fmt.Errorf("entry must be in waiting status")
 	}
 
-	id, err := insertEntry(db, entry)
-	if err != nil {
+	if err := insertEntry(db, &entry); err != nil {
 		return 0, fmt.Errorf("failed to insert entry: %w", err)
 	}
 
-	entry.ID = id
 	*queue = append(*queue, entry)
-	sort.Sort(ByJoinTime(*queue))
+	sort.Sort(ByQueueOrder(*queue))
+	queueEvents.emit(EventJoined, entry)
 
-	return id, nil
+	return entry.ID, nil
 }
 
-func clearQueueInMemory(queue *[]Entry, db *sql.DB) error {
-	if err := clearQueue(db); err != nil {
-		return fmt.Errorf("failed to clear queue in database: %w", err)
+// addPendingEntry stores an entry that must verify its phone before it joins
+// the queue, or before its appointment is booked
+func addPendingEntry(entry Entry, db *sql.DB) (int, error) {
+	if entry.Status != StatusPending {
+		return 0, fmt.Errorf("entry must be in pending status")
+	}
+
+	insert := insertEntry
+	if queueing.IsAppointment(entry) {
+		insert = insertAppointment
+	}
+	if err := insert(db, &entry); err != nil {
+		return 0, fmt.Errorf("failed to insert entry: %w", err)
+	}
+	return entry.ID, nil
+}
+
+// activateEntry moves a verified pending entry into the queue. Its place is
+// taken from the moment of verification, not from the original join request.
+func activateEntry(entry *Entry, queue *[]Entry, db *sql.DB) error {
+	now := time.Now()
+	if err := moveToBack(db, entry, StatusWaiting, &now); err != nil {
+		return err
+	}
+
+	*queue = append(*queue, *entry)
+	sort.Sort(ByQueueOrder(*queue))
+	queueEvents.emit(EventJoined, *entry)
+	return nil
+}
+
+// clearQueueInMemory cancels every waiting and waitlisted entry and returns
+// them
+func clearQueueInMemory(queue *[]Entry, db *sql.DB) ([]Entry, error) {
+	cancelled, err := cancelAll(db, StatusWaiting, StatusWaitlisted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to clear queue in database: %w", err)
 	}
 	*queue = []Entry{}
+	for _, entry := range cancelled {
+		queueEvents.emit(EventCancelled, entry)
+	}
+	queueEvents.publish(QueueEvent{Type: EventCleared, Count: len(cancelled), Time: time.Now()})
+	return cancelled, nil
+}
+
+// updateEntry saves new details for a waiting entry and refreshes the queue copy
+func updateEntry(entry Entry, queue *[]Entry, db *sql.DB) error {
+	index := slices.IndexFunc(*queue, func(e Entry) bool { return e.ID == entry.ID })
+	if index == -1 {
+		return fmt.Errorf("entry %d is not waiting in the queue", entry.ID)
+	}
+
+	if err := updateEntryDetails(db, entry); err != nil {
+		return err
+	}
+
+	(*queue)[index] = entry
+	queueEvents.emit(EventUpdated, entry)
+	return nil
+}
+
+// delayEntry moves a waiting entry back by up to spots places
+func delayEntry(id int, spots int, queue *[]Entry, db *sql.DB) error {
+	sort.Sort(ByQueueOrder(*queue))
+
+	index := slices.IndexFunc(*queue, func(e Entry) bool { return e.ID == id })
+	if index == -1 {
+		return fmt.Errorf("entry %d is not waiting in the queue", id)
+	}
+
+	delayed, moved, err := placeEntry(index, index+spots, queue, db)
+	if err != nil || !moved {
+		return err
+	}
+
+	queueEvents.emit(EventDelayed, delayed)
 	return nil
 }
+
+// moveEntry puts a waiting entry at a 1-based position in the queue,
+// clamped to the front and back
+func moveEntry(id int, position int, queue *[]Entry, db *sql.DB) (Entry, error) {
+	sort.Sort(ByQueueOrder(*queue))
+
+	index := slices.IndexFunc(*queue, func(e Entry) bool { return e.ID == id })
+	if index == -1 {
+		return Entry{}, fmt.Errorf("entry %d is not waiting in the queue", id)
+	}
+
+	entry, moved, err := placeEntry(index, position-1, queue, db)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if moved {
+		queueEvents.emit(EventMoved, entry)
+	}
+	return entry, nil
+}
+
+// placeEntry moves the entry at index of the sorted queue so that it ends up
+// at index target and saves its new key, or the whole renumbered queue (see
+// queueing.Place).
+func placeEntry(index, target int, queue *[]Entry, db *sql.DB) (Entry, bool, error) {
+	placed, at, renumbered := queueing.Place(*queue, index, target)
+	if at == index {
+		return (*queue)[index], false, nil
+	}
+
+	var err error
+	if renumbered {
+		err = renumberQueue(db, placed)
+	} else {
+		err = updateQueueOrder(db, placed[at])
+	}
+	if err != nil {
+		return Entry{}, false, err
+	}
+
+	*queue = placed
+	return placed[at], true, nil
+}
+
+// confirmEntry records that a waiting or notified customer is on their way
+func confirmEntry(entry *Entry, db *sql.DB) error {
+	if entry.Status != StatusWaiting && entry.Status != StatusNotified {
+		return fmt.Errorf("entry %d is not active", entry.ID)
+	}
+
+	now := time.Now()
+	entry.ConfirmedAt = &now
+	if err := updateConfirmedAt(db, *entry); err != nil {
+		return err
+	}
+
+	queueEvents.emit(EventConfirmed, *entry)
+	return nil
+}
+
+// queuePosition returns the 1-based position of a waiting entry, or 0 if it
+// is no longer waiting
+func queuePosition(queue []Entry, entry Entry) int {
+	return queueing.Position(queue, entry)
+}
+
+// entriesAhead returns the waiting entries called before entry
+func entriesAhead(queue []Entry, entry Entry) []Entry {
+	var ahead []Entry
+	for _, e := range queue {
+		if e.Status == StatusWaiting && e.ID != entry.ID && queueing.AheadOf(e, entry) {
+			ahead = append(ahead, e)
+		}
+	}
+	return ahead
+}
+
+// ServiceTime lives in queueing with the wait estimate
+type ServiceTime = queueing.ServiceTime
+
+// guests counts the people in the given parties
+func guests(entries []Entry) int {
+	n := 0
+	for _, e := range entries {
+		n += e.PartySize
+	}
+	return n
+}
@@ -0,0 +1,130 @@
+package queueing
+
+import (
+	"fmt"
+	"time"
+)
+
+// Appointment priorities: where an appointment goes among the walk-ins when
+// it enters the queue
+const (
+	// PriorityFirst puts appointments ahead of every walk-in
+	PriorityFirst = "first"
+	// PriorityAlternate calls one appointment after every WalkIns walk-ins
+	PriorityAlternate = "alternate"
+	// PriorityNone puts appointments at the back of the queue like walk-ins
+	PriorityNone = "none"
+)
+
+// AppointmentPolicy sets how appointments are booked and how they share the
+// queue with walk-ins
+type AppointmentPolicy struct {
+	// SlotLength is the length of a slot; slots start on multiples of it
+	SlotLength time.Duration
+	PerSlot    int
+	Priority   string
+	WalkIns    int
+	// NoShowAfter is how long after its slot starts an appointment that has
+	// not checked in becomes a no-show
+	NoShowAfter time.Duration
+}
+
+// ParseAppointmentPolicy checks the appointment settings
+func ParseAppointmentPolicy(slotLength time.Duration, perSlot int, priority string, walkIns int, noShowAfter time.Duration) (AppointmentPolicy, error) {
+	if slotLength < time.Minute || time.Hour%slotLength != 0 && slotLength%time.Hour != 0 {
+		return AppointmentPolicy{}, fmt.Errorf("appointment slot length must divide an hour or be whole hours, got %s", slotLength)
+	}
+	if perSlot < 1 {
+		return AppointmentPolicy{}, fmt.Errorf("appointments per slot must be at least 1, got %d", perSlot)
+	}
+	if priority != PriorityFirst && priority != PriorityAlternate && priority != PriorityNone {
+		return AppointmentPolicy{}, fmt.Errorf("unknown appointment priority %q", priority)
+	}
+	if walkIns < 0 {
+		return AppointmentPolicy{}, fmt.Errorf("walk-ins between appointments must not be negative, got %d", walkIns)
+	}
+	if noShowAfter < 0 {
+		return AppointmentPolicy{}, fmt.Errorf("appointment no-show window must not be negative, got %s", noShowAfter)
+	}
+	return AppointmentPolicy{
+		SlotLength:  slotLength,
+		PerSlot:     perSlot,
+		Priority:    priority,
+		WalkIns:     walkIns,
+		NoShowAfter: noShowAfter,
+	}, nil
+}
+
+// IsAppointment reports whether an entry was booked rather than walking in
+func IsAppointment(entry Entry) bool {
+	return entry.AppointmentAt != nil
+}
+
+// PlaceFor returns the index in the sorted queue where an appointment entering
+// it belongs. Under PriorityAlternate an appointment goes WalkIns walk-ins
+// behind the last appointment waiting or, if none is, behind as many walk-ins
+// as are still owed since the last appointment called (from history).
+func (p AppointmentPolicy) PlaceFor(queue []Entry, history []Entry) int {
+	last := -1
+	for i, e := range queue {
+		if IsAppointment(e) {
+			last = i
+		}
+	}
+
+	switch p.Priority {
+	case PriorityFirst:
+		return last + 1
+	case PriorityAlternate:
+		if last != -1 {
+			return min(last+1+p.WalkIns, len(queue))
+		}
+		called := 0
+		for i := len(history) - 1; i >= 0 && called < p.WalkIns && !IsAppointment(history[i]); i-- {
+			called++
+		}
+		return min(p.WalkIns-called, len(queue))
+	}
+	return len(queue)
+}
+
+// ValidateSlot returns a message for the appointmentAt field, or "" if at is
+// the start of a future slot
+func (p AppointmentPolicy) ValidateSlot(at *time.Time, now time.Time) string {
+	switch {
+	case at == nil:
+		return "Appointment time is required"
+	case !at.After(now):
+		return "Appointment time must be in the future"
+	case !at.Truncate(p.SlotLength).Equal(*at):
+		return fmt.Sprintf("Appointment time must be the start of a slot, every %s", p.SlotLength)
+	}
+	return ""
+}
+
+// What to do with a booked appointment whose slot has started
+const (
+	DueWait   = "wait"
+	DueAdmit  = "admit"
+	DueNoShow = "no_show"
+)
+
+// Due decides what happens to a booked appointment at now. A checked in one
+// enters the queue once its slot starts, but only while the queue is open;
+// otherwise it waits for the queue to open. One not checked in NoShowAfter
+// into its slot is a no-show.
+func (p AppointmentPolicy) Due(entry Entry, now time.Time, queueOpen bool) string {
+	if entry.AppointmentAt == nil || now.Before(*entry.AppointmentAt) {
+		return DueWait
+	}
+	if entry.CheckedInAt != nil {
+		if queueOpen {
+			return DueAdmit
+		}
+		return DueWait
+	}
+	if now.Sub(*entry.AppointmentAt) >= p.NoShowAfter {
+		return DueNoShow
+	}
+	return DueWait
+}
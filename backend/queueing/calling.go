@@ -0,0 +1,151 @@
+package queueing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+var (
+	// ErrNoEligibleEntry means people are waiting, but none the counter can serve
+	ErrNoEligibleEntry = errors.New("no waiting entry matches the counter's skills")
+	// ErrNoPartyFits means people are waiting, but no party small enough for the seats
+	ErrNoPartyFits = errors.New("no waiting party fits the seats")
+	// ErrNotArrived means people are waiting, but none the counter could call has arrived
+	ErrNotArrived = errors.New("nobody waiting has arrived")
+)
+
+// Counter is a desk customers are called to. Each operator holds at most one.
+type Counter struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Skills are the service types the counter serves; none means every type.
+	// With Overflow set it also calls other customers when nobody matches.
+	Skills   []string `json:"skills"`
+	Overflow bool     `json:"overflow"`
+	// Seats is the largest party the counter, such as a table, can take; 0 means any
+	Seats     int        `json:"seats"`
+	ClaimedBy string     `json:"-"`
+	ClaimedAt *time.Time `json:"claimedAt,omitempty"`
+	// Claimed is true when any operator holds the counter, Mine when the caller does
+	Claimed bool `json:"claimed"`
+	Mine    bool `json:"mine"`
+	// Current is the customer most recently called to the counter and not yet served
+	Current   *Entry    `json:"current,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Handles reports whether the counter serves a service type. A counter
+// without skills serves everything, and entries that joined without a
+// service type can go to any counter.
+func (c Counter) Handles(serviceType string) bool {
+	return len(c.Skills) == 0 || serviceType == "" || slices.Contains(c.Skills, serviceType)
+}
+
+// PassedOverError reports a party that has been skipped as often as the
+// policy allows and now holds back smaller parties behind it
+type PassedOverError struct {
+	Entry Entry
+}
+
+func (e *PassedOverError) Error() string {
+	return fmt.Sprintf("party of %d (entry %d) has been passed over too often", e.Entry.PartySize, e.Entry.ID)
+}
+
+func (e *PassedOverError) Unwrap() error {
+	return ErrNoPartyFits
+}
+
+// Fits reports whether a party can sit at seats; zero seats fit anyone
+func Fits(entry Entry, seats int) bool {
+	return seats == 0 || entry.PartySize <= seats
+}
+
+// CallRules limit who can be called next
+type CallRules struct {
+	// Seats is the largest party that fits; 0 fits any party
+	Seats int
+	// SkipLimit is how many times a party too large for the seats may be
+	// passed over by smaller parties behind it
+	SkipLimit int
+	// ArrivedOnly passes over customers who have not confirmed they are on
+	// site. They keep their place.
+	ArrivedOnly bool
+}
+
+// NextFor returns the index in a sorted queue of the entry a counter, which
+// may be nil, should call: the first one it has the skills for that fits
+// the rules or, failing that and if the counter takes overflow, the first one
+// that fits. It also returns the indexes of the parties passed over for being
+// too large. The error says why nobody can be called.
+func NextFor(counter *Counter, rules CallRules, queue []Entry) (int, []int, error) {
+	handled := func(e Entry) bool { return counter == nil || counter.Handles(e.ServiceType) }
+	present := func(e Entry) bool { return !rules.ArrivedOnly || e.ArrivedAt != nil }
+	index, passed, err := firstFitting(queue, func(e Entry) bool { return handled(e) && present(e) }, rules)
+
+	var passedOver *PassedOverError
+	overflow := counter != nil && counter.Overflow
+	if err != nil && !errors.As(err, &passedOver) && overflow {
+		index, passed, err = firstFitting(queue, present, rules)
+	}
+
+	if errors.Is(err, ErrNoEligibleEntry) && rules.ArrivedOnly && (overflow || slices.ContainsFunc(queue, handled)) {
+		return -1, nil, ErrNotArrived
+	}
+	return index, passed, err
+}
+
+// firstFitting returns the first eligible entry that fits the seats, passing
+// over larger parties unless one has already been skipped SkipLimit times
+func firstFitting(queue []Entry, eligible func(Entry) bool, rules CallRules) (int, []int, error) {
+	var passed []int
+	matched := false
+	for i, e := range queue {
+		if !eligible(e) {
+			continue
+		}
+		matched = true
+		if Fits(e, rules.Seats) {
+			return i, passed, nil
+		}
+		if e.Skips >= rules.SkipLimit {
+			return -1, nil, &PassedOverError{Entry: e}
+		}
+		passed = append(passed, i)
+	}
+
+	if !matched {
+		return -1, nil, ErrNoEligibleEntry
+	}
+	return -1, nil, ErrNoPartyFits
+}
+
+// Call returns the waiting entry with the given ID as notified at now, to
+// counter if it is not nil, and the queue without it. Any waiting entry can
+// be called, not only the next one; the rest keep their order.
+func Call(queue []Entry, id int, counter *Counter, now time.Time) (Entry, []Entry, error) {
+	index := slices.IndexFunc(queue, func(e Entry) bool { return e.ID == id })
+	if index == -1 {
+		return Entry{}, nil, fmt.Errorf("entry %d is not waiting in the queue", id)
+	}
+
+	called := queue[index]
+	if err := CheckTransition(called, StatusNotified); err != nil {
+		return Entry{}, nil, err
+	}
+	called.Status = StatusNotified
+	called.CalledAt = &now
+	AtCounter(&called, counter)
+
+	return called, slices.Delete(slices.Clone(queue), index, index+1), nil
+}
+
+// AtCounter records that counter called or served entry; a nil counter leaves it unchanged
+func AtCounter(entry *Entry, counter *Counter) {
+	if counter == nil {
+		return
+	}
+	entry.CounterID = &counter.ID
+	entry.Counter = counter.Name
+}
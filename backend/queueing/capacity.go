@@ -0,0 +1,63 @@
+package queueing
+
+import (
+	"fmt"
+	"time"
+)
+
+// What happens to a customer who joins a full queue
+const (
+	FullReject   = "reject"
+	FullWaitlist = "waitlist"
+)
+
+// ServiceTime is the average time serving a party takes: Party for the
+// first person plus Guest for each one after
+type ServiceTime struct {
+	Party time.Duration
+	Guest time.Duration
+}
+
+// Of returns how long serving a party of partySize takes
+func (s ServiceTime) Of(partySize int) time.Duration {
+	return s.Party + time.Duration(max(partySize-1, 0))*s.Guest
+}
+
+// EstimateWait approximates how long a customer behind the given parties will
+// wait, counting one more average service for their own turn to come.
+func EstimateWait(ahead []Entry, serviceTime ServiceTime) time.Duration {
+	wait := serviceTime.Party
+	for _, e := range ahead {
+		wait += serviceTime.Of(e.PartySize)
+	}
+	return wait
+}
+
+// Capacity limits how many customers wait in the queue. Zero limits are off.
+type Capacity struct {
+	MaxWaiting int
+	// MaxWait caps the estimated wait of the next customer to join
+	MaxWait  time.Duration
+	WhenFull string
+}
+
+func ParseCapacity(maxWaiting int, maxWait time.Duration, whenFull string) (Capacity, error) {
+	if maxWaiting < 0 {
+		return Capacity{}, fmt.Errorf("maximum waiting must not be negative, got %d", maxWaiting)
+	}
+	if maxWait < 0 {
+		return Capacity{}, fmt.Errorf("maximum wait must not be negative, got %s", maxWait)
+	}
+	if whenFull != FullReject && whenFull != FullWaitlist {
+		return Capacity{}, fmt.Errorf("unknown full queue policy %q", whenFull)
+	}
+	return Capacity{MaxWaiting: maxWaiting, MaxWait: maxWait, WhenFull: whenFull}, nil
+}
+
+// Full reports whether the customers waiting in queue fill it
+func (c Capacity) Full(queue []Entry, serviceTime ServiceTime) bool {
+	if c.MaxWaiting > 0 && len(queue) >= c.MaxWaiting {
+		return true
+	}
+	return c.MaxWait > 0 && EstimateWait(queue, serviceTime) > c.MaxWait
+}
@@ -0,0 +1,44 @@
+package queueing
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrCounterClaimed means another operator holds the counter
+	ErrCounterClaimed = errors.New("counter is claimed by another operator")
+	// ErrCounterNotFound means the counter to claim does not exist
+	ErrCounterNotFound = errors.New("counter not found")
+)
+
+// Claim gives the counter with the given ID to operator at now. counters
+// must include it and every counter the operator already holds. An operator
+// holds one counter at a time, so Claim also returns the IDs of the others
+// to free. Claiming a counter the operator already holds renews the claim;
+// one held by someone else must be released first.
+func Claim(counters []Counter, id int, operator string, now time.Time) (Counter, []int, error) {
+	var claimed *Counter
+	var release []int
+	for i, c := range counters {
+		switch {
+		case c.ID == id:
+			claimed = &counters[i]
+		case c.ClaimedBy == operator:
+			release = append(release, c.ID)
+		}
+	}
+
+	if claimed == nil {
+		return Counter{}, nil, ErrCounterNotFound
+	}
+	if claimed.ClaimedBy != "" && claimed.ClaimedBy != operator {
+		return Counter{}, nil, ErrCounterClaimed
+	}
+
+	counter := *claimed
+	counter.ClaimedBy = operator
+	counter.ClaimedAt = &now
+	counter.Claimed = true
+	return counter, release, nil
+}
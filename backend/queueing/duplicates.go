@@ -0,0 +1,34 @@
+package queueing
+
+import "fmt"
+
+// How /join treats a phone number that already has an active entry
+const (
+	DuplicateReject = "reject"
+	DuplicateReturn = "return"
+	DuplicateAllow  = "allow"
+)
+
+type DuplicatePolicy struct {
+	Mode string
+	// MaxActive is the number of active entries a phone may hold under DuplicateAllow
+	MaxActive int
+}
+
+func ParseDuplicatePolicy(mode string, maxActive int) (DuplicatePolicy, error) {
+	switch mode {
+	case DuplicateReject, DuplicateReturn:
+		return DuplicatePolicy{Mode: mode, MaxActive: 1}, nil
+	case DuplicateAllow:
+		if maxActive < 1 {
+			return DuplicatePolicy{}, fmt.Errorf("duplicate join limit must be at least 1, got %d", maxActive)
+		}
+		return DuplicatePolicy{Mode: mode, MaxActive: maxActive}, nil
+	}
+	return DuplicatePolicy{}, fmt.Errorf("unknown duplicate join policy %q", mode)
+}
+
+// Allows reports whether a phone that already holds active entries may join again
+func (p DuplicatePolicy) Allows(active int) bool {
+	return p.Mode == DuplicateAllow && active < p.MaxActive
+}
@@ -0,0 +1,52 @@
+// Package queueing holds the queue's entries and the rules for how they move:
+// statuses and the transitions between them.
+package queueing
+
+import (
+	"time"
+
+	"wait-to-go/notify"
+)
+
+type Entry struct {
+	ID                  int            `json:"id"`
+	FirstName           string         `json:"firstName"`
+	LastName            string         `json:"lastName"`
+	Email               string         `json:"email"`
+	PhoneNumber         string         `json:"phoneNumber"`
+	Status              string         `json:"status"`
+	JoinTime            time.Time      `json:"joinTime"`
+	NotificationChannel notify.Channel `json:"notificationChannel"`
+	ConfirmedAt         *time.Time     `json:"confirmedAt,omitempty"`
+	Notes               string         `json:"notes"`
+	// ServiceType is what the customer came for, one of SERVICE_TYPES
+	ServiceType string `json:"serviceType,omitempty"`
+	// CalledAt is when the entry was last notified, Counter and CounterID
+	// the desk that called or served it
+	CalledAt  *time.Time `json:"calledAt,omitempty"`
+	CounterID *int       `json:"counterId,omitempty"`
+	Counter   string     `json:"counter,omitempty"`
+	// AppointmentAt is the start of the booked slot, for appointments only;
+	// CheckedInAt is when the customer arrived for it
+	AppointmentAt *time.Time `json:"appointmentAt,omitempty"`
+	CheckedInAt   *time.Time `json:"checkedInAt,omitempty"`
+	// ArrivedAt is when the customer confirmed they are on site (see ArrivalCodes)
+	ArrivedAt *time.Time `json:"arrivedAt,omitempty"`
+	// PartySize is how many people the entry is for, such as a table for 4
+	PartySize int `json:"partySize"`
+	// QueueOrder orders waiting entries; lower is served first
+	QueueOrder int64 `json:"-"`
+	// Skips counts how often the party was passed over for being too large (see PartyPolicy)
+	Skips int `json:"-"`
+}
+
+const (
+	StatusPending    = "pending"
+	StatusBooked     = "booked"
+	StatusWaitlisted = "waitlisted"
+	StatusWaiting    = "waiting"
+	StatusNotified   = "notified"
+	StatusServed     = "served"
+	StatusCancelled  = "cancelled"
+	StatusNoShow     = "no_show"
+)
@@ -0,0 +1,223 @@
+package queueing
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// entrySorts maps the sort query parameter to the entry column it orders by.
+// A leading "-" sorts descending.
+var entrySorts = map[string]string{
+	"position":  "queueOrder",
+	"joinTime":  "joinTime",
+	"lastName":  "lastName",
+	"firstName": "firstName",
+	"id":        "id",
+}
+
+var entryStatuses = []string{StatusPending, StatusBooked, StatusWaitlisted, StatusWaiting, StatusNotified, StatusServed, StatusNoShow, StatusCancelled}
+
+// EntryFilter selects and orders a page of entries for the admin listing
+type EntryFilter struct {
+	Statuses     []string
+	ServiceTypes []string
+	// Search matches a substring of the name, phone number or email
+	Search string
+	// JoinedFrom (inclusive) and JoinedTo (exclusive) bound joinTime; either may be zero
+	JoinedFrom time.Time
+	JoinedTo   time.Time
+	// Sort is one of the entrySorts columns, never raw user input
+	Sort       string
+	Descending bool
+	Limit      int
+	After      *EntryCursor
+}
+
+// EntryCursor marks the last row of a page: its sort column value and ID,
+// which together order entries uniquely
+type EntryCursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+type EntryPage struct {
+	Entries    []Entry `json:"entries"`
+	Total      int     `json:"total"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// sortKey identifies the sort column and direction in cursors, for example "-joinTime"
+func (f EntryFilter) sortKey() string {
+	if f.Descending {
+		return "-" + f.Sort
+	}
+	return f.Sort
+}
+
+func (c EntryCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeEntryCursor(s string) (*EntryCursor, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, false
+	}
+	var c EntryCursor
+	if err := json.Unmarshal(b, &c); err != nil || c.ID == 0 {
+		return nil, false
+	}
+	return &c, true
+}
+
+// sortValue is the cursor value of an entry for a sort column
+func sortValue(entry Entry, column string) string {
+	switch column {
+	case "lastName":
+		return entry.LastName
+	case "firstName":
+		return entry.FirstName
+	case "id":
+		return strconv.Itoa(entry.ID)
+	case "queueOrder":
+		return strconv.FormatInt(entry.QueueOrder, 10)
+	}
+	return entry.JoinTime.UTC().Format(time.RFC3339Nano)
+}
+
+// CursorValue is the After cursor's sort column value, typed for comparing
+// against the column
+func (f EntryFilter) CursorValue() (any, error) {
+	switch f.Sort {
+	case "joinTime":
+		t, err := time.Parse(time.RFC3339Nano, f.After.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor time: %w", err)
+		}
+		return t, nil
+	case "queueOrder":
+		n, err := strconv.ParseInt(f.After.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor position: %w", err)
+		}
+		return n, nil
+	case "id":
+		return f.After.ID, nil
+	}
+	return f.After.Value, nil
+}
+
+// Page builds the response from up to Limit+1 rows in order; the extra row
+// only tells us another page exists
+func (f EntryFilter) Page(entries []Entry, total int) EntryPage {
+	page := EntryPage{Entries: entries, Total: total}
+	if page.Entries == nil {
+		page.Entries = []Entry{}
+	}
+	if len(page.Entries) > f.Limit {
+		page.Entries = page.Entries[:f.Limit]
+		last := page.Entries[len(page.Entries)-1]
+		page.NextCursor = EntryCursor{Sort: f.sortKey(), Value: sortValue(last, f.Sort), ID: last.ID}.Encode()
+	}
+	return page
+}
+
+// ParseEntryFilter reads status, serviceType, q, from, to, sort, limit and cursor
+func ParseEntryFilter(query url.Values) (EntryFilter, FieldErrors) {
+	fields := FieldErrors{}
+	filter := EntryFilter{
+		Statuses: []string{StatusWaiting},
+		Search:   strings.TrimSpace(query.Get("q")),
+		Sort:     "queueOrder",
+		Limit:    DefaultPageSize,
+	}
+
+	if status := query.Get("status"); status != "" {
+		filter.Statuses = nil
+		for _, s := range strings.Split(status, ",") {
+			if !slices.Contains(entryStatuses, s) {
+				fields["status"] = "Status must be a comma separated list of " + strings.Join(entryStatuses, ", ")
+				break
+			}
+			filter.Statuses = append(filter.Statuses, s)
+		}
+	}
+
+	if serviceType := query.Get("serviceType"); serviceType != "" {
+		filter.ServiceTypes = strings.Split(serviceType, ",")
+	}
+
+	if from := query.Get("from"); from != "" {
+		t, _, err := parseTimeParam(from)
+		if err != nil {
+			fields["from"] = "From must be an RFC 3339 timestamp or a YYYY-MM-DD date"
+		}
+		filter.JoinedFrom = t
+	}
+	if to := query.Get("to"); to != "" {
+		t, dateOnly, err := parseTimeParam(to)
+		if err != nil {
+			fields["to"] = "To must be an RFC 3339 timestamp or a YYYY-MM-DD date"
+		}
+		// A date includes the whole day
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		}
+		filter.JoinedTo = t
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		name, desc := strings.CutPrefix(sort, "-")
+		column, ok := entrySorts[name]
+		if !ok {
+			fields["sort"] = "Sort must be one of position, joinTime, lastName, firstName or id, optionally prefixed with -"
+		}
+		filter.Sort, filter.Descending = column, desc
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 || n > MaxPageSize {
+			fields["limit"] = "Limit must be between 1 and " + strconv.Itoa(MaxPageSize)
+		}
+		filter.Limit = n
+	}
+
+	if cursor := query.Get("cursor"); cursor != "" {
+		// The cursor is opaque to clients, so one that was edited is as invalid
+		// as one for another sort order
+		c, ok := decodeEntryCursor(cursor)
+		if ok {
+			filter.After = c
+			_, err := filter.CursorValue()
+			ok = err == nil && c.Sort == filter.sortKey()
+		}
+		if !ok {
+			fields["cursor"] = "Cursor is invalid or was issued for a different sort order"
+		}
+	}
+
+	return filter, fields
+}
+
+// parseTimeParam accepts a full timestamp or a date, taken as midnight UTC
+func parseTimeParam(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	t, err = time.Parse(time.DateOnly, value)
+	return t, true, err
+}
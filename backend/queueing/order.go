@@ -0,0 +1,84 @@
+package queueing
+
+import (
+	"slices"
+	"sort"
+)
+
+// QueueOrderGap spaces the ordering keys of entries so one can be moved
+// between two others without renumbering the queue
+const QueueOrderGap = 1024
+
+// ByQueueOrder sorts entries in the order they will be called
+type ByQueueOrder []Entry
+
+func (q ByQueueOrder) Len() int           { return len(q) }
+func (q ByQueueOrder) Less(i, j int) bool { return AheadOf(q[i], q[j]) }
+func (q ByQueueOrder) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+// AheadOf reports whether a is called before b. IDs break ties between equal keys.
+func AheadOf(a, b Entry) bool {
+	if a.QueueOrder != b.QueueOrder {
+		return a.QueueOrder < b.QueueOrder
+	}
+	return a.ID < b.ID
+}
+
+// Place moves the entry at index of the sorted queue so that it ends up at
+// index target, clamped to the queue. The entry gets a key between its new
+// neighbours; when they are adjacent every entry is renumbered QueueOrderGap
+// apart and renumbered is true. It returns a sorted copy of the queue and
+// where the entry ended up, which is index when it did not move.
+func Place(queue []Entry, index, target int) (placed []Entry, at int, renumbered bool) {
+	entry := queue[index]
+	rest := slices.Delete(slices.Clone(queue), index, index+1)
+	target = max(0, min(target, len(rest)))
+	if target == index {
+		return slices.Clone(queue), index, false
+	}
+
+	var prev, next *Entry
+	if target > 0 {
+		prev = &rest[target-1]
+	}
+	if target < len(rest) {
+		next = &rest[target]
+	}
+
+	switch {
+	case next == nil:
+		entry.QueueOrder = prev.QueueOrder + QueueOrderGap
+	case prev == nil:
+		entry.QueueOrder = next.QueueOrder - QueueOrderGap
+	default:
+		entry.QueueOrder = prev.QueueOrder + (next.QueueOrder-prev.QueueOrder)/2
+	}
+
+	fits := (prev == nil || AheadOf(*prev, entry)) && (next == nil || AheadOf(entry, *next))
+	ordered := slices.Insert(rest, target, entry)
+	if !fits {
+		for i := range ordered {
+			ordered[i].QueueOrder = int64(i+1) * QueueOrderGap
+		}
+		return ordered, target, true
+	}
+
+	sort.Sort(ByQueueOrder(ordered))
+	return ordered, target, false
+}
+
+// Position is the 1-based place of a waiting entry among the waiting entries
+// of queue, or 0 if it is not waiting
+func Position(queue []Entry, entry Entry) int {
+	if entry.Status != StatusWaiting {
+		return 0
+	}
+
+	position := 0
+	for _, e := range queue {
+		if e.Status == StatusWaiting && e.ID != entry.ID && AheadOf(e, entry) {
+			position++
+		}
+	}
+	return position + 1 // Add 1 because we want 1-based position
+}
@@ -0,0 +1,45 @@
+package queueing
+
+import "strconv"
+
+// EntryPatch is a customer's change to their own entry. Nil fields are left
+// as they are.
+type EntryPatch struct {
+	FirstName *string `json:"firstName"`
+	LastName  *string `json:"lastName"`
+	Email     *string `json:"email"`
+	Notes     *string `json:"notes"`
+	PartySize *int    `json:"partySize"`
+}
+
+// FieldChange records the before and after value of a single field
+type FieldChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Apply returns entry with the patch applied and the fields that actually
+// changed, keyed by JSON field name. Setting a field to its current value is
+// not a change.
+func (p EntryPatch) Apply(entry Entry) (Entry, map[string]FieldChange) {
+	changes := map[string]FieldChange{}
+	for field, change := range map[string]struct {
+		value  *string
+		target *string
+	}{
+		"firstName": {p.FirstName, &entry.FirstName},
+		"lastName":  {p.LastName, &entry.LastName},
+		"email":     {p.Email, &entry.Email},
+		"notes":     {p.Notes, &entry.Notes},
+	} {
+		if change.value != nil && *change.value != *change.target {
+			changes[field] = FieldChange{From: *change.target, To: *change.value}
+			*change.target = *change.value
+		}
+	}
+	if p.PartySize != nil && *p.PartySize != entry.PartySize {
+		changes["partySize"] = FieldChange{From: strconv.Itoa(entry.PartySize), To: strconv.Itoa(*p.PartySize)}
+		entry.PartySize = *p.PartySize
+	}
+	return entry, changes
+}
@@ -0,0 +1,135 @@
+package queueing
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// weekdays are the keys of Schedule.Weekly
+var weekdays = []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// OpeningHours is one opening period of a day in "HH:MM" local time. Close
+// may be "24:00"; periods cannot run past midnight.
+type OpeningHours struct {
+	Open  string `json:"open"`
+	Close string `json:"close"`
+}
+
+// Schedule opens and closes the queue automatically
+type Schedule struct {
+	Timezone string                    `json:"timezone"`
+	Weekly   map[string][]OpeningHours `json:"weekly"`
+	// CancelAtClose cancels everyone still waiting when the queue closes on schedule
+	CancelAtClose bool `json:"cancelAtClose"`
+}
+
+// Holiday closes the queue for a whole day, overriding the weekly hours
+type Holiday struct {
+	Date   string `json:"date"`
+	Reason string `json:"reason"`
+}
+
+// parseClock reads "HH:MM" as minutes after midnight
+func parseClock(s string) (int, bool) {
+	if s == "24:00" {
+		return 24 * 60, true
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, false
+	}
+	return t.Hour()*60 + t.Minute(), true
+}
+
+// Validate checks the time zone and that every period opens before it
+// closes, returning problems by JSON field
+func (s Schedule) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if _, err := time.LoadLocation(s.Timezone); err != nil || s.Timezone == "" {
+		fields["timezone"] = "Timezone must be an IANA time zone such as America/New_York"
+	}
+
+	for day, periods := range s.Weekly {
+		if !slices.Contains(weekdays, day) {
+			fields["weekly"] = "Days must be " + strings.Join(weekdays, ", ")
+			continue
+		}
+		for _, p := range periods {
+			opens, ok1 := parseClock(p.Open)
+			closes, ok2 := parseClock(p.Close)
+			if !ok1 || !ok2 || opens >= closes {
+				fields["weekly."+day] = "Each period needs an open time before its close time, as HH:MM"
+			}
+		}
+	}
+	return fields
+}
+
+func (s Schedule) location() *time.Location {
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// OpenAt reports whether the schedule has the queue open at t
+func (s Schedule) OpenAt(t time.Time, holidays []Holiday) bool {
+	local := t.In(s.location())
+	if isHoliday(local, holidays) {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	for _, p := range s.Weekly[weekdays[local.Weekday()]] {
+		opens, _ := parseClock(p.Open)
+		closes, _ := parseClock(p.Close)
+		if minute >= opens && minute < closes {
+			return true
+		}
+	}
+	return false
+}
+
+// NextChange returns when the schedule next opens or closes the queue after
+// t, looking up to two weeks ahead. It returns the zero time if it never does.
+func (s Schedule) NextChange(t time.Time, holidays []Holiday) time.Time {
+	loc := s.location()
+	local := t.In(loc)
+	openNow := s.OpenAt(t, holidays)
+
+	var boundaries []time.Time
+	for d := 0; d <= 14; d++ {
+		day := time.Date(local.Year(), local.Month(), local.Day()+d, 0, 0, 0, 0, loc)
+		for _, p := range s.Weekly[weekdays[day.Weekday()]] {
+			for _, clock := range []string{p.Open, p.Close} {
+				// Build the wall clock time rather than adding a duration to
+				// midnight, which is off by the shift on daylight saving days
+				minute, _ := parseClock(clock)
+				at := time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, loc)
+				if at.After(t) {
+					boundaries = append(boundaries, at)
+				}
+			}
+		}
+		// Holidays start and end at midnight
+		if d > 0 {
+			boundaries = append(boundaries, day)
+		}
+	}
+	slices.SortFunc(boundaries, func(a, b time.Time) int { return a.Compare(b) })
+
+	for _, at := range boundaries {
+		if s.OpenAt(at, holidays) != openNow {
+			return at
+		}
+	}
+	return time.Time{}
+}
+
+func isHoliday(local time.Time, holidays []Holiday) bool {
+	date := local.Format(time.DateOnly)
+	return slices.ContainsFunc(holidays, func(h Holiday) bool { return h.Date == date })
+}
@@ -0,0 +1,53 @@
+package queueing
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInvalidTransition is matched by every TransitionError
+var ErrInvalidTransition = errors.New("invalid status transition")
+
+// transitions lists the statuses each status may move to. Served, no-show
+// and cancelled are final.
+var transitions = map[string][]string{
+	StatusPending:    {StatusWaiting, StatusWaitlisted, StatusBooked, StatusCancelled},
+	StatusBooked:     {StatusWaiting, StatusNoShow, StatusCancelled},
+	StatusWaitlisted: {StatusWaiting, StatusCancelled},
+	StatusWaiting:    {StatusNotified, StatusCancelled},
+	StatusNotified:   {StatusServed, StatusNoShow, StatusCancelled},
+}
+
+// RecoverableStatuses are the statuses session recovery issues a new token
+// for: everyone still due to be served, including a booked appointment that
+// needs its token to check in
+var RecoverableStatuses = []string{StatusBooked, StatusWaitlisted, StatusWaiting, StatusNotified}
+
+// TransitionError reports a status change the state machine does not allow
+type TransitionError struct {
+	ID   int
+	From string
+	To   string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("entry %d cannot go from %s to %s", e.ID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
+
+// CanTransition reports whether an entry may move from one status to another
+func CanTransition(from, to string) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// CheckTransition returns a TransitionError if entry may not move to status to
+func CheckTransition(entry Entry, to string) error {
+	if !CanTransition(entry.Status, to) {
+		return &TransitionError{ID: entry.ID, From: entry.Status, To: to}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package queueing
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+
+	"wait-to-go/notify"
+	"wait-to-go/phone"
+)
+
+// FieldErrors maps a JSON field name to a human readable problem with it
+type FieldErrors map[string]string
+
+// ValidateEntry checks and normalizes the customer supplied fields of an entry.
+// The phone number is rewritten to E.164 and the notification channel defaulted.
+func ValidateEntry(entry *Entry, region string) FieldErrors {
+	fields := FieldErrors{}
+
+	if entry.FirstName == "" {
+		fields["firstName"] = "First name is required"
+	} else if len(entry.FirstName) > 30 {
+		fields["firstName"] = "First name must be at most 30 characters"
+	}
+
+	if entry.LastName == "" {
+		fields["lastName"] = "Last name is required"
+	} else if len(entry.LastName) > 30 {
+		fields["lastName"] = "Last name must be at most 30 characters"
+	}
+
+	if entry.Email != "" {
+		if len(entry.Email) > 50 {
+			fields["email"] = "Email must be at most 50 characters"
+		} else if _, err := mail.ParseAddress(entry.Email); err != nil {
+			fields["email"] = "Email is not a valid address"
+		}
+	}
+
+	if len(entry.Notes) > 200 {
+		fields["notes"] = "Notes must be at most 200 characters"
+	}
+
+	normalized, err := phone.Normalize(entry.PhoneNumber, region)
+	switch {
+	case errors.Is(err, phone.ErrEmpty):
+		fields["phoneNumber"] = "Phone number is required"
+	case errors.Is(err, phone.ErrInvalidChars):
+		fields["phoneNumber"] = "Phone number may only contain digits, spaces, dashes, dots, parentheses and a leading +"
+	case err != nil:
+		fields["phoneNumber"] = "Phone number is not a valid number"
+	default:
+		entry.PhoneNumber = normalized
+	}
+
+	channel, ok := notify.ParseChannel(string(entry.NotificationChannel))
+	if !ok {
+		fields["notificationChannel"] = "Notification channel must be sms, email or none"
+	} else if channel == notify.ChannelEmail && entry.Email == "" {
+		fields["notificationChannel"] = "An email address is required for email notifications"
+	} else {
+		entry.NotificationChannel = channel
+	}
+
+	return fields
+}
+
+// ValidatePartySize returns a message for the partySize field, or "" if the
+// size is within the limit
+func ValidatePartySize(size, maxSize int) string {
+	if size < 1 || size > maxSize {
+		return fmt.Sprintf("Party size must be between 1 and %d", maxSize)
+	}
+	return ""
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/queueing"
+)
+
+// Queue states. Paused and closed queues reject joins; customers already
+// waiting can still be called.
+const (
+	QueueOpen   = "open"
+	QueuePaused = "paused"
+	QueueClosed = "closed"
+)
+
+// QueueSettings is the stored state and schedule of the queue.
+// ScheduledState is the state the schedule last applied.
+type QueueSettings struct {
+	State          string
+	Message        string
+	ChangedAt      time.Time
+	Schedule       *Schedule
+	ScheduledState string
+}
+
+// QueueInfo describes a queue to customers before they join
+type QueueInfo struct {
+	ID      string `json:"id"`
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+	// OpensAt and ClosesAt are the next scheduled changes, when there is a schedule
+	OpensAt      *time.Time `json:"opensAt,omitempty"`
+	ClosesAt     *time.Time `json:"closesAt,omitempty"`
+	ServiceTypes []string   `json:"serviceTypes"`
+	Waiting      int        `json:"waiting"`
+	// EstimatedWaitMinutes is how long someone joining now can expect to wait
+	EstimatedWaitMinutes int `json:"estimatedWaitMinutes"`
+	// Full means new customers are turned away or waitlisted (see WHEN_FULL)
+	Full       bool `json:"full"`
+	Waitlisted int  `json:"waitlisted"`
+}
+
+// queueInfo describes the queue as of now. The caller must hold queueMu.
+func (a *App) queueInfo() (QueueInfo, error) {
+	settings, err := getQueueSettings(a.db)
+	if err != nil {
+		return QueueInfo{}, err
+	}
+
+	info := QueueInfo{
+		ID:           DefaultQueueID,
+		State:        settings.State,
+		Message:      settings.Message,
+		ServiceTypes: a.serviceTypes,
+		Waiting:      len(*a.queue),
+
+		EstimatedWaitMinutes: int(queueing.EstimateWait(*a.queue, a.serviceTime).Minutes()),
+	}
+	if info.ServiceTypes == nil {
+		info.ServiceTypes = []string{}
+	}
+
+	if info.Waitlisted, err = countWaitlisted(a.db); err != nil {
+		return QueueInfo{}, err
+	}
+	info.Full = info.Waitlisted > 0 || a.capacity.Full(*a.queue, a.serviceTime)
+
+	if settings.Schedule != nil {
+		holidays, err := getHolidays(a.db)
+		if err != nil {
+			return QueueInfo{}, err
+		}
+		now := time.Now()
+		if next := settings.Schedule.NextChange(now, holidays); !next.IsZero() {
+			if settings.Schedule.OpenAt(now, holidays) {
+				info.ClosesAt = &next
+			} else {
+				info.OpensAt = &next
+			}
+		}
+	}
+	return info, nil
+}
+
+// handleGetQueue describes the queue: GET /api/v1/queues/{queue}
+func (a *App) handleGetQueue(w http.ResponseWriter, r *http.Request) {
+	info, err := a.queueInfo()
+	if err != nil {
+		apierror.Error(w, r, "Failed to get queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// checkQueueOpen writes a 409 and returns false unless the queue is open.
+// The error carries the admin's message and, if known, when it next opens.
+func (a *App) checkQueueOpen(w http.ResponseWriter, r *http.Request) bool {
+	info, err := a.queueInfo()
+	if err != nil {
+		apierror.Error(w, r, "Failed to get queue", http.StatusInternalServerError)
+		return false
+	}
+	if info.State == QueueOpen {
+		return true
+	}
+
+	code, message := apierror.CodeQueueClosed, "The queue is closed"
+	if info.State == QueuePaused {
+		code, message = apierror.CodeQueuePaused, "The queue is not taking new customers right now"
+	}
+	if info.Message != "" {
+		message = info.Message
+	}
+
+	details := map[string]string{}
+	if info.OpensAt != nil {
+		details["opensAt"] = info.OpensAt.Format(time.RFC3339)
+	}
+	apierror.ErrorWithDetails(w, r, code, message, http.StatusConflict, details)
+	return false
+}
+
+// changeQueueState saves a new state and, when closing with cancelWaiting,
+// cancels everyone still waiting and tells them. The caller must hold queueMu.
+func (a *App) changeQueueState(state, message string, cancelWaiting bool) error {
+	if err := setQueueState(a.db, state, message, time.Now()); err != nil {
+		return err
+	}
+	queueEvents.publish(QueueEvent{Type: queueStateEvents[state], Time: time.Now()})
+
+	if !cancelWaiting {
+		return nil
+	}
+
+	// Everyone waiting or waitlisted is cancelled and told. The queue only
+	// holds waiting entries, so the rest were waitlisted.
+	waiting := map[int]bool{}
+	for _, entry := range *a.queue {
+		waiting[entry.ID] = true
+	}
+	cancelled, err := clearQueueInMemory(a.queue, a.db)
+	if err != nil {
+		return err
+	}
+	for _, entry := range cancelled {
+		place := "your place in it"
+		if !waiting[entry.ID] {
+			place = "your place on the waitlist"
+		}
+		body := fmt.Sprintf("Hi %s, the queue has closed and %s has been cancelled.", entry.FirstName, place)
+		if message != "" {
+			body += " " + message
+		}
+		msg := customerMessage(entry, "The queue has closed", body)
+		go func() {
+			if err := a.notifier.Send(msg); err != nil {
+				log.Printf("Warning: failed to notify entry %d of closing: %v", entry.ID, err)
+			}
+		}()
+	}
+	return nil
+}
+
+// handleOpenQueue starts accepting joins: POST /api/v1/queues/{queue}:open
+func (a *App) handleOpenQueue(w http.ResponseWriter, r *http.Request) {
+	a.updateQueueState(w, r, QueueOpen)
+}
+
+// handlePauseQueue stops joins for a while, with an optional {"message"}
+// shown to customers who try to join: POST /api/v1/queues/{queue}:pause
+func (a *App) handlePauseQueue(w http.ResponseWriter, r *http.Request) {
+	a.updateQueueState(w, r, QueuePaused)
+}
+
+// handleCloseQueue closes the queue, with an optional {"message"}, and cancels
+// everyone waiting if {"cancelWaiting": true}: POST /api/v1/queues/{queue}:close
+func (a *App) handleCloseQueue(w http.ResponseWriter, r *http.Request) {
+	a.updateQueueState(w, r, QueueClosed)
+}
+
+func (a *App) updateQueueState(w http.ResponseWriter, r *http.Request, state string) {
+	var req struct {
+		Message       string `json:"message"`
+		CancelWaiting bool   `json:"cancelWaiting"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	fields := FieldErrors{}
+	if len(req.Message) > 200 {
+		fields["message"] = "Message must be at most 200 characters"
+	}
+	if req.CancelWaiting && state != QueueClosed {
+		fields["cancelWaiting"] = "Only closing the queue can cancel waiting customers"
+	}
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	if err := a.changeQueueState(state, req.Message, req.CancelWaiting); err != nil {
+		log.Printf("Warning: %v", err)
+		apierror.Error(w, r, "Failed to change queue state", http.StatusInternalServerError)
+		return
+	}
+
+	info, err := a.queueInfo()
+	if err != nil {
+		apierror.Error(w, r, "Failed to get queue", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// lockQueue serializes handlers that read or change the in-memory queue with
+// each other and with the scheduler
+func (a *App) lockQueue(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a.queueMu.Lock()
+		defer a.queueMu.Unlock()
+		next(w, r)
+	}
+}
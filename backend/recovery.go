@@ -0,0 +1,137 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+	"wait-to-go/phone"
+)
+
+const OTPPurposeRecover = "recover"
+
+// handleRecover starts session recovery: POST /api/v1/recovery {"phoneNumber"}.
+// The response is the same whether or not the phone has an active entry, so
+// the endpoint cannot be used to find out who is in the queue.
+func (a *App) handleRecover(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PhoneNumber string `json:"phoneNumber"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	phoneNumber, err := phone.Normalize(req.PhoneNumber, a.phoneRegion)
+	if err != nil {
+		apierror.ValidationError(w, r, map[string]string{"phoneNumber": "Phone number is not a valid number"})
+		return
+	}
+
+	if !a.recoveryLimiter.Allow("ip:"+auth.ClientIP(r)) || !a.recoveryLimiter.Allow("phone:"+phoneNumber) {
+		apierror.Error(w, r, "Too many recovery attempts, please try again later", http.StatusTooManyRequests)
+		return
+	}
+
+	entry, err := getRecoverableEntryByPhone(a.db, phoneNumber)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Nothing to recover; answer as if a code was sent
+	case err != nil:
+		apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		return
+	default:
+		if err := a.sendRecoveryCode(entry); err != nil {
+			log.Printf("Warning: %v", err)
+			apierror.Error(w, r, "Failed to send recovery code", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "code_sent",
+		"expiresIn": int(a.otp.TTL.Seconds()),
+	})
+}
+
+// sendRecoveryCode texts the entry's phone a code for POST /api/v1/recovery:verify
+func (a *App) sendRecoveryCode(entry Entry) error {
+	code, err := a.issueOTP(OTPPurposeRecover, entry.PhoneNumber)
+	if err != nil {
+		return err
+	}
+	a.sendSMS(entry, a.recoveryMessage(entry.PhoneNumber, code))
+	return nil
+}
+
+// recoveryMessage includes a magic link when a recovery page is configured
+func (a *App) recoveryMessage(phoneNumber, code string) string {
+	msg := fmt.Sprintf("Your code to get back to your place in line is %s.", code)
+	if a.recoveryURL == "" {
+		return msg
+	}
+
+	link := a.recoveryURL + "?" + url.Values{"phone": {phoneNumber}, "code": {code}}.Encode()
+	return msg + " Or tap: " + link
+}
+
+// handleRecoverVerify exchanges a recovery code for a new token:
+// POST /api/v1/recovery:verify {"phoneNumber", "code"}
+func (a *App) handleRecoverVerify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		PhoneNumber string `json:"phoneNumber"`
+		Code        string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	phoneNumber, err := phone.Normalize(req.PhoneNumber, a.phoneRegion)
+	if err != nil {
+		apierror.ValidationError(w, r, map[string]string{"phoneNumber": "Phone number is not a valid number"})
+		return
+	}
+	if len(req.Code) != auth.OTPDigits {
+		apierror.ValidationError(w, r, map[string]string{"code": fmt.Sprintf("Code must be %d digits", auth.OTPDigits)})
+		return
+	}
+
+	if remaining, err := a.checkOTP(OTPPurposeRecover, phoneNumber, req.Code); err != nil {
+		writeOTPError(w, r, remaining, err)
+		return
+	}
+
+	// Look the entry up again: it may have been served or cancelled since the code was sent
+	entry, err := getRecoverableEntryByPhone(a.db, phoneNumber)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "No active entry for phone number", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Internal server error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	token, err := auth.GenerateToken(entry.ID, entry.PhoneNumber)
+	if err != nil {
+		apierror.Error(w, r, "Failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":   "success",
+		"id":       entry.ID,
+		"token":    token,
+		"position": queuePosition(*a.queue, entry),
+	})
+}
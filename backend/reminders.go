@@ -0,0 +1,170 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"wait-to-go/notify"
+	"wait-to-go/queueing"
+)
+
+// ReminderRule fires once per entry when its position or estimated wait drops
+// to the configured threshold. A zero threshold is ignored.
+type ReminderRule struct {
+	Name        string
+	MaxPosition int
+	MaxWait     time.Duration
+}
+
+func (r ReminderRule) matches(position int, wait time.Duration) bool {
+	if r.MaxPosition > 0 && position <= r.MaxPosition {
+		return true
+	}
+	if r.MaxWait > 0 && wait <= r.MaxWait {
+		return true
+	}
+	return false
+}
+
+// parseReminderRules reads a comma separated list such as "position<=3,eta<=10m"
+func parseReminderRules(spec string) ([]ReminderRule, error) {
+	var rules []ReminderRule
+
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		kind, value, ok := strings.Cut(part, "<=")
+		if !ok {
+			return nil, fmt.Errorf("invalid reminder rule %q", part)
+		}
+
+		rule := ReminderRule{Name: part}
+		switch strings.TrimSpace(kind) {
+		case "position":
+			n, err := strconv.Atoi(strings.TrimSpace(value))
+			if err != nil || n <= 0 {
+				return nil, fmt.Errorf("invalid position in reminder rule %q", part)
+			}
+			rule.MaxPosition = n
+		case "eta":
+			d, err := time.ParseDuration(strings.TrimSpace(value))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid duration in reminder rule %q", part)
+			}
+			rule.MaxWait = d
+		default:
+			return nil, fmt.Errorf("unknown reminder rule %q", part)
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
+
+type ReminderEngine struct {
+	rules       []ReminderRule
+	serviceTime ServiceTime
+	db          *sql.DB
+	notifier    *notify.Notifier
+
+	// fired caches, per entry ID, the rules already claimed in the database.
+	// Entries are dropped once they leave the queue.
+	fired map[int]map[string]bool
+	mu    sync.Mutex
+}
+
+func NewReminderEngine(db *sql.DB, notifier *notify.Notifier, rules []ReminderRule, serviceTime ServiceTime) *ReminderEngine {
+	return &ReminderEngine{
+		rules:       rules,
+		serviceTime: serviceTime,
+		db:          db,
+		notifier:    notifier,
+		fired:       make(map[int]map[string]bool),
+	}
+}
+
+// Evaluate checks every waiting entry against the rules and sends any reminders
+// that have not been sent before.
+func (re *ReminderEngine) Evaluate(queue []Entry) {
+	if len(re.rules) == 0 {
+		return
+	}
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+
+	waiting := waitingEntries(queue)
+	sort.Sort(ByQueueOrder(waiting))
+	re.prune(waiting)
+
+	for i, entry := range waiting {
+		position := i + 1
+		wait := queueing.EstimateWait(waiting[:i], re.serviceTime)
+
+		for _, rule := range re.rules {
+			if !rule.matches(position, wait) {
+				continue
+			}
+
+			if re.fired[entry.ID][rule.Name] {
+				continue
+			}
+
+			claimed, err := claimReminder(re.db, entry.ID, rule.Name)
+			if err != nil {
+				log.Printf("Warning: %v", err)
+				continue
+			}
+			if re.fired[entry.ID] == nil {
+				re.fired[entry.ID] = map[string]bool{}
+			}
+			re.fired[entry.ID][rule.Name] = true
+			if !claimed {
+				continue
+			}
+
+			msg := customerMessage(entry, "You're almost up",
+				fmt.Sprintf("Hi %s, you're number %d in line (about %d min). Please head back soon.",
+					entry.FirstName, position, int(wait.Minutes())))
+			go func() {
+				if err := re.notifier.Send(msg); err != nil {
+					log.Printf("Warning: failed to send reminder to entry %d: %v", entry.ID, err)
+				}
+			}()
+		}
+	}
+}
+
+// prune forgets the reminders of entries no longer waiting. They never wait
+// again, so the database still stops a reminder being sent twice.
+func (re *ReminderEngine) prune(waiting []Entry) {
+	ids := make(map[int]bool, len(waiting))
+	for _, e := range waiting {
+		ids[e.ID] = true
+	}
+	for id := range re.fired {
+		if !ids[id] {
+			delete(re.fired, id)
+		}
+	}
+}
+
+func waitingEntries(queue []Entry) []Entry {
+	var waiting []Entry
+	for _, e := range queue {
+		if e.Status == StatusWaiting {
+			waiting = append(waiting, e)
+		}
+	}
+	return waiting
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+
+	"wait-to-go/apierror"
+	"wait-to-go/apiroute"
+	"wait-to-go/apispec"
+	"wait-to-go/auth"
+)
+
+// DefaultQueueID names the single queue served by this instance in
+// /api/v1/queues/{queue} paths
+const DefaultQueueID = "default"
+
+// routes registers the /api/v1 API and the deprecated unversioned aliases
+func (a *App) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	public := func(h http.HandlerFunc) http.HandlerFunc { return h }
+	customer := auth.AuthMiddleware
+	admin := auth.AdminAuthMiddleware
+	// locked handlers read or change the in-memory queue. Joins, bookings
+	// and their verification lock it themselves, only around the capacity
+	// check and the insert, as they first wait on CAPTCHA checks and SMS.
+	locked := a.lockQueue
+
+	// Queue entries
+	mux.HandleFunc("GET /api/v1/queues/{queue}", a.inQueue(public(locked(a.handleGetQueue))))
+	mux.HandleFunc("POST /api/v1/queues/{queue}/entries", a.inQueue(public(a.idempotent(a.handleJoin))))
+	mux.HandleFunc("GET /api/v1/queues/{queue}/entries", a.inQueue(admin(a.handleListEntries)))
+	mux.HandleFunc("DELETE /api/v1/queues/{queue}/entries", a.inQueue(admin(a.idempotent(locked(a.handleClear)))))
+	mux.HandleFunc("POST /api/v1/queues/{queue}/entries:next", a.inQueue(admin(a.idempotent(locked(a.handleNext)))))
+	mux.HandleFunc("POST /api/v1/queues/{queue}/appointments", a.inQueue(public(a.idempotent(a.handleBook))))
+	mux.HandleFunc("GET /api/v1/challenges/join", public(a.handleJoinChallenge))
+
+	// Queue state and schedule; states are POST /api/v1/queues/{queue}:<action>
+	mux.HandleFunc("POST /api/v1/queues/{queue}", apiroute.CustomMethods(map[string]http.HandlerFunc{
+		"open":  a.inQueue(admin(locked(a.handleOpenQueue))),
+		"pause": a.inQueue(admin(locked(a.handlePauseQueue))),
+		"close": a.inQueue(admin(locked(a.handleCloseQueue))),
+	}))
+	mux.HandleFunc("GET /api/v1/queues/{queue}/schedule", a.inQueue(admin(a.handleGetSchedule)))
+	mux.HandleFunc("PUT /api/v1/queues/{queue}/schedule", a.inQueue(admin(a.handlePutSchedule)))
+	mux.HandleFunc("DELETE /api/v1/queues/{queue}/schedule", a.inQueue(admin(a.handleDeleteSchedule)))
+	mux.HandleFunc("GET /api/v1/queues/{queue}/holidays", a.inQueue(admin(a.handleListHolidays)))
+	mux.HandleFunc("POST /api/v1/queues/{queue}/holidays", a.inQueue(admin(a.handleAddHoliday)))
+	mux.HandleFunc("DELETE /api/v1/queues/{queue}/holidays/{date}", a.inQueue(admin(a.handleDeleteHoliday)))
+	// The rotating code shown on site; customers scan it with POST /api/v1/entries/{id}:arrive
+	mux.HandleFunc("GET /api/v1/queues/{queue}/arrivalCode", a.inQueue(admin(a.handleArrivalCode)))
+	// The join link to print on posters
+	mux.HandleFunc("GET /api/v1/queues/{queue}/qrcode", a.inQueue(admin(a.handlePosterQRCode)))
+
+	// Single entries; custom methods are POST /api/v1/entries/{id}:<action>
+	mux.HandleFunc("GET /api/v1/entries/{id}", customer(locked(a.handleStatus)))
+	mux.HandleFunc("PATCH /api/v1/entries/{id}", customer(locked(a.handleUpdateEntry)))
+	mux.HandleFunc("POST /api/v1/entries/{id}", apiroute.CustomMethods(map[string]http.HandlerFunc{
+		"notify": admin(a.idempotent(locked(a.handleNotify))),
+		"serve":  admin(a.idempotent(locked(a.handleServe))),
+		"noShow": admin(locked(a.handleNoShow)),
+		"move":   admin(locked(a.handleMove)),
+		"verify": public(a.handleJoinVerify),
+		// Customers with their own token
+		"checkIn": customer(locked(a.handleCheckIn)),
+		"cancel":  customer(locked(a.handleCancel)),
+		"arrive":  customer(locked(a.handleArrive)),
+	}))
+
+	// Counters; operators claim one so the customers they call are sent to it
+	mux.HandleFunc("GET /api/v1/counters", admin(a.handleListCounters))
+	mux.HandleFunc("POST /api/v1/counters", admin(a.handleCreateCounter))
+	mux.HandleFunc("PATCH /api/v1/counters/{id}", admin(a.handleUpdateCounter))
+	mux.HandleFunc("DELETE /api/v1/counters/{id}", admin(a.handleDeleteCounter))
+	mux.HandleFunc("POST /api/v1/counters/{id}", apiroute.CustomMethods(map[string]http.HandlerFunc{
+		"claim":   admin(a.handleClaimCounter),
+		"release": admin(a.handleReleaseCounter),
+	}))
+
+	// Session recovery
+	mux.HandleFunc("POST /api/v1/recovery", public(a.handleRecover))
+	mux.HandleFunc("POST /api/v1/recovery:verify", public(locked(a.handleRecoverVerify)))
+
+	// Inbound SMS provider callbacks (require HMAC signature)
+	mux.HandleFunc("POST /api/v1/sms/inbound", locked(a.handleInboundSMS))
+
+	// Webhooks and blocklist
+	mux.HandleFunc("GET /api/v1/webhooks", admin(a.handleListWebhooks))
+	mux.HandleFunc("POST /api/v1/webhooks", admin(a.handleCreateWebhook))
+	mux.HandleFunc("DELETE /api/v1/webhooks/{id}", admin(a.handleDeleteWebhook))
+	mux.HandleFunc("POST /api/v1/webhooks/{id}", apiroute.CustomMethods(map[string]http.HandlerFunc{
+		"test": admin(a.handleTestWebhook),
+	}))
+	mux.HandleFunc("GET /api/v1/webhooks/{id}/deliveries", admin(a.handleWebhookDeliveries))
+	mux.HandleFunc("GET /api/v1/blocklist", admin(a.handleListBlocklist))
+	mux.HandleFunc("POST /api/v1/blocklist", admin(a.handleAddBlocked))
+	mux.HandleFunc("DELETE /api/v1/blocklist/{id}", admin(a.handleDeleteBlocked))
+
+	// Deprecated unversioned routes, kept until clients have moved to /api/v1
+	mux.HandleFunc("POST /join", apiroute.Deprecated("/api/v1/queues/default/entries", public(a.idempotent(a.handleJoin))))
+	mux.HandleFunc("GET /join/challenge", apiroute.Deprecated("/api/v1/challenges/join", public(a.handleJoinChallenge)))
+	mux.HandleFunc("POST /join/verify", apiroute.Deprecated("/api/v1/entries/{id}:verify", public(a.handleJoinVerify)))
+	mux.HandleFunc("POST /recover", apiroute.Deprecated("/api/v1/recovery", public(a.handleRecover)))
+	mux.HandleFunc("POST /recover/verify", apiroute.Deprecated("/api/v1/recovery:verify", public(locked(a.handleRecoverVerify))))
+	mux.HandleFunc("POST /sms/inbound", apiroute.Deprecated("/api/v1/sms/inbound", locked(a.handleInboundSMS)))
+	mux.HandleFunc("GET /status/{id}", apiroute.Deprecated("/api/v1/entries/{id}", customer(locked(a.handleStatus))))
+	mux.HandleFunc("PATCH /status/{id}", apiroute.Deprecated("/api/v1/entries/{id}", customer(locked(a.handleUpdateEntry))))
+	mux.HandleFunc("GET /queue", apiroute.Deprecated("/api/v1/queues/default/entries", admin(locked(a.handleQueue))))
+	mux.HandleFunc("POST /next", apiroute.Deprecated("/api/v1/queues/default/entries:next", admin(a.idempotent(locked(a.handleNext)))))
+	mux.HandleFunc("POST /serve", apiroute.Deprecated("/api/v1/entries/{id}:serve", admin(a.idempotent(locked(a.handleServe)))))
+	mux.HandleFunc("POST /clear", apiroute.Deprecated("/api/v1/queues/default/entries", admin(a.idempotent(locked(a.handleClear)))))
+	mux.HandleFunc("GET /webhooks", apiroute.Deprecated("/api/v1/webhooks", admin(a.handleListWebhooks)))
+	mux.HandleFunc("POST /webhooks", apiroute.Deprecated("/api/v1/webhooks", admin(a.handleCreateWebhook)))
+	mux.HandleFunc("DELETE /webhooks/{id}", apiroute.Deprecated("/api/v1/webhooks/{id}", admin(a.handleDeleteWebhook)))
+	mux.HandleFunc("POST /webhooks/{id}/test", apiroute.Deprecated("/api/v1/webhooks/{id}:test", admin(a.handleTestWebhook)))
+	mux.HandleFunc("GET /webhooks/{id}/deliveries", apiroute.Deprecated("/api/v1/webhooks/{id}/deliveries", admin(a.handleWebhookDeliveries)))
+	mux.HandleFunc("GET /blocklist", apiroute.Deprecated("/api/v1/blocklist", admin(a.handleListBlocklist)))
+	mux.HandleFunc("POST /blocklist", apiroute.Deprecated("/api/v1/blocklist", admin(a.handleAddBlocked)))
+	mux.HandleFunc("DELETE /blocklist/{id}", apiroute.Deprecated("/api/v1/blocklist/{id}", admin(a.handleDeleteBlocked)))
+
+	// API description
+	mux.HandleFunc("GET /openapi.json", apispec.SpecHandler)
+	mux.HandleFunc("GET /docs", apispec.DocsHandler)
+
+	mux.HandleFunc("/", notFound(mux))
+
+	return apierror.WithRequestID(cors(mux))
+}
+
+// inQueue rejects /api/v1/queues/{queue} paths naming a queue other than the
+// default one
+func (a *App) inQueue(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("queue") != DefaultQueueID {
+			apierror.Error(w, r, "Queue not found", http.StatusNotFound)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// notFound answers requests no route matched with a JSON 404, or a 405 listing
+// the allowed methods when the path exists under other methods
+func notFound(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var allowed []string
+		for _, method := range []string{"GET", "POST", "PUT", "PATCH", "DELETE"} {
+			probe := r.Clone(r.Context())
+			probe.Method = method
+			if _, pattern := mux.Handler(probe); pattern != "/" {
+				allowed = append(allowed, method)
+			}
+		}
+
+		if len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			apierror.Error(w, r, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		apierror.Error(w, r, "Not found", http.StatusNotFound)
+	}
+}
+
+// cors allows browser clients on any origin and answers preflight requests
+func cors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key, X-Request-ID, X-Verification-Token, X-Poster-Token, Idempotency-Key, X-Admin-Session")
+		w.Header().Set("Access-Control-Expose-Headers", "Authorization, X-Request-ID, Deprecation, Link, Idempotent-Replayed, X-Join-URL, X-Poster-Expires, X-Arrival-Expires")
+
+		if r.Method == "OPTIONS" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var serviceTypePattern = regexp.MustCompile(`^[a-z0-9_-]{1,30}$`)
+
+// parseServiceTypes reads SERVICE_TYPES, a comma separated list such as
+// "general,returns,new_account". The first type is the default at join.
+func parseServiceTypes(value string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(value, ",") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		if !serviceTypePattern.MatchString(t) {
+			return nil, fmt.Errorf("invalid service type %q: use up to 30 lowercase letters, digits, _ or -", t)
+		}
+		if slices.Contains(types, t) {
+			return nil, fmt.Errorf("service type %q is listed twice", t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// validateServiceType defaults the entry's service type to the first
+// configured one and checks it is known. It returns a message for the
+// serviceType field, or "" if the entry is valid.
+func validateServiceType(entry *Entry, serviceTypes []string) string {
+	if len(serviceTypes) == 0 {
+		if entry.ServiceType != "" {
+			return "This queue does not use service types"
+		}
+		return ""
+	}
+
+	if entry.ServiceType == "" {
+		entry.ServiceType = serviceTypes[0]
+	}
+	if !slices.Contains(serviceTypes, entry.ServiceType) {
+		return "Service type must be one of " + strings.Join(serviceTypes, ", ")
+	}
+	return ""
+}
+
+// validateSkills returns a message for the skills field, or "" if every skill
+// is a configured service type
+func validateSkills(skills []string, serviceTypes []string) string {
+	for _, skill := range skills {
+		if !slices.Contains(serviceTypes, skill) {
+			if len(serviceTypes) == 0 {
+				return "This queue does not use service types"
+			}
+			return "Skills must be service types: " + strings.Join(serviceTypes, ", ")
+		}
+	}
+	return ""
+}
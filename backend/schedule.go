@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/queueing"
+)
+
+// scheduleInterval is how often the scheduler checks for opening and closing
+// times and for appointments that are due
+const scheduleInterval = 30 * time.Second
+
+// Schedule, its opening hours and holidays live in queueing
+type (
+	Schedule     = queueing.Schedule
+	OpeningHours = queueing.OpeningHours
+	Holiday      = queueing.Holiday
+)
+
+// runScheduler applies the schedule and admits due appointments until the
+// process exits
+func (a *App) runScheduler() {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		if err := a.applySchedule(now); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		if err := a.admitDueAppointments(now); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+}
+
+// applySchedule opens or closes the queue when the schedule crosses an
+// opening or closing time. Only the crossing changes the state, so a queue
+// paused or opened by hand stays that way until the next scheduled change.
+func (a *App) applySchedule(now time.Time) error {
+	settings, err := getQueueSettings(a.db)
+	if err != nil {
+		return err
+	}
+	if settings.Schedule == nil {
+		return nil
+	}
+	holidays, err := getHolidays(a.db)
+	if err != nil {
+		return err
+	}
+
+	want := QueueClosed
+	if settings.Schedule.OpenAt(now, holidays) {
+		want = QueueOpen
+	}
+	if want == settings.ScheduledState {
+		return nil
+	}
+
+	// A schedule that was just saved sets the state, but never cancels anyone
+	cancel := want == QueueClosed && settings.Schedule.CancelAtClose && settings.ScheduledState != ""
+
+	a.queueMu.Lock()
+	defer a.queueMu.Unlock()
+
+	// Record the crossing only once the change is made, so a failed change
+	// is tried again on the next tick
+	if err := a.changeQueueState(want, "", cancel); err != nil {
+		return err
+	}
+	return setScheduledState(a.db, want)
+}
+
+// handleGetSchedule returns the schedule, or 404 if the queue has none:
+// GET /api/v1/queues/{queue}/schedule
+func (a *App) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	settings, err := getQueueSettings(a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get schedule", http.StatusInternalServerError)
+		return
+	}
+	if settings.Schedule == nil {
+		apierror.Error(w, r, "The queue has no schedule", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(settings.Schedule)
+}
+
+// handlePutSchedule replaces the schedule. It takes effect within a minute,
+// opening or closing the queue to match.
+func (a *App) handlePutSchedule(w http.ResponseWriter, r *http.Request) {
+	var schedule Schedule
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&schedule); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if fields := schedule.Validate(); len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+	if schedule.Weekly == nil {
+		schedule.Weekly = map[string][]OpeningHours{}
+	}
+
+	if err := saveSchedule(a.db, &schedule); err != nil {
+		apierror.Error(w, r, "Failed to save schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(schedule)
+}
+
+// handleDeleteSchedule stops automatic opening and closing
+func (a *App) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if err := saveSchedule(a.db, nil); err != nil {
+		apierror.Error(w, r, "Failed to delete schedule", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+func (a *App) handleListHolidays(w http.ResponseWriter, r *http.Request) {
+	holidays, err := getHolidays(a.db)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get holidays", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(holidays)
+}
+
+// handleAddHoliday closes the queue for a day: {"date": "2025-12-25", "reason": "..."}
+func (a *App) handleAddHoliday(w http.ResponseWriter, r *http.Request) {
+	var holiday Holiday
+	if err := json.NewDecoder(r.Body).Decode(&holiday); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	fields := FieldErrors{}
+	if _, err := time.Parse(time.DateOnly, holiday.Date); err != nil {
+		fields["date"] = "Date must be YYYY-MM-DD"
+	}
+	if len(holiday.Reason) > 200 {
+		fields["reason"] = "Reason must be at most 200 characters"
+	}
+	if len(fields) > 0 {
+		apierror.ValidationError(w, r, fields)
+		return
+	}
+
+	if err := insertHoliday(a.db, holiday); err != nil {
+		apierror.Error(w, r, "Failed to add holiday", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(holiday)
+}
+
+func (a *App) handleDeleteHoliday(w http.ResponseWriter, r *http.Request) {
+	date := r.PathValue("date")
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		apierror.Error(w, r, "Invalid date format", http.StatusBadRequest)
+		return
+	}
+
+	if err := deleteHoliday(a.db, date); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Holiday not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to delete holiday", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
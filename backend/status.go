@@ -0,0 +1,108 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+// checkTransition returns a TransitionError if entry may not move to status to
+func checkTransition(entry Entry, to string) error {
+	return queueing.CheckTransition(entry, to)
+}
+
+// setStatus moves an entry to a new status. The database update only applies
+// if the row still has the status the caller saw, so concurrent changes are
+// reported as a TransitionError from the current status.
+func setStatus(db *sql.DB, entry *Entry, to string) error {
+	if err := checkTransition(*entry, to); err != nil {
+		return err
+	}
+
+	current, err := updateStatusFrom(db, entry.ID, entry.Status, to)
+	if err != nil {
+		return err
+	}
+	if current != entry.Status {
+		return &queueing.TransitionError{ID: entry.ID, From: current, To: to}
+	}
+
+	entry.Status = to
+	return nil
+}
+
+// setCallStatus moves an entry to a new status like setStatus, saving in the
+// same update when it was called, if calledAt is set, and the counter, if
+// counter is set
+func setCallStatus(db *sql.DB, entry *Entry, to string, calledAt *time.Time, counter *Counter) error {
+	if err := checkTransition(*entry, to); err != nil {
+		return err
+	}
+
+	updated := *entry
+	updated.Status = to
+	if calledAt != nil {
+		updated.CalledAt = calledAt
+	}
+	queueing.AtCounter(&updated, counter)
+
+	if err := saveCall(db, entry.Status, updated); err != nil {
+		return err
+	}
+	*entry = updated
+	return nil
+}
+
+// saveCall stores an entry that was called or served, provided it still has
+// status from in the database
+func saveCall(db *sql.DB, from string, updated Entry) error {
+	current, err := updateCallFrom(db, updated, from)
+	if err != nil {
+		return err
+	}
+	if current != from {
+		return &queueing.TransitionError{ID: updated.ID, From: current, To: updated.Status}
+	}
+	return nil
+}
+
+// moveToBack changes an entry's status like setStatus and puts it at the back
+// of the queue. A non-nil joinTime replaces the entry's join time.
+func moveToBack(db *sql.DB, entry *Entry, to string, joinTime *time.Time) error {
+	if err := checkTransition(*entry, to); err != nil {
+		return err
+	}
+
+	order, err := updateStatusToBack(db, entry.ID, entry.Status, to, joinTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		current, err := getEntryStatus(db, entry.ID)
+		if err != nil {
+			return err
+		}
+		return &queueing.TransitionError{ID: entry.ID, From: current, To: to}
+	}
+	if err != nil {
+		return err
+	}
+
+	entry.Status = to
+	entry.QueueOrder = order
+	if joinTime != nil {
+		entry.JoinTime = *joinTime
+	}
+	return nil
+}
+
+// cancelAll cancels every entry with one of the given statuses and returns
+// them. Each status must be allowed to become cancelled.
+func cancelAll(db *sql.DB, statuses ...string) ([]Entry, error) {
+	for _, from := range statuses {
+		if !queueing.CanTransition(from, StatusCancelled) {
+			return nil, fmt.Errorf("%s entries cannot be cancelled: %w", from, queueing.ErrInvalidTransition)
+		}
+	}
+	return updateStatusesTo(db, statuses, StatusCancelled)
+}
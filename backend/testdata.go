@@ -11,42 +11,47 @@ func getTests() ([]Entry, []Entry) {
 			FirstName:   "Alice",
 			LastName:    "Johnson",
 			Email:       "alice@example.com",
-			PhoneNumber: "555-0100",
+			PhoneNumber: "+15555550100",
 			Status:      StatusWaiting,
 			JoinTime:    time.Date(2025, 4, 20, 9, 0, 0, 0, time.UTC),
+			QueueOrder:  3 * queueOrderGap,
 		},
 		{
 			FirstName:   "Bob",
 			LastName:    "Smith",
 			Email:       "bob@example.com",
-			PhoneNumber: "555-0101",
+			PhoneNumber: "+15555550101",
 			Status:      StatusWaiting,
 			JoinTime:    time.Date(2025, 4, 20, 9, 1, 0, 0, time.UTC),
+			QueueOrder:  4 * queueOrderGap,
 		},
 		{
 			FirstName:   "Charlie",
 			LastName:    "Lee",
 			Email:       "charlie@example.com",
-			PhoneNumber: "555-0102",
+			PhoneNumber: "+15555550102",
 			Status:      StatusNotified,
 			JoinTime:    time.Date(2025, 4, 20, 8, 58, 0, 0, time.UTC),
+			QueueOrder:  2 * queueOrderGap,
 		},
 		{FirstName: "Dana",
 			LastName:    "Khan",
 			Email:       "dana@example.com",
-			PhoneNumber: "555-0103",
+			PhoneNumber: "+15555550103",
 			Status:      StatusServed,
 			JoinTime:    time.Date(2025, 4, 20, 8, 50, 0, 0, time.UTC),
+			QueueOrder:  1 * queueOrderGap,
 		},
 		{
 			FirstName:   "Eli",
 			LastName:    "Garcia",
 			Email:       "eli@example.com",
-			PhoneNumber: "555-0104",
+			PhoneNumber: "+15555550104",
 			Status:      StatusWaiting,
 			JoinTime:    time.Date(2025, 4, 20, 9, 2, 0, 0, time.UTC),
+			QueueOrder:  5 * queueOrderGap,
 		}}
 
-	sort.Sort(ByJoinTime(queue))
+	sort.Sort(ByQueueOrder(queue))
 	return queue, []Entry{}
 }
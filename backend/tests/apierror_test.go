@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wait-to-go/apierror"
+	"wait-to-go/auth"
+)
+
+func TestErrorEnvelope(t *testing.T) {
+	handler := apierror.WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		apierror.ValidationError(w, r, map[string]string{"phoneNumber": "Phone number is required"})
+	}))
+
+	req := httptest.NewRequest("POST", "/join", nil)
+	req.Header.Set(apierror.RequestIDHeader, "req-123")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("handler returned wrong status code: got %v want %v", rr.Code, http.StatusBadRequest)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected JSON content type, got %q", ct)
+	}
+
+	var response apierror.Response
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+		t.Fatalf("Response is not valid JSON: %v", err)
+	}
+	if response.Error.Code != apierror.CodeValidation {
+		t.Errorf("Expected code %q, got %q", apierror.CodeValidation, response.Error.Code)
+	}
+	if response.Error.Details["phoneNumber"] == "" {
+		t.Error("Expected phoneNumber detail")
+	}
+	if response.Error.RequestID != "req-123" || rr.Header().Get(apierror.RequestIDHeader) != "req-123" {
+		t.Errorf("Expected request ID req-123 to be propagated, got %q", response.Error.RequestID)
+	}
+}
+
+func TestRequestIDGeneratedWhenMissingOrInvalid(t *testing.T) {
+	handler := apierror.WithRequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, incoming := range []string{"", "bad id with spaces"} {
+		req := httptest.NewRequest("GET", "/queue", nil)
+		if incoming != "" {
+			req.Header.Set(apierror.RequestIDHeader, incoming)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		id := rr.Header().Get(apierror.RequestIDHeader)
+		if id == "" || id == incoming {
+			t.Errorf("Expected a generated request ID for %q, got %q", incoming, id)
+		}
+	}
+}
+
+func TestMiddlewareErrorCodes(t *testing.T) {
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		header   string
+		value    string
+		wantCode apierror.Code
+	}{
+		{name: "Missing token", handler: auth.AuthMiddleware(testHandler), wantCode: apierror.CodeUnauthorized},
+		{name: "Invalid token", handler: auth.AuthMiddleware(testHandler), header: "Authorization", value: "Bearer nope", wantCode: apierror.CodeInvalidToken},
+		{name: "Invalid API key", handler: auth.AdminAuthMiddleware(testHandler), header: "X-API-Key", value: "invalid-key", wantCode: apierror.CodeInvalidAPIKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/test", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rr := httptest.NewRecorder()
+			tt.handler.ServeHTTP(rr, req)
+
+			var response apierror.Response
+			if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+				t.Fatalf("Response is not valid JSON: %v", err)
+			}
+			if response.Error.Code != tt.wantCode {
+				t.Errorf("Expected code %q, got %q", tt.wantCode, response.Error.Code)
+			}
+		})
+	}
+}
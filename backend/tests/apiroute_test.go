@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"wait-to-go/apiroute"
+)
+
+// echoAction answers with the action name and the {id} the handler sees
+func echoAction(action string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(action + " " + r.PathValue("id")))
+	}
+}
+
+func TestCustomMethods(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/entries/{id}", apiroute.CustomMethods(map[string]http.HandlerFunc{
+		"serve":  echoAction("serve"),
+		"cancel": echoAction("cancel"),
+	}))
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "Known action", path: "/api/v1/entries/12:serve", wantStatus: http.StatusOK, wantBody: "serve 12"},
+		{name: "Another action", path: "/api/v1/entries/7:cancel", wantStatus: http.StatusOK, wantBody: "cancel 7"},
+		{name: "Unknown action", path: "/api/v1/entries/12:delete", wantStatus: http.StatusNotFound},
+		{name: "No action", path: "/api/v1/entries/12", wantStatus: http.StatusNotFound},
+		{name: "Empty action", path: "/api/v1/entries/12:", wantStatus: http.StatusNotFound},
+		{name: "Case sensitive", path: "/api/v1/entries/12:Serve", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest("POST", tt.path, nil))
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("POST %s returned %d, want %d", tt.path, rr.Code, tt.wantStatus)
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("POST %s = %q, want %q", tt.path, rr.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCustomMethodsOnQueue(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/queues/{queue}", apiroute.CustomMethods(map[string]http.HandlerFunc{
+		"pause": func(w http.ResponseWriter, r *http.Request) { w.Write([]byte(r.PathValue("queue"))) },
+	}))
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/queues/default:pause", nil))
+	if rr.Code != http.StatusOK || rr.Body.String() != "default" {
+		t.Errorf("POST /api/v1/queues/default:pause = %d %q, want 200 with the bare queue name", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDeprecated(t *testing.T) {
+	mux := http.NewServeMux()
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	mux.HandleFunc("GET /queue", apiroute.Deprecated("/api/v1/queues/default/entries", ok))
+	mux.HandleFunc("GET /status/{id}", apiroute.Deprecated("/api/v1/entries/{id}", ok))
+	mux.HandleFunc("POST /join/verify", apiroute.Deprecated("/api/v1/entries/{id}:verify", ok))
+
+	tests := []struct {
+		name     string
+		method   string
+		path     string
+		wantLink string
+	}{
+		{name: "Fixed successor", method: "GET", path: "/queue", wantLink: `</api/v1/queues/default/entries>; rel="successor-version"`},
+		{name: "Successor with the id filled in", method: "GET", path: "/status/42", wantLink: `</api/v1/entries/42>; rel="successor-version"`},
+		{name: "Successor id not in the old path", method: "POST", path: "/join/verify", wantLink: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if rr.Code != http.StatusOK {
+				t.Fatalf("%s %s returned %d, want the handler to run", tt.method, tt.path, rr.Code)
+			}
+			if got := rr.Header().Get("Deprecation"); got != "true" {
+				t.Errorf("Deprecation = %q, want true", got)
+			}
+			if got := rr.Header().Get("Link"); got != tt.wantLink {
+				t.Errorf("Link = %q, want %q", got, tt.wantLink)
+			}
+		})
+	}
+}
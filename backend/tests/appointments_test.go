@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+// visits builds entries from a string of kinds: 'a' for an appointment and
+// 'w' for a walk-in
+func visits(kinds string) []queueing.Entry {
+	slot := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	entries := make([]queueing.Entry, len(kinds))
+	for i, kind := range kinds {
+		entries[i] = queueing.Entry{ID: i + 1, Status: queueing.StatusWaiting}
+		if kind == 'a' {
+			entries[i].AppointmentAt = &slot
+		}
+	}
+	return entries
+}
+
+func TestPlaceFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		priority string
+		walkIns  int
+		queue    string
+		history  string
+		want     int
+	}{
+		{name: "First into an empty queue", priority: queueing.PriorityFirst, queue: "", want: 0},
+		{name: "First ahead of walk-ins", priority: queueing.PriorityFirst, queue: "www", want: 0},
+		{name: "First behind waiting appointments", priority: queueing.PriorityFirst, queue: "aaww", want: 2},
+		{name: "First behind an appointment placed later", priority: queueing.PriorityFirst, queue: "wwaw", want: 3},
+		{name: "None at the back", priority: queueing.PriorityNone, queue: "awww", want: 4},
+		{name: "Alternate behind the last appointment", priority: queueing.PriorityAlternate, walkIns: 2, queue: "awwww", want: 3},
+		{name: "Alternate at the back when walk-ins run out", priority: queueing.PriorityAlternate, walkIns: 2, queue: "wawa", want: 4},
+		{name: "Alternate with no history", priority: queueing.PriorityAlternate, walkIns: 2, queue: "wwww", want: 2},
+		{name: "Alternate after an appointment was called", priority: queueing.PriorityAlternate, walkIns: 2, queue: "wwww", history: "wwa", want: 2},
+		{name: "Alternate owes the rest of the walk-ins", priority: queueing.PriorityAlternate, walkIns: 2, queue: "wwww", history: "aw", want: 1},
+		{name: "Alternate after enough walk-ins", priority: queueing.PriorityAlternate, walkIns: 2, queue: "wwww", history: "aww", want: 0},
+		{name: "Alternate into a short queue", priority: queueing.PriorityAlternate, walkIns: 3, queue: "w", want: 1},
+		{name: "Alternate with no walk-ins between", priority: queueing.PriorityAlternate, walkIns: 0, queue: "aww", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := queueing.AppointmentPolicy{Priority: tt.priority, WalkIns: tt.walkIns}
+			if got := policy.PlaceFor(visits(tt.queue), visits(tt.history)); got != tt.want {
+				t.Errorf("PlaceFor(%q, history %q) = %d, want %d", tt.queue, tt.history, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSlot(t *testing.T) {
+	now := time.Date(2026, 10, 20, 9, 10, 0, 0, time.UTC)
+	at := func(hour, minute int) *time.Time {
+		slot := time.Date(2026, 10, 20, hour, minute, 0, 0, time.UTC)
+		return &slot
+	}
+	policy := queueing.AppointmentPolicy{SlotLength: 15 * time.Minute}
+
+	tests := []struct {
+		name    string
+		at      *time.Time
+		wantErr bool
+	}{
+		{name: "Start of a future slot", at: at(9, 15)},
+		{name: "Missing", at: nil, wantErr: true},
+		{name: "In the past", at: at(9, 0), wantErr: true},
+		{name: "Now", at: &now, wantErr: true},
+		{name: "Inside a slot", at: at(9, 20), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if msg := policy.ValidateSlot(tt.at, now); (msg != "") != tt.wantErr {
+				t.Errorf("ValidateSlot() = %q, wantErr %v", msg, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseAppointmentPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		slotLength time.Duration
+		perSlot    int
+		priority   string
+		walkIns    int
+		wantErr    bool
+	}{
+		{name: "Quarter hours", slotLength: 15 * time.Minute, perSlot: 1, priority: queueing.PriorityFirst},
+		{name: "Two hours", slotLength: 2 * time.Hour, perSlot: 3, priority: queueing.PriorityAlternate, walkIns: 2},
+		{name: "Slot not dividing an hour", slotLength: 25 * time.Minute, perSlot: 1, priority: queueing.PriorityFirst, wantErr: true},
+		{name: "Slot under a minute", slotLength: 30 * time.Second, perSlot: 1, priority: queueing.PriorityFirst, wantErr: true},
+		{name: "No appointments per slot", slotLength: time.Hour, perSlot: 0, priority: queueing.PriorityFirst, wantErr: true},
+		{name: "Unknown priority", slotLength: time.Hour, perSlot: 1, priority: "last", wantErr: true},
+		{name: "Negative walk-ins", slotLength: time.Hour, perSlot: 1, priority: queueing.PriorityAlternate, walkIns: -1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := queueing.ParseAppointmentPolicy(tt.slotLength, tt.perSlot, tt.priority, tt.walkIns, 15*time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseAppointmentPolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAppointmentDue(t *testing.T) {
+	slot := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	checkedIn := slot.Add(-5 * time.Minute)
+	policy := queueing.AppointmentPolicy{SlotLength: 15 * time.Minute, NoShowAfter: 15 * time.Minute}
+
+	tests := []struct {
+		name      string
+		checkedIn bool
+		now       time.Time
+		open      bool
+		want      string
+	}{
+		{name: "Checked in before the slot", checkedIn: true, now: slot.Add(-time.Minute), open: true, want: queueing.DueWait},
+		{name: "Checked in as the slot starts", checkedIn: true, now: slot, open: true, want: queueing.DueAdmit},
+		{name: "Checked in while paused", checkedIn: true, now: slot.Add(time.Minute), open: false, want: queueing.DueWait},
+		{name: "Checked in and closed past the no-show time", checkedIn: true, now: slot.Add(time.Hour), open: false, want: queueing.DueWait},
+		{name: "Checked in and reopened late", checkedIn: true, now: slot.Add(time.Hour), open: true, want: queueing.DueAdmit},
+		{name: "Not checked in yet", now: slot.Add(10 * time.Minute), open: true, want: queueing.DueWait},
+		{name: "Never checked in", now: slot.Add(15 * time.Minute), open: true, want: queueing.DueNoShow},
+		{name: "Never checked in while closed", now: slot.Add(15 * time.Minute), open: false, want: queueing.DueNoShow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := queueing.Entry{ID: 1, Status: queueing.StatusBooked, AppointmentAt: &slot}
+			if tt.checkedIn {
+				entry.CheckedInAt = &checkedIn
+			}
+			if got := policy.Due(entry, tt.now, tt.open); got != tt.want {
+				t.Errorf("Due() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
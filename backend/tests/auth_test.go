@@ -155,3 +155,24 @@ func TestAdminKeyValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestOTP(t *testing.T) {
+	code, err := auth.GenerateOTP()
+	if err != nil {
+		t.Fatalf("GenerateOTP() error = %v", err)
+	}
+	if len(code) != auth.OTPDigits {
+		t.Errorf("GenerateOTP() = %q, want %d digits", code, auth.OTPDigits)
+	}
+
+	hash := auth.HashOTP("join/1", code)
+	if !auth.CheckOTP("join/1", code, hash) {
+		t.Error("CheckOTP() rejected the issued code")
+	}
+	if auth.CheckOTP("join/2", code, hash) {
+		t.Error("CheckOTP() accepted the code for a different subject")
+	}
+	if auth.CheckOTP("join/1", "000000x", hash) {
+		t.Error("CheckOTP() accepted a wrong code")
+	}
+}
@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+// party is a waiting entry for the calling tests
+func party(id, size, skips int, serviceType string) queueing.Entry {
+	return queueing.Entry{ID: id, Status: queueing.StatusWaiting, PartySize: size, Skips: skips, ServiceType: serviceType}
+}
+
+func TestNextForSeats(t *testing.T) {
+	tests := []struct {
+		name       string
+		rules      queueing.CallRules
+		queue      []queueing.Entry
+		wantIndex  int
+		wantPassed []int
+		wantErr    error
+	}{
+		{
+			name:      "Any seats take the first party",
+			rules:     queueing.CallRules{SkipLimit: 3},
+			queue:     []queueing.Entry{party(1, 6, 0, ""), party(2, 2, 0, "")},
+			wantIndex: 0,
+		},
+		{
+			name:      "First party fits",
+			rules:     queueing.CallRules{Seats: 4, SkipLimit: 3},
+			queue:     []queueing.Entry{party(1, 4, 0, ""), party(2, 2, 0, "")},
+			wantIndex: 0,
+		},
+		{
+			name:       "Large parties are passed over",
+			rules:      queueing.CallRules{Seats: 2, SkipLimit: 3},
+			queue:      []queueing.Entry{party(1, 6, 0, ""), party(2, 4, 2, ""), party(3, 2, 0, "")},
+			wantIndex:  2,
+			wantPassed: []int{0, 1},
+		},
+		{
+			name:      "Skipped too often holds the seats",
+			rules:     queueing.CallRules{Seats: 2, SkipLimit: 3},
+			queue:     []queueing.Entry{party(1, 6, 3, ""), party(2, 2, 0, "")},
+			wantIndex: -1,
+			wantErr:   &queueing.PassedOverError{},
+		},
+		{
+			name:      "Skip limit of zero never skips",
+			rules:     queueing.CallRules{Seats: 2},
+			queue:     []queueing.Entry{party(1, 3, 0, ""), party(2, 2, 0, "")},
+			wantIndex: -1,
+			wantErr:   &queueing.PassedOverError{},
+		},
+		{
+			name:      "Nobody fits",
+			rules:     queueing.CallRules{Seats: 2, SkipLimit: 3},
+			queue:     []queueing.Entry{party(1, 6, 0, ""), party(2, 4, 0, "")},
+			wantIndex: -1,
+			wantErr:   queueing.ErrNoPartyFits,
+		},
+		{
+			name:      "Empty queue",
+			rules:     queueing.CallRules{Seats: 2, SkipLimit: 3},
+			wantIndex: -1,
+			wantErr:   queueing.ErrNoEligibleEntry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, passed, err := queueing.NextFor(nil, tt.rules, tt.queue)
+			checkNext(t, index, passed, err, tt.wantIndex, tt.wantPassed, tt.wantErr)
+		})
+	}
+}
+
+func TestNextForCounterSkills(t *testing.T) {
+	queue := []queueing.Entry{party(1, 1, 0, "returns"), party(2, 1, 0, "general"), party(3, 1, 0, "")}
+	rules := queueing.CallRules{SkipLimit: 3}
+
+	tests := []struct {
+		name      string
+		counter   *queueing.Counter
+		queue     []queueing.Entry
+		wantIndex int
+		wantErr   error
+	}{
+		{name: "No counter calls anyone", counter: nil, queue: queue, wantIndex: 0},
+		{name: "No skills calls anyone", counter: &queueing.Counter{}, queue: queue, wantIndex: 0},
+		{name: "Skills pick the first match", counter: &queueing.Counter{Skills: []string{"general"}}, queue: queue, wantIndex: 1},
+		{name: "Untyped entries go to any counter", counter: &queueing.Counter{Skills: []string{"loans"}}, queue: queue, wantIndex: 2},
+		{
+			name:      "No match without overflow",
+			counter:   &queueing.Counter{Skills: []string{"loans"}},
+			queue:     queue[:2],
+			wantIndex: -1,
+			wantErr:   queueing.ErrNoEligibleEntry,
+		},
+		{
+			name:      "Overflow calls the first customer",
+			counter:   &queueing.Counter{Skills: []string{"loans"}, Overflow: true},
+			queue:     queue[:2],
+			wantIndex: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, passed, err := queueing.NextFor(tt.counter, rules, tt.queue)
+			checkNext(t, index, passed, err, tt.wantIndex, nil, tt.wantErr)
+		})
+	}
+}
+
+func TestNextForOverflowKeepsSkipLimit(t *testing.T) {
+	// The matching party has been skipped enough, so overflow must not let
+	// the counter call a smaller party of another type instead
+	counter := &queueing.Counter{Skills: []string{"general"}, Overflow: true}
+	queue := []queueing.Entry{party(1, 6, 3, "general"), party(2, 2, 0, "returns")}
+
+	index, passed, err := queueing.NextFor(counter, queueing.CallRules{Seats: 2, SkipLimit: 3}, queue)
+	checkNext(t, index, passed, err, -1, nil, &queueing.PassedOverError{})
+}
+
+func TestNextForArrivedOnly(t *testing.T) {
+	now := time.Now()
+	arrived := func(e queueing.Entry) queueing.Entry {
+		e.ArrivedAt = &now
+		return e
+	}
+	rules := queueing.CallRules{SkipLimit: 3, ArrivedOnly: true}
+
+	tests := []struct {
+		name      string
+		counter   *queueing.Counter
+		rules     queueing.CallRules
+		queue     []queueing.Entry
+		wantIndex int
+		wantErr   error
+	}{
+		{
+			name:      "Unarrived walk-in is passed over",
+			rules:     rules,
+			queue:     []queueing.Entry{party(1, 1, 0, ""), arrived(party(2, 1, 0, ""))},
+			wantIndex: 1,
+		},
+		{
+			name:      "Arrived walk-in at the head is called",
+			rules:     rules,
+			queue:     []queueing.Entry{arrived(party(1, 1, 0, "")), party(2, 1, 0, "")},
+			wantIndex: 0,
+		},
+		{
+			name:      "Nobody has arrived",
+			rules:     rules,
+			queue:     []queueing.Entry{party(1, 1, 0, ""), party(2, 1, 0, "")},
+			wantIndex: -1,
+			wantErr:   queueing.ErrNotArrived,
+		},
+		{
+			name:      "Arrival is not required",
+			rules:     queueing.CallRules{SkipLimit: 3},
+			queue:     []queueing.Entry{party(1, 1, 0, ""), arrived(party(2, 1, 0, ""))},
+			wantIndex: 0,
+		},
+		{
+			name:      "Counter skills still apply",
+			counter:   &queueing.Counter{Skills: []string{"loans"}},
+			rules:     rules,
+			queue:     []queueing.Entry{arrived(party(1, 1, 0, "general")), party(2, 1, 0, "loans")},
+			wantIndex: -1,
+			wantErr:   queueing.ErrNotArrived,
+		},
+		{
+			name:      "Nobody the counter handles is waiting",
+			counter:   &queueing.Counter{Skills: []string{"loans"}},
+			rules:     rules,
+			queue:     []queueing.Entry{arrived(party(1, 1, 0, "general"))},
+			wantIndex: -1,
+			wantErr:   queueing.ErrNoEligibleEntry,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			index, passed, err := queueing.NextFor(tt.counter, tt.rules, tt.queue)
+			checkNext(t, index, passed, err, tt.wantIndex, nil, tt.wantErr)
+		})
+	}
+}
+
+func TestCallOutOfTurn(t *testing.T) {
+	now := time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC)
+	desk := &queueing.Counter{ID: 3, Name: "Desk 3"}
+
+	tests := []struct {
+		name         string
+		id           int
+		counter      *queueing.Counter
+		wantPosition int
+		wantRest     []int
+	}{
+		{name: "Head of the queue", id: 1, wantPosition: 1, wantRest: []int{2, 3, 4}},
+		{name: "Middle of the queue", id: 3, counter: desk, wantPosition: 3, wantRest: []int{1, 2, 4}},
+		{name: "Back of the queue", id: 4, wantPosition: 4, wantRest: []int{1, 2, 3}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := []queueing.Entry{party(1, 1, 0, ""), party(2, 1, 0, ""), party(3, 1, 0, ""), party(4, 1, 0, "")}
+			for i := range queue {
+				queue[i].QueueOrder = int64(i+1) * queueing.QueueOrderGap
+			}
+			waiting := queue[slices.IndexFunc(queue, func(e queueing.Entry) bool { return e.ID == tt.id })]
+			if got := queueing.Position(queue, waiting); got != tt.wantPosition {
+				t.Errorf("Position() before the call = %d, want %d", got, tt.wantPosition)
+			}
+
+			called, rest, err := queueing.Call(queue, tt.id, tt.counter, now)
+			if err != nil {
+				t.Fatalf("Call() error = %v", err)
+			}
+			if called.ID != tt.id || called.Status != queueing.StatusNotified || called.CalledAt == nil || !called.CalledAt.Equal(now) {
+				t.Errorf("Call() = %+v, want entry %d notified at %s", called, tt.id, now)
+			}
+			if tt.counter != nil && (called.CounterID == nil || *called.CounterID != tt.counter.ID || called.Counter != tt.counter.Name) {
+				t.Errorf("Call() counter = %v %q, want %d %q", called.CounterID, called.Counter, tt.counter.ID, tt.counter.Name)
+			}
+			if tt.counter == nil && called.CounterID != nil {
+				t.Errorf("Call() without a counter set counter %d", *called.CounterID)
+			}
+
+			var ids []int
+			for _, e := range rest {
+				ids = append(ids, e.ID)
+			}
+			if !slices.Equal(ids, tt.wantRest) {
+				t.Errorf("queue after Call() = %v, want %v", ids, tt.wantRest)
+			}
+			for i, e := range rest {
+				if got := queueing.Position(rest, e); got != i+1 {
+					t.Errorf("entry %d is at position %d after the call, want %d", e.ID, got, i+1)
+				}
+			}
+			if len(queue) != 4 || queue[slices.IndexFunc(queue, func(e queueing.Entry) bool { return e.ID == tt.id })].Status != queueing.StatusWaiting {
+				t.Error("Call() modified the queue it was given")
+			}
+		})
+	}
+}
+
+func TestCallOutOfTurnErrors(t *testing.T) {
+	notified := party(2, 1, 0, "")
+	notified.Status = queueing.StatusNotified
+	queue := []queueing.Entry{party(1, 1, 0, ""), notified}
+
+	if _, _, err := queueing.Call(queue, 9, nil, time.Now()); err == nil {
+		t.Error("Call() of an entry not in the queue succeeded")
+	}
+	if _, _, err := queueing.Call(queue, 2, nil, time.Now()); !errors.Is(err, queueing.ErrInvalidTransition) {
+		t.Errorf("Call() of a notified entry error = %v, want ErrInvalidTransition", err)
+	}
+	if got := queueing.Position(queue, notified); got != 0 {
+		t.Errorf("Position() of a notified entry = %d, want 0", got)
+	}
+}
+
+func checkNext(t *testing.T, index int, passed []int, err error, wantIndex int, wantPassed []int, wantErr error) {
+	t.Helper()
+
+	var passedOver *queueing.PassedOverError
+	switch {
+	case wantErr == nil && err != nil:
+		t.Fatalf("NextFor() error = %v, want none", err)
+	case errors.As(wantErr, &passedOver):
+		if !errors.As(err, &passedOver) || !errors.Is(err, queueing.ErrNoPartyFits) {
+			t.Fatalf("NextFor() error = %v, want a PassedOverError", err)
+		}
+	case wantErr != nil && !errors.Is(err, wantErr):
+		t.Fatalf("NextFor() error = %v, want %v", err, wantErr)
+	}
+
+	if index != wantIndex {
+		t.Errorf("NextFor() index = %d, want %d", index, wantIndex)
+	}
+	if !slices.Equal(passed, wantPassed) {
+		t.Errorf("NextFor() passed over = %v, want %v", passed, wantPassed)
+	}
+}
@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+// partiesOf builds a queue of waiting parties of the given sizes
+func partiesOf(sizes ...int) []queueing.Entry {
+	queue := make([]queueing.Entry, len(sizes))
+	for i, size := range sizes {
+		queue[i] = queueing.Entry{ID: i + 1, Status: queueing.StatusWaiting, PartySize: size, QueueOrder: int64(i+1) * queueing.QueueOrderGap}
+	}
+	return queue
+}
+
+func TestEstimateWait(t *testing.T) {
+	serviceTime := queueing.ServiceTime{Party: 5 * time.Minute, Guest: 2 * time.Minute}
+
+	tests := []struct {
+		name  string
+		ahead []queueing.Entry
+		want  time.Duration
+	}{
+		{name: "Nobody ahead", ahead: nil, want: 5 * time.Minute},
+		{name: "Two singles", ahead: partiesOf(1, 1), want: 15 * time.Minute},
+		{name: "Party of four", ahead: partiesOf(4), want: 16 * time.Minute},
+		{name: "Unset party size counts as one", ahead: partiesOf(0), want: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := queueing.EstimateWait(tt.ahead, serviceTime); got != tt.want {
+				t.Errorf("EstimateWait() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapacityFull(t *testing.T) {
+	serviceTime := queueing.ServiceTime{Party: 5 * time.Minute, Guest: 2 * time.Minute}
+
+	tests := []struct {
+		name     string
+		capacity queueing.Capacity
+		queue    []queueing.Entry
+		want     bool
+	}{
+		{name: "No limits", capacity: queueing.Capacity{}, queue: partiesOf(1, 1, 1, 1, 1, 1, 1, 1), want: false},
+		{name: "Below max waiting", capacity: queueing.Capacity{MaxWaiting: 3}, queue: partiesOf(1, 1), want: false},
+		{name: "At max waiting", capacity: queueing.Capacity{MaxWaiting: 3}, queue: partiesOf(1, 1, 1), want: true},
+		{name: "Even the first wait is too long", capacity: queueing.Capacity{MaxWaiting: 1, MaxWait: time.Minute}, queue: nil, want: true},
+		{name: "Wait at the limit", capacity: queueing.Capacity{MaxWait: 15 * time.Minute}, queue: partiesOf(1, 1), want: false},
+		{name: "Wait over the limit", capacity: queueing.Capacity{MaxWait: 15 * time.Minute}, queue: partiesOf(1, 2), want: true},
+		{name: "Large party fills by wait", capacity: queueing.Capacity{MaxWaiting: 10, MaxWait: 20 * time.Minute}, queue: partiesOf(8), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.capacity.Full(tt.queue, serviceTime); got != tt.want {
+				t.Errorf("Full() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseCapacity(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxWaiting int
+		maxWait    time.Duration
+		whenFull   string
+		wantErr    bool
+	}{
+		{name: "Off", whenFull: queueing.FullReject},
+		{name: "Waitlist", maxWaiting: 20, maxWait: time.Hour, whenFull: queueing.FullWaitlist},
+		{name: "Negative waiting", maxWaiting: -1, whenFull: queueing.FullReject, wantErr: true},
+		{name: "Negative wait", maxWait: -time.Minute, whenFull: queueing.FullReject, wantErr: true},
+		{name: "Unknown policy", whenFull: "queue-jump", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := queueing.ParseCapacity(tt.maxWaiting, tt.maxWait, tt.whenFull)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseCapacity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			want := queueing.Capacity{MaxWaiting: tt.maxWaiting, MaxWait: tt.maxWait, WhenFull: tt.whenFull}
+			if !tt.wantErr && got != want {
+				t.Errorf("ParseCapacity() = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
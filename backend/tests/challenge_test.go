@@ -0,0 +1,73 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"wait-to-go/challenge"
+)
+
+func TestProofOfWork(t *testing.T) {
+	pow, err := challenge.NewProofOfWork(8, time.Minute)
+	if err != nil {
+		t.Fatalf("NewProofOfWork() error = %v", err)
+	}
+
+	c, err := pow.NewChallenge()
+	if err != nil {
+		t.Fatalf("NewChallenge() error = %v", err)
+	}
+	token := challenge.Solve(c, 8)
+
+	if err := pow.Verify(token, "127.0.0.1"); err != nil {
+		t.Errorf("Verify() of solved challenge error = %v", err)
+	}
+	if err := pow.Verify(token, "127.0.0.1"); !errors.Is(err, challenge.ErrReplayed) {
+		t.Errorf("Verify() of reused solution error = %v, want %v", err, challenge.ErrReplayed)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{name: "Missing token", token: "", wantErr: challenge.ErrMissing},
+		{name: "No nonce", token: c, wantErr: challenge.ErrInvalid},
+		{name: "Forged challenge", token: "9999999999.abcdef.00:1", wantErr: challenge.ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := pow.Verify(tt.token, "127.0.0.1"); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify(%q) error = %v, want %v", tt.token, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestProofOfWorkExpired(t *testing.T) {
+	pow, err := challenge.NewProofOfWork(1, -time.Minute)
+	if err != nil {
+		t.Fatalf("NewProofOfWork() error = %v", err)
+	}
+
+	c, _ := pow.NewChallenge()
+	if err := pow.Verify(challenge.Solve(c, 1), "127.0.0.1"); !errors.Is(err, challenge.ErrExpired) {
+		t.Errorf("Verify() of expired challenge error = %v, want %v", err, challenge.ErrExpired)
+	}
+}
+
+func TestStaticVerifier(t *testing.T) {
+	verifier := challenge.StaticVerifier{Token: "local-dev"}
+
+	if err := verifier.Verify("local-dev", ""); err != nil {
+		t.Errorf("Verify() with matching token error = %v", err)
+	}
+	if err := verifier.Verify("wrong", ""); !errors.Is(err, challenge.ErrInvalid) {
+		t.Errorf("Verify() with wrong token error = %v, want %v", err, challenge.ErrInvalid)
+	}
+	if err := verifier.Verify("", ""); !errors.Is(err, challenge.ErrMissing) {
+		t.Errorf("Verify() with no token error = %v, want %v", err, challenge.ErrMissing)
+	}
+}
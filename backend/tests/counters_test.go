@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+func TestClaimCounter(t *testing.T) {
+	earlier := time.Date(2026, 10, 20, 8, 0, 0, 0, time.UTC)
+	now := earlier.Add(time.Hour)
+	held := func(id int, by string) queueing.Counter {
+		return queueing.Counter{ID: id, Name: "Desk", ClaimedBy: by, ClaimedAt: &earlier, Claimed: true}
+	}
+	free := func(id int) queueing.Counter {
+		return queueing.Counter{ID: id, Name: "Desk"}
+	}
+
+	tests := []struct {
+		name        string
+		counters    []queueing.Counter
+		id          int
+		wantRelease []int
+		wantErr     error
+	}{
+		{name: "Free counter", counters: []queueing.Counter{free(1)}, id: 1},
+		{name: "Renew own claim", counters: []queueing.Counter{held(1, "alice")}, id: 1},
+		{name: "Move to another counter", counters: []queueing.Counter{held(1, "alice"), free(2)}, id: 2, wantRelease: []int{1}},
+		{name: "Held by someone else", counters: []queueing.Counter{held(1, "bob")}, id: 1, wantErr: queueing.ErrCounterClaimed},
+		{
+			name:     "Held by someone else keeps own counter",
+			counters: []queueing.Counter{held(1, "alice"), held(2, "bob")},
+			id:       2,
+			wantErr:  queueing.ErrCounterClaimed,
+		},
+		{name: "No such counter", counters: []queueing.Counter{held(1, "alice")}, id: 5, wantErr: queueing.ErrCounterNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, release, err := queueing.Claim(tt.counters, tt.id, "alice", now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Claim() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if release != nil {
+					t.Errorf("failed Claim() would release %v", release)
+				}
+				return
+			}
+
+			if counter.ID != tt.id || counter.ClaimedBy != "alice" || !counter.Claimed {
+				t.Errorf("Claim() = %+v, want counter %d claimed by alice", counter, tt.id)
+			}
+			if counter.ClaimedAt == nil || !counter.ClaimedAt.Equal(now) {
+				t.Errorf("ClaimedAt = %v, want %s", counter.ClaimedAt, now)
+			}
+			if !slices.Equal(release, tt.wantRelease) {
+				t.Errorf("Claim() releases %v, want %v", release, tt.wantRelease)
+			}
+		})
+	}
+}
+
+// counterDesk applies claims and releases to a set of counters the way
+// claimCounter and releaseCounter store them
+type counterDesk map[int]queueing.Counter
+
+func (d counterDesk) claim(id int, operator string) error {
+	var counters []queueing.Counter
+	for _, c := range d {
+		if c.ID == id || c.ClaimedBy == operator {
+			counters = append(counters, c)
+		}
+	}
+	counter, release, err := queueing.Claim(counters, id, operator, time.Now())
+	if err != nil {
+		return err
+	}
+	d[id] = counter
+	for _, other := range release {
+		d.release(other)
+	}
+	return nil
+}
+
+func (d counterDesk) release(id int) {
+	c := d[id]
+	c.ClaimedBy, c.ClaimedAt, c.Claimed = "", nil, false
+	d[id] = c
+}
+
+func (d counterDesk) holders() map[string][]int {
+	held := map[string][]int{}
+	for _, c := range d {
+		if c.ClaimedBy != "" {
+			held[c.ClaimedBy] = append(held[c.ClaimedBy], c.ID)
+		}
+	}
+	return held
+}
+
+func TestCounterClaimAndRelease(t *testing.T) {
+	desk := counterDesk{1: {ID: 1, Name: "Desk 1"}, 2: {ID: 2, Name: "Desk 2"}}
+
+	if err := desk.claim(1, "alice"); err != nil {
+		t.Fatalf("alice claiming a free counter: %v", err)
+	}
+	if err := desk.claim(1, "bob"); !errors.Is(err, queueing.ErrCounterClaimed) {
+		t.Fatalf("bob claiming alice's counter error = %v, want ErrCounterClaimed", err)
+	}
+	if err := desk.claim(2, "alice"); err != nil {
+		t.Fatalf("alice moving to another counter: %v", err)
+	}
+	if held := desk.holders(); !slices.Equal(held["alice"], []int{2}) {
+		t.Fatalf("alice holds %v after moving, want only counter 2", held["alice"])
+	}
+	if err := desk.claim(1, "bob"); err != nil {
+		t.Fatalf("bob claiming the counter alice left: %v", err)
+	}
+
+	// A counter left claimed by a closed browser is released by someone else
+	desk.release(2)
+	if err := desk.claim(2, "carol"); err != nil {
+		t.Fatalf("carol claiming a released counter: %v", err)
+	}
+	held := desk.holders()
+	if len(held["alice"]) != 0 || !slices.Equal(held["bob"], []int{1}) || !slices.Equal(held["carol"], []int{2}) {
+		t.Errorf("counters are held by %v, want bob at 1 and carol at 2", held)
+	}
+}
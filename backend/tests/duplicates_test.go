@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"testing"
+
+	"wait-to-go/queueing"
+)
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		maxActive     int
+		wantMaxActive int
+		wantErr       bool
+	}{
+		{name: "Reject ignores the limit", mode: queueing.DuplicateReject, maxActive: 5, wantMaxActive: 1},
+		{name: "Return ignores the limit", mode: queueing.DuplicateReturn, maxActive: 0, wantMaxActive: 1},
+		{name: "Allow keeps the limit", mode: queueing.DuplicateAllow, maxActive: 3, wantMaxActive: 3},
+		{name: "Allow needs a limit", mode: queueing.DuplicateAllow, maxActive: 0, wantErr: true},
+		{name: "Unknown mode", mode: "merge", maxActive: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := queueing.ParseDuplicatePolicy(tt.mode, tt.maxActive)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDuplicatePolicy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (policy.Mode != tt.mode || policy.MaxActive != tt.wantMaxActive) {
+				t.Errorf("ParseDuplicatePolicy() = %+v, want mode %s with MaxActive %d", policy, tt.mode, tt.wantMaxActive)
+			}
+		})
+	}
+}
+
+func TestDuplicatePolicyAllows(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		maxActive int
+		active    int
+		want      bool
+	}{
+		{name: "Reject a second entry", mode: queueing.DuplicateReject, active: 1, want: false},
+		{name: "Return a second entry", mode: queueing.DuplicateReturn, active: 1, want: false},
+		{name: "Allow one more", mode: queueing.DuplicateAllow, maxActive: 3, active: 2, want: true},
+		{name: "Allow up to the limit", mode: queueing.DuplicateAllow, maxActive: 3, active: 3, want: false},
+		{name: "Allow with a limit of one", mode: queueing.DuplicateAllow, maxActive: 1, active: 1, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := queueing.ParseDuplicatePolicy(tt.mode, max(tt.maxActive, 1))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := policy.Allows(tt.active); got != tt.want {
+				t.Errorf("Allows(%d) = %v, want %v", tt.active, got, tt.want)
+			}
+		})
+	}
+}
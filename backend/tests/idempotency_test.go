@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wait-to-go/idempotency"
+)
+
+// memoryStore keeps idempotency keys in a map
+type memoryStore struct {
+	mu        sync.Mutex
+	responses map[string]idempotency.Response
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{responses: map[string]idempotency.Response{}}
+}
+
+func (s *memoryStore) Claim(claim idempotency.Response, expiredBefore time.Time) (bool, idempotency.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := claim.Scope + " " + claim.Key
+	if existing, ok := s.responses[id]; ok && !existing.CreatedAt.Before(expiredBefore) {
+		return false, existing, nil
+	}
+	s.responses[id] = claim
+	return true, claim, nil
+}
+
+func (s *memoryStore) Save(response idempotency.Response) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[response.Scope+" "+response.Key] = response
+	return nil
+}
+
+func (s *memoryStore) Release(scope, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.responses, scope+" "+key)
+	return nil
+}
+
+// countingHandler answers with the next status in statuses and counts calls
+type countingHandler struct {
+	calls    int
+	statuses []int
+}
+
+func (h *countingHandler) serve(w http.ResponseWriter, r *http.Request) {
+	status := h.statuses[min(h.calls, len(h.statuses)-1)]
+	h.calls++
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(`{"call":` + strconv.Itoa(h.calls) + `}`))
+}
+
+func newIdempotent(store idempotency.Store, next http.HandlerFunc) http.HandlerFunc {
+	m := &idempotency.Middleware{
+		Store: store,
+		TTL:   time.Hour,
+		Scope: func(r *http.Request) string { return r.Method + " " + r.URL.Path },
+	}
+	return m.Wrap(next)
+}
+
+func postWithKey(handler http.HandlerFunc, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/queues/default/entries:next", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(idempotency.KeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	handler(w, req)
+	return w
+}
+
+func TestIdempotencyReplay(t *testing.T) {
+	next := &countingHandler{statuses: []int{http.StatusOK}}
+	handler := newIdempotent(newMemoryStore(), next.serve)
+
+	first := postWithKey(handler, "key-1", `{}`)
+	second := postWithKey(handler, "key-1", `{}`)
+
+	if next.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", next.calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() {
+		t.Errorf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+	if second.Header().Get(idempotency.ReplayHeader) != "true" {
+		t.Errorf("replay is missing %s", idempotency.ReplayHeader)
+	}
+	if first.Header().Get(idempotency.ReplayHeader) != "" {
+		t.Errorf("first response has %s set", idempotency.ReplayHeader)
+	}
+
+	postWithKey(handler, "", `{}`)
+	postWithKey(handler, "", `{}`)
+	if next.calls != 3 {
+		t.Errorf("handler ran %d times with and without keys, want 3", next.calls)
+	}
+}
+
+func TestIdempotencyBodyMismatch(t *testing.T) {
+	next := &countingHandler{statuses: []int{http.StatusCreated}}
+	handler := newIdempotent(newMemoryStore(), next.serve)
+
+	postWithKey(handler, "key-1", `{"firstName":"Ada"}`)
+	w := postWithKey(handler, "key-1", `{"firstName":"Grace"}`)
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key status = %d, want %d", w.Code, http.StatusUnprocessableEntity)
+	}
+	if !strings.Contains(w.Body.String(), "idempotency_key_reused") {
+		t.Errorf("reused key body = %s, want idempotency_key_reused", w.Body)
+	}
+	if next.calls != 1 {
+		t.Errorf("handler ran %d times, want 1", next.calls)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := newMemoryStore()
+	var inner *httptest.ResponseRecorder
+	var handler http.HandlerFunc
+	handler = newIdempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		// The same key arrives again while this request is running
+		inner = postWithKey(handler, "key-1", `{}`)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	postWithKey(handler, "key-1", `{}`)
+	if inner == nil || inner.Code != http.StatusConflict || !strings.Contains(inner.Body.String(), "idempotency_key_in_use") {
+		t.Errorf("concurrent repeat = %v, want 409 idempotency_key_in_use", inner)
+	}
+}
+
+func TestIdempotencyRetriesUnstoredResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		wantCalls int
+	}{
+		{name: "Success is replayed", status: http.StatusOK, wantCalls: 1},
+		{name: "Created is replayed", status: http.StatusCreated, wantCalls: 1},
+		{name: "Validation error is replayed", status: http.StatusBadRequest, wantCalls: 1},
+		{name: "Unprocessable is replayed", status: http.StatusUnprocessableEntity, wantCalls: 1},
+		{name: "Paused or full queue runs again", status: http.StatusConflict, wantCalls: 2},
+		{name: "Rate limit runs again", status: http.StatusTooManyRequests, wantCalls: 2},
+		{name: "Server error runs again", status: http.StatusInternalServerError, wantCalls: 2},
+		{name: "Unavailable runs again", status: http.StatusServiceUnavailable, wantCalls: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := &countingHandler{statuses: []int{tt.status}}
+			handler := newIdempotent(newMemoryStore(), next.serve)
+
+			postWithKey(handler, "key-1", `{}`)
+			postWithKey(handler, "key-1", `{}`)
+			if next.calls != tt.wantCalls {
+				t.Errorf("handler ran %d times, want %d", next.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	store := newMemoryStore()
+	panics := true
+	handler := newIdempotent(store, func(w http.ResponseWriter, r *http.Request) {
+		if panics {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	func() {
+		defer func() { recover() }()
+		postWithKey(handler, "key-1", `{}`)
+	}()
+
+	panics = false
+	if w := postWithKey(handler, "key-1", `{}`); w.Code != http.StatusOK {
+		t.Errorf("retry after panic status = %d, want %d", w.Code, http.StatusOK)
+	}
+}
+
+func TestIdempotencyKeyTooLong(t *testing.T) {
+	next := &countingHandler{statuses: []int{http.StatusOK}}
+	handler := newIdempotent(newMemoryStore(), next.serve)
+
+	if w := postWithKey(handler, strings.Repeat("k", 256), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key status = %d, want %d", w.Code, http.StatusBadRequest)
+	}
+	if next.calls != 0 {
+		t.Errorf("handler ran %d times, want 0", next.calls)
+	}
+}
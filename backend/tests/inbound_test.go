@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"wait-to-go/auth"
+	"wait-to-go/notify"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("webhook-secret")
+	body := []byte(`{"from":"+15551234567","body":"YES"}`)
+	valid := hex.EncodeToString(auth.SignPayload(secret, body))
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		body      []byte
+		signature string
+		want      bool
+	}{
+		{name: "Valid", secret: secret, body: body, signature: valid, want: true},
+		{name: "Valid with prefix", secret: secret, body: body, signature: "sha256=" + valid, want: true},
+		{name: "Wrong secret", secret: []byte("other"), body: body, signature: valid, want: false},
+		{name: "Tampered body", secret: secret, body: []byte(`{"from":"+15551234567","body":"STOP"}`), signature: valid, want: false},
+		{name: "Not hex", secret: secret, body: body, signature: "sha256=zz", want: false},
+		{name: "Truncated", secret: secret, body: body, signature: valid[:32], want: false},
+		{name: "Missing", secret: secret, body: body, signature: "", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auth.VerifySignature(tt.secret, tt.body, tt.signature); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyTimestamped(t *testing.T) {
+	secret := []byte("webhook-secret")
+	body := []byte(`{"from":"+15551234567","body":"DELAY"}`)
+	now := time.Unix(1_800_000_000, 0)
+	stamp := func(t time.Time) string { return strconv.FormatInt(t.Unix(), 10) }
+	sent := now.Add(-time.Minute)
+	valid := hex.EncodeToString(auth.SignTimestamped(secret, sent.Unix(), body))
+
+	tests := []struct {
+		name      string
+		body      []byte
+		timestamp string
+		signature string
+		now       time.Time
+		want      bool
+	}{
+		{name: "Valid", body: body, timestamp: stamp(sent), signature: valid, now: now, want: true},
+		{name: "Valid with prefix", body: body, timestamp: stamp(sent), signature: "sha256=" + valid, now: now, want: true},
+		{name: "Replayed later", body: body, timestamp: stamp(sent), signature: valid, now: now.Add(auth.SignatureTolerance), want: false},
+		{name: "Timestamp moved forward", body: body, timestamp: stamp(now), signature: valid, now: now, want: false},
+		{
+			name:      "Too far in the future",
+			body:      body,
+			timestamp: stamp(now.Add(auth.SignatureTolerance + time.Second)),
+			signature: hex.EncodeToString(auth.SignTimestamped(secret, now.Add(auth.SignatureTolerance+time.Second).Unix(), body)),
+			now:       now,
+			want:      false,
+		},
+		{name: "Body only signature", body: body, timestamp: stamp(sent), signature: hex.EncodeToString(auth.SignPayload(secret, body)), now: now, want: false},
+		{name: "Tampered body", body: []byte(`{"from":"+15551234567","body":"CANCEL"}`), timestamp: stamp(sent), signature: valid, now: now, want: false},
+		{name: "Missing timestamp", body: body, timestamp: "", signature: valid, now: now, want: false},
+		{name: "Timestamp not a number", body: body, timestamp: "yesterday", signature: valid, now: now, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := auth.VerifyTimestamped(secret, tt.body, tt.timestamp, tt.signature, tt.now); got != tt.want {
+				t.Errorf("VerifyTimestamped() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseAction(t *testing.T) {
+	tests := []struct {
+		body   string
+		want   string
+		wantOK bool
+	}{
+		{body: "1", want: notify.ActionConfirm, wantOK: true},
+		{body: " yes\n", want: notify.ActionConfirm, wantOK: true},
+		{body: "Y", want: notify.ActionConfirm, wantOK: true},
+		{body: "delay", want: notify.ActionDelay, wantOK: true},
+		{body: "Stop", want: notify.ActionCancel, wantOK: true},
+		{body: "CANCEL", want: notify.ActionCancel, wantOK: true},
+		{body: "yes please", wantOK: false},
+		{body: "", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.body, func(t *testing.T) {
+			got, ok := notify.ParseAction(tt.body)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ParseAction(%q) = %q, %v, want %q, %v", tt.body, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestDecodeInboundSMS(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        notify.InboundSMS
+		wantErr     bool
+	}{
+		{
+			name:        "JSON",
+			contentType: "application/json; charset=utf-8",
+			body:        `{"from":"+15551234567","body":"YES"}`,
+			want:        notify.InboundSMS{From: "+15551234567", Body: "YES"},
+		},
+		{
+			name:        "Form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "From=%2B15551234567&Body=delay&MessageSid=SM1",
+			want:        notify.InboundSMS{From: "+15551234567", Body: "delay"},
+		},
+		{
+			name:        "Bad JSON",
+			contentType: "application/json",
+			body:        `{"from":`,
+			wantErr:     true,
+		},
+		{
+			name:        "Bad form",
+			contentType: "application/x-www-form-urlencoded",
+			body:        "From=%zz",
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := notify.DecodeInboundSMS(tt.contentType, []byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeInboundSMS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("DecodeInboundSMS() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"cmp"
+	"encoding/base64"
+	"net/url"
+	"slices"
+	"testing"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+func TestParseEntryFilterSort(t *testing.T) {
+	tests := []struct {
+		sort     string
+		wantSort string
+		wantDesc bool
+		wantErr  bool
+	}{
+		{sort: "", wantSort: "queueOrder"},
+		{sort: "position", wantSort: "queueOrder"},
+		{sort: "-joinTime", wantSort: "joinTime", wantDesc: true},
+		{sort: "lastName", wantSort: "lastName"},
+		{sort: "-id", wantSort: "id", wantDesc: true},
+		{sort: "phoneNumber", wantErr: true},
+		{sort: "queueOrder", wantErr: true},
+		{sort: "joinTime; DROP TABLE entry", wantErr: true},
+		{sort: "--id", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			filter, fields := queueing.ParseEntryFilter(url.Values{"sort": {tt.sort}})
+			if (fields["sort"] != "") != tt.wantErr {
+				t.Fatalf("ParseEntryFilter(sort=%q) errors = %v, wantErr %v", tt.sort, fields, tt.wantErr)
+			}
+			if !tt.wantErr && (filter.Sort != tt.wantSort || filter.Descending != tt.wantDesc) {
+				t.Errorf("ParseEntryFilter(sort=%q) = %s desc %v, want %s desc %v", tt.sort, filter.Sort, filter.Descending, tt.wantSort, tt.wantDesc)
+			}
+		})
+	}
+}
+
+func TestParseEntryFilterCursor(t *testing.T) {
+	raw := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+
+	tests := []struct {
+		name    string
+		sort    string
+		cursor  string
+		wantErr bool
+	}{
+		{name: "Issued for the same sort", sort: "joinTime", cursor: queueing.EntryCursor{Sort: "joinTime", Value: "2026-10-20T09:00:00.5Z", ID: 4}.Encode()},
+		{name: "Issued for the default sort", sort: "", cursor: queueing.EntryCursor{Sort: "queueOrder", Value: "3000", ID: 4}.Encode()},
+		{name: "Issued for another column", sort: "lastName", cursor: queueing.EntryCursor{Sort: "joinTime", Value: "2026-10-20T09:00:00Z", ID: 4}.Encode(), wantErr: true},
+		{name: "Issued for the other direction", sort: "-lastName", cursor: queueing.EntryCursor{Sort: "lastName", Value: "Smith", ID: 4}.Encode(), wantErr: true},
+		{name: "Not base64", sort: "id", cursor: "not a cursor!", wantErr: true},
+		{name: "Not JSON", sort: "id", cursor: raw("id=4"), wantErr: true},
+		{name: "No ID", sort: "lastName", cursor: raw(`{"s":"lastName","v":"Smith"}`), wantErr: true},
+		{name: "Edited time", sort: "joinTime", cursor: raw(`{"s":"joinTime","v":"yesterday","id":4}`), wantErr: true},
+		{name: "Edited position", sort: "position", cursor: raw(`{"s":"queueOrder","v":"1 OR 1=1","id":4}`), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, fields := queueing.ParseEntryFilter(url.Values{"sort": {tt.sort}, "cursor": {tt.cursor}})
+			if (fields["cursor"] != "") != tt.wantErr {
+				t.Errorf("ParseEntryFilter() errors = %v, wantErr %v", fields, tt.wantErr)
+			}
+		})
+	}
+}
+
+// listed is the rows of entries matching filter in its order, the way
+// listEntries queries them: after the cursor on (sort column, id) and at most
+// Limit+1 of them
+func listed(t *testing.T, entries []queueing.Entry, filter queueing.EntryFilter) []queueing.Entry {
+	t.Helper()
+	key := func(e queueing.Entry) any {
+		switch filter.Sort {
+		case "joinTime":
+			return e.JoinTime
+		case "queueOrder":
+			return e.QueueOrder
+		case "lastName":
+			return e.LastName
+		case "firstName":
+			return e.FirstName
+		}
+		return e.ID
+	}
+	compare := func(a any, aID int, b any, bID int) int {
+		var c int
+		switch a := a.(type) {
+		case time.Time:
+			c = a.Compare(b.(time.Time))
+		case int64:
+			c = cmp.Compare(a, b.(int64))
+		case string:
+			c = cmp.Compare(a, b.(string))
+		case int:
+			c = cmp.Compare(a, b.(int))
+		}
+		if c == 0 {
+			c = cmp.Compare(aID, bID)
+		}
+		if filter.Descending {
+			c = -c
+		}
+		return c
+	}
+
+	rows := slices.SortedFunc(slices.Values(entries), func(a, b queueing.Entry) int {
+		return compare(key(a), a.ID, key(b), b.ID)
+	})
+	if filter.After != nil {
+		after, err := filter.CursorValue()
+		if err != nil {
+			t.Fatalf("CursorValue() error = %v", err)
+		}
+		rows = slices.DeleteFunc(rows, func(e queueing.Entry) bool {
+			return compare(key(e), e.ID, after, filter.After.ID) <= 0
+		})
+	}
+	return rows[:min(len(rows), filter.Limit+1)]
+}
+
+func TestEntryPagesCoverEveryEntryOnce(t *testing.T) {
+	// Ties in every column, and join times that differ by less than a second
+	base := time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC)
+	names := []string{"Smith", "Jones", "Smith", "Brown", "Jones", "Smith", "Adams"}
+	var entries []queueing.Entry
+	for i := range 17 {
+		entries = append(entries, queueing.Entry{
+			ID:         i + 1,
+			FirstName:  names[(i*3)%len(names)],
+			LastName:   names[i%len(names)],
+			JoinTime:   base.Add(time.Duration(i/3) * 1500 * time.Microsecond),
+			QueueOrder: int64((i % 5) * 1000),
+		})
+	}
+
+	for _, sort := range []string{"position", "-position", "joinTime", "-joinTime", "lastName", "-lastName", "firstName", "id", "-id"} {
+		for _, limit := range []string{"1", "4", "17", "50"} {
+			t.Run(sort+" by "+limit, func(t *testing.T) {
+				var seen []int
+				cursor := ""
+				for pages := 0; ; pages++ {
+					if pages > len(entries) {
+						t.Fatal("paging did not end")
+					}
+					query := url.Values{"sort": {sort}, "limit": {limit}}
+					if cursor != "" {
+						query.Set("cursor", cursor)
+					}
+					filter, fields := queueing.ParseEntryFilter(query)
+					if len(fields) > 0 {
+						t.Fatalf("ParseEntryFilter() errors = %v on page %d", fields, pages+1)
+					}
+
+					page := filter.Page(listed(t, entries, filter), len(entries))
+					if len(page.Entries) > filter.Limit {
+						t.Fatalf("page %d has %d entries, over the limit of %d", pages+1, len(page.Entries), filter.Limit)
+					}
+					for _, e := range page.Entries {
+						seen = append(seen, e.ID)
+					}
+					if page.NextCursor == "" {
+						break
+					}
+					cursor = page.NextCursor
+				}
+
+				all, _ := queueing.ParseEntryFilter(url.Values{"sort": {sort}})
+				all.Limit = len(entries)
+				var wantIDs []int
+				for _, e := range listed(t, entries, all) {
+					wantIDs = append(wantIDs, e.ID)
+				}
+				if !slices.Equal(seen, wantIDs) {
+					t.Errorf("pages listed %v, want each entry once in order %v", seen, wantIDs)
+				}
+			})
+		}
+	}
+}
+
+func TestEntryPageLastPage(t *testing.T) {
+	filter := queueing.EntryFilter{Sort: "id", Limit: 2}
+	if page := filter.Page(nil, 0); page.Entries == nil || page.NextCursor != "" {
+		t.Errorf("empty Page() = %+v, want an empty list and no cursor", page)
+	}
+	entries := []queueing.Entry{{ID: 1}, {ID: 2}}
+	if page := filter.Page(entries, 2); len(page.Entries) != 2 || page.NextCursor != "" {
+		t.Errorf("Page() of exactly Limit rows = %+v, want no cursor", page)
+	}
+}
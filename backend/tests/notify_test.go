@@ -0,0 +1,69 @@
+package tests
+
+import (
+	"testing"
+
+	"wait-to-go/notify"
+)
+
+type recordingSender struct {
+	sent []notify.Message
+}
+
+func (s *recordingSender) Send(msg notify.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestParseChannel(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		want   notify.Channel
+		wantOK bool
+	}{
+		{name: "Empty defaults to SMS", input: "", want: notify.ChannelSMS, wantOK: true},
+		{name: "Email", input: "email", want: notify.ChannelEmail, wantOK: true},
+		{name: "None", input: "none", want: notify.ChannelNone, wantOK: true},
+		{name: "Unknown", input: "pigeon", wantOK: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := notify.ParseChannel(tt.input)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseChannel(%q) ok = %v, want %v", tt.input, ok, tt.wantOK)
+			}
+			if ok && got != tt.want {
+				t.Errorf("ParseChannel(%q) = %v, want %v", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNotifierRoutesByChannel(t *testing.T) {
+	sms := &recordingSender{}
+	email := &recordingSender{}
+
+	notifier := notify.NewNotifier()
+	notifier.Register(notify.ChannelSMS, sms)
+	notifier.Register(notify.ChannelEmail, email)
+
+	if err := notifier.Send(notify.Message{Channel: notify.ChannelSMS, To: "+15550100", Body: "hi"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := notifier.Send(notify.Message{Channel: notify.ChannelEmail, To: "a@example.com", Body: "hi"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if err := notifier.Send(notify.Message{Channel: notify.ChannelNone, Body: "dropped"}); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	if len(sms.sent) != 1 || len(email.sent) != 1 {
+		t.Errorf("Expected one message per channel, got sms=%d email=%d", len(sms.sent), len(email.sent))
+	}
+
+	if err := notifier.Send(notify.Message{Channel: notify.ChannelSMS, Body: "no recipient"}); err == nil {
+		t.Error("Expected error for message without recipient")
+	}
+}
@@ -0,0 +1,288 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wait-to-go/apispec"
+)
+
+type openAPIDoc struct {
+	Servers []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) openAPIDoc {
+	t.Helper()
+
+	var doc openAPIDoc
+	if err := json.Unmarshal(apispec.Spec, &doc); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+	if len(doc.Servers) != 1 {
+		t.Fatalf("Expected one server, got %d", len(doc.Servers))
+	}
+	return doc
+}
+
+// parseBackend parses the main package sources one directory up, and the
+// queueing and webhook packages that hold the types main aliases
+func parseBackend(t *testing.T) []*ast.File {
+	t.Helper()
+
+	var files []string
+	for _, pattern := range []string{"../*.go", "../queueing/*.go", "../webhook/*.go"} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, matches...)
+	}
+
+	fset := token.NewFileSet()
+	var parsed []*ast.File
+	for _, name := range files {
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatalf("Failed to parse %s: %v", name, err)
+		}
+		parsed = append(parsed, f)
+	}
+	return parsed
+}
+
+// registeredRoutes lists the "METHOD /api/v1/..." routes registered with
+// mux.HandleFunc, expanding apiroute.CustomMethods into one "POST .../{id}:action"
+// route per action
+func registeredRoutes(t *testing.T) []string {
+	var routes []string
+	for _, f := range parseBackend(t) {
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || sel.Sel.Name != "HandleFunc" {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			pattern, _ := strconv.Unquote(lit.Value)
+			method, path, ok := strings.Cut(pattern, " ")
+			if !ok || !strings.HasPrefix(path, "/api/v1/") {
+				return true
+			}
+
+			if handler, ok := call.Args[1].(*ast.CallExpr); ok && isCallTo(handler, "apiroute", "CustomMethods") {
+				actions := handler.Args[0].(*ast.CompositeLit)
+				for _, elt := range actions.Elts {
+					key, _ := strconv.Unquote(elt.(*ast.KeyValueExpr).Key.(*ast.BasicLit).Value)
+					routes = append(routes, method+" "+path+":"+key)
+				}
+				return true
+			}
+
+			routes = append(routes, method+" "+path)
+			return true
+		})
+	}
+	slices.Sort(routes)
+	return routes
+}
+
+func isCallTo(call *ast.CallExpr, pkg, name string) bool {
+	sel, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != name {
+		return false
+	}
+	ident, ok := sel.X.(*ast.Ident)
+	return ok && ident.Name == pkg
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	doc := loadSpec(t)
+
+	var documented []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			if method == "parameters" {
+				continue
+			}
+			documented = append(documented, strings.ToUpper(method)+" "+doc.Servers[0].URL+path)
+		}
+	}
+	slices.Sort(documented)
+
+	routes := registeredRoutes(t)
+	if len(routes) == 0 {
+		t.Fatal("Found no /api/v1 routes")
+	}
+
+	for _, route := range routes {
+		if !slices.Contains(documented, route) {
+			t.Errorf("Route %s is not in openapi.json", route)
+		}
+	}
+	for _, op := range documented {
+		if !slices.Contains(routes, op) {
+			t.Errorf("openapi.json documents %s but no handler is registered for it", op)
+		}
+	}
+}
+
+// specSchemas maps schema names to the Go types they describe
+var specSchemas = map[string]string{
+	"Entry":           "Entry",
+	"EntryPage":       "EntryPage",
+	"JoinRequest":     "JoinRequest",
+	"Webhook":         "Webhook",
+	"WebhookDelivery": "Delivery",
+	"Blocked":         "Blocked",
+	"Counter":         "Counter",
+	"Queue":           "QueueInfo",
+	"Schedule":        "Schedule",
+	"Holiday":         "Holiday",
+	"OpeningHours":    "OpeningHours",
+	"ArrivalCode":     "ArrivalCode",
+}
+
+func TestOpenAPISchemas(t *testing.T) {
+	doc := loadSpec(t)
+
+	structs := map[string]*ast.StructType{}
+	for _, f := range parseBackend(t) {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if spec, ok := n.(*ast.TypeSpec); ok {
+				if st, ok := spec.Type.(*ast.StructType); ok {
+					structs[spec.Name.Name] = st
+				}
+			}
+			return true
+		})
+	}
+
+	for schema, typeName := range specSchemas {
+		st, ok := structs[typeName]
+		if !ok {
+			t.Errorf("Type %s not found", typeName)
+			continue
+		}
+
+		var fields []string
+		for _, field := range st.Fields.List {
+			if field.Tag == nil {
+				continue
+			}
+			tag, _ := strconv.Unquote(field.Tag.Value)
+			name, _, _ := strings.Cut(reflect.StructTag(tag).Get("json"), ",")
+			if name != "" && name != "-" {
+				fields = append(fields, name)
+			}
+		}
+
+		var properties []string
+		for name := range doc.Components.Schemas[schema].Properties {
+			properties = append(properties, name)
+		}
+
+		slices.Sort(fields)
+		slices.Sort(properties)
+		if !slices.Equal(fields, properties) {
+			t.Errorf("Schema %s has properties %v, but %s has JSON fields %v", schema, properties, typeName, fields)
+		}
+	}
+}
+
+func TestOpenAPIRefs(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(apispec.Spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if ref, ok := v["$ref"].(string); ok {
+				if !resolves(doc, ref) {
+					t.Errorf("Unresolved $ref %s", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(doc)
+}
+
+func resolves(doc map[string]interface{}, ref string) bool {
+	pointer, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return false
+	}
+
+	var node interface{} = doc
+	for _, part := range strings.Split(pointer, "/") {
+		obj, ok := node.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if node, ok = obj[part]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func TestDocsPagePinsSwaggerUI(t *testing.T) {
+	w := httptest.NewRecorder()
+	apispec.DocsHandler(w, httptest.NewRequest("GET", "/docs", nil))
+	page := w.Body.String()
+	policy := w.Header().Get("Content-Security-Policy")
+
+	assets := regexp.MustCompile(`(?:src|href)="(https?://[^"]+)"`).FindAllStringSubmatch(page, -1)
+	if len(assets) == 0 {
+		t.Fatal("docs page loads no external assets")
+	}
+	for _, m := range assets {
+		if !strings.HasPrefix(m[1], apispec.SwaggerUIURL) {
+			t.Errorf("docs page loads %s, want only assets under %s", m[1], apispec.SwaggerUIURL)
+		}
+	}
+
+	script := regexp.MustCompile(`(?s)<script>(.*?)</script>`).FindStringSubmatch(page)
+	if script == nil {
+		t.Fatal("docs page has no inline script")
+	}
+	sum := sha256.Sum256([]byte(script[1]))
+	if hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"; !strings.Contains(policy, hash) {
+		t.Errorf("Content-Security-Policy = %q, want it to allow the inline script %s", policy, hash)
+	}
+}
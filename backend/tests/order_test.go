@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"slices"
+	"sort"
+	"testing"
+
+	"wait-to-go/queueing"
+)
+
+// queueOf builds a sorted queue of entries with the given ordering keys,
+// numbering them from 1
+func queueOf(orders ...int64) []queueing.Entry {
+	queue := make([]queueing.Entry, len(orders))
+	for i, order := range orders {
+		queue[i] = queueing.Entry{ID: i + 1, Status: queueing.StatusWaiting, QueueOrder: order}
+	}
+	sort.Sort(queueing.ByQueueOrder(queue))
+	return queue
+}
+
+func ids(queue []queueing.Entry) []int {
+	var ids []int
+	for _, e := range queue {
+		ids = append(ids, e.ID)
+	}
+	return ids
+}
+
+func TestByQueueOrder(t *testing.T) {
+	queue := []queueing.Entry{
+		{ID: 3, QueueOrder: 2048},
+		{ID: 2, QueueOrder: 1024},
+		{ID: 1, QueueOrder: 2048},
+	}
+	sort.Sort(queueing.ByQueueOrder(queue))
+	if got, want := ids(queue), []int{2, 1, 3}; !slices.Equal(got, want) {
+		t.Errorf("sorted IDs = %v, want %v (IDs break ties)", got, want)
+	}
+}
+
+func TestPlace(t *testing.T) {
+	gap := int64(queueing.QueueOrderGap)
+
+	tests := []struct {
+		name           string
+		queue          []queueing.Entry
+		index          int
+		target         int
+		wantIDs        []int
+		wantAt         int
+		wantRenumbered bool
+		wantOrder      int64
+	}{
+		{
+			name:    "Stays put",
+			queue:   queueOf(gap, 2*gap, 3*gap),
+			index:   1,
+			target:  1,
+			wantIDs: []int{1, 2, 3},
+			wantAt:  1,
+		},
+		{
+			name:      "Delay between two others",
+			queue:     queueOf(gap, 2*gap, 3*gap, 4*gap),
+			index:     0,
+			target:    2,
+			wantIDs:   []int{2, 3, 1, 4},
+			wantAt:    2,
+			wantOrder: 3*gap + gap/2,
+		},
+		{
+			name:      "Move to the front",
+			queue:     queueOf(gap, 2*gap, 3*gap),
+			index:     2,
+			target:    0,
+			wantIDs:   []int{3, 1, 2},
+			wantAt:    0,
+			wantOrder: 0,
+		},
+		{
+			name:      "Target past the back is clamped",
+			queue:     queueOf(gap, 2*gap, 3*gap),
+			index:     0,
+			target:    10,
+			wantIDs:   []int{2, 3, 1},
+			wantAt:    2,
+			wantOrder: 4 * gap,
+		},
+		{
+			name:      "Adjacent keys fit when the ID breaks the tie",
+			queue:     queueOf(gap, gap+1, 3*gap),
+			index:     2,
+			target:    1,
+			wantIDs:   []int{1, 3, 2},
+			wantAt:    1,
+			wantOrder: gap,
+		},
+		{
+			name:           "Adjacent keys renumber the queue",
+			queue:          queueOf(gap, 2*gap, 2*gap+1),
+			index:          0,
+			target:         1,
+			wantIDs:        []int{2, 1, 3},
+			wantAt:         1,
+			wantRenumbered: true,
+			wantOrder:      2 * gap,
+		},
+		{
+			name:           "Equal keys renumber the queue",
+			queue:          queueOf(gap, gap, gap),
+			index:          0,
+			target:         1,
+			wantIDs:        []int{2, 1, 3},
+			wantAt:         1,
+			wantRenumbered: true,
+			wantOrder:      2 * gap,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := slices.Clone(tt.queue)
+			placed, at, renumbered := queueing.Place(tt.queue, tt.index, tt.target)
+
+			if !slices.Equal(tt.queue, before) {
+				t.Errorf("Place() changed its input to %v", tt.queue)
+			}
+			if got := ids(placed); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("Place() order = %v, want %v", got, tt.wantIDs)
+			}
+			if at != tt.wantAt || renumbered != tt.wantRenumbered {
+				t.Errorf("Place() at, renumbered = %d, %v, want %d, %v", at, renumbered, tt.wantAt, tt.wantRenumbered)
+			}
+			if at != tt.index && placed[at].QueueOrder != tt.wantOrder {
+				t.Errorf("Place() key = %d, want %d", placed[at].QueueOrder, tt.wantOrder)
+			}
+			if !sort.IsSorted(queueing.ByQueueOrder(placed)) {
+				t.Errorf("Place() keys are out of order: %+v", placed)
+			}
+			if renumbered {
+				for i, e := range placed {
+					if want := int64(i+1) * gap; e.QueueOrder != want {
+						t.Errorf("entry %d key = %d, want %d", e.ID, e.QueueOrder, want)
+					}
+				}
+			}
+		})
+	}
+}
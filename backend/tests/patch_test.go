@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"maps"
+	"strconv"
+	"strings"
+	"testing"
+
+	"wait-to-go/notify"
+	"wait-to-go/queueing"
+)
+
+func waitingEntry() queueing.Entry {
+	return queueing.Entry{
+		ID:                  1,
+		FirstName:           "Ada",
+		LastName:            "Lovelace",
+		Email:               "ada@example.com",
+		PhoneNumber:         "+15550100100",
+		Status:              queueing.StatusWaiting,
+		NotificationChannel: notify.ChannelEmail,
+		PartySize:           2,
+	}
+}
+
+func TestEntryPatchApply(t *testing.T) {
+	text := func(s string) *string { return &s }
+	size := func(n int) *int { return &n }
+
+	tests := []struct {
+		name  string
+		patch queueing.EntryPatch
+		want  map[string]queueing.FieldChange
+	}{
+		{name: "Empty patch", patch: queueing.EntryPatch{}, want: map[string]queueing.FieldChange{}},
+		{
+			name:  "One field",
+			patch: queueing.EntryPatch{Notes: text("Window seat")},
+			want:  map[string]queueing.FieldChange{"notes": {From: "", To: "Window seat"}},
+		},
+		{
+			name:  "Several fields",
+			patch: queueing.EntryPatch{FirstName: text("Augusta"), Email: text("augusta@example.com"), PartySize: size(4)},
+			want: map[string]queueing.FieldChange{
+				"firstName": {From: "Ada", To: "Augusta"},
+				"email":     {From: "ada@example.com", To: "augusta@example.com"},
+				"partySize": {From: "2", To: "4"},
+			},
+		},
+		{
+			name:  "Same values are not changes",
+			patch: queueing.EntryPatch{FirstName: text("Ada"), LastName: text("Lovelace"), PartySize: size(2)},
+			want:  map[string]queueing.FieldChange{},
+		},
+		{
+			name:  "Clearing a field",
+			patch: queueing.EntryPatch{Email: text("")},
+			want:  map[string]queueing.FieldChange{"email": {From: "ada@example.com", To: ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := waitingEntry()
+			updated, changes := tt.patch.Apply(entry)
+
+			if !maps.Equal(changes, tt.want) {
+				t.Errorf("Apply() changes = %v, want %v", changes, tt.want)
+			}
+			if entry != waitingEntry() {
+				t.Error("Apply() modified the entry it was given")
+			}
+			for field, change := range changes {
+				if got := fieldValue(updated, field); got != change.To {
+					t.Errorf("updated %s = %q, want %q", field, got, change.To)
+				}
+			}
+			if updated.PhoneNumber != entry.PhoneNumber || updated.Status != entry.Status {
+				t.Error("Apply() changed a field that cannot be patched")
+			}
+		})
+	}
+}
+
+func fieldValue(entry queueing.Entry, field string) string {
+	switch field {
+	case "firstName":
+		return entry.FirstName
+	case "lastName":
+		return entry.LastName
+	case "email":
+		return entry.Email
+	case "notes":
+		return entry.Notes
+	case "partySize":
+		return strconv.Itoa(entry.PartySize)
+	}
+	return ""
+}
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name       string
+		change     func(*queueing.Entry)
+		wantFields []string
+	}{
+		{name: "Valid", change: func(e *queueing.Entry) {}},
+		{name: "Missing first name", change: func(e *queueing.Entry) { e.FirstName = "" }, wantFields: []string{"firstName"}},
+		{name: "Long last name", change: func(e *queueing.Entry) { e.LastName = strings.Repeat("x", 31) }, wantFields: []string{"lastName"}},
+		{name: "Bad email", change: func(e *queueing.Entry) { e.Email = "not an address" }, wantFields: []string{"email"}},
+		{name: "Long email", change: func(e *queueing.Entry) { e.Email = strings.Repeat("a", 40) + "@example.com" }, wantFields: []string{"email"}},
+		{
+			name:       "Email cleared under email notifications",
+			change:     func(e *queueing.Entry) { e.Email = "" },
+			wantFields: []string{"notificationChannel"},
+		},
+		{name: "Long notes", change: func(e *queueing.Entry) { e.Notes = strings.Repeat("x", 201) }, wantFields: []string{"notes"}},
+		{name: "Bad phone number", change: func(e *queueing.Entry) { e.PhoneNumber = "555-CALL-NOW" }, wantFields: []string{"phoneNumber"}},
+		{name: "Unknown channel", change: func(e *queueing.Entry) { e.NotificationChannel = "fax" }, wantFields: []string{"notificationChannel"}},
+		{
+			name:       "Several problems",
+			change:     func(e *queueing.Entry) { e.FirstName, e.LastName = "", "" },
+			wantFields: []string{"firstName", "lastName"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := waitingEntry()
+			tt.change(&entry)
+			fields := queueing.ValidateEntry(&entry, "US")
+
+			if len(fields) != len(tt.wantFields) {
+				t.Errorf("ValidateEntry() = %v, want errors for %v", fields, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if fields[field] == "" {
+					t.Errorf("ValidateEntry() has no error for %s", field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateEntryNormalizes(t *testing.T) {
+	entry := waitingEntry()
+	entry.PhoneNumber = "555-010-0100"
+	entry.NotificationChannel = ""
+
+	if fields := queueing.ValidateEntry(&entry, "US"); len(fields) > 0 {
+		t.Fatalf("ValidateEntry() = %v, want no errors", fields)
+	}
+	if entry.PhoneNumber != "+15550100100" {
+		t.Errorf("PhoneNumber = %q, want E.164", entry.PhoneNumber)
+	}
+	if entry.NotificationChannel != notify.ChannelSMS {
+		t.Errorf("NotificationChannel = %q, want the sms default", entry.NotificationChannel)
+	}
+}
+
+func TestValidatePartySize(t *testing.T) {
+	for _, tt := range []struct {
+		size    int
+		wantErr bool
+	}{
+		{size: 0, wantErr: true},
+		{size: 1},
+		{size: 6},
+		{size: 7, wantErr: true},
+	} {
+		if msg := queueing.ValidatePartySize(tt.size, 6); (msg != "") != tt.wantErr {
+			t.Errorf("ValidatePartySize(%d, 6) = %q, wantErr %v", tt.size, msg, tt.wantErr)
+		}
+	}
+}
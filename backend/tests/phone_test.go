@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"wait-to-go/phone"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		region  string
+		want    string
+		wantErr error
+	}{
+		{name: "Formatted US number", raw: "+1 (555) 010-0100", region: "US", want: "+15550100100"},
+		{name: "National US number", raw: "555-010-0100", region: "US", want: "+15550100100"},
+		{name: "US number with trunk prefix", raw: "1 555 010 0100", region: "US", want: "+15550100100"},
+		{name: "Digits only", raw: "5550100100", region: "us", want: "+15550100100"},
+		{name: "International 00 prefix", raw: "0044 20 7946 0958", region: "US", want: "+442079460958"},
+		{name: "UK national with trunk zero", raw: "020 7946 0958", region: "GB", want: "+442079460958"},
+		{name: "UK mobile with trunk zero", raw: "07700 900123", region: "GB", want: "+447700900123"},
+		{name: "UK nine digit number", raw: "01632 96012", region: "GB", want: "+44163296012"},
+		{name: "UK number without trunk zero", raw: "7700 900123", region: "GB", want: "+447700900123"},
+		{name: "German number with trunk zero", raw: "030 1234567", region: "DE", want: "+49301234567"},
+		{name: "German mobile with trunk zero", raw: "0151 23456789", region: "DE", want: "+4915123456789"},
+		{name: "German number too short", raw: "030 12", region: "DE", wantErr: phone.ErrInvalidLength},
+		{name: "Seven digit local number", raw: "555-0100", region: "US", wantErr: phone.ErrInvalidLength},
+		{name: "Empty", raw: "  ", region: "US", wantErr: phone.ErrEmpty},
+		{name: "Letters", raw: "555-CALL-NOW", region: "US", wantErr: phone.ErrInvalidChars},
+		{name: "Too long international", raw: "+1234567890123456", region: "US", wantErr: phone.ErrInvalidLength},
+		{name: "Unknown region", raw: "5550100100", region: "ZZ", wantErr: phone.ErrUnknownRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := phone.Normalize(tt.raw, tt.region)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("Normalize(%q) error = %v, want %v", tt.raw, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Normalize(%q) unexpected error: %v", tt.raw, err)
+			}
+			if got != tt.want {
+				t.Errorf("Normalize(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
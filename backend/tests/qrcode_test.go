@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"image/png"
+	"strings"
+	"testing"
+
+	"wait-to-go/qrcode"
+)
+
+func TestQRCodeVersion(t *testing.T) {
+	tests := []struct {
+		name        string
+		length      int
+		level       qrcode.Level
+		wantVersion int
+	}{
+		{name: "Fits version 1", length: 14, level: qrcode.Medium, wantVersion: 1},
+		{name: "One byte too many for version 1", length: 15, level: qrcode.Medium, wantVersion: 2},
+		{name: "Higher level needs more room", length: 14, level: qrcode.High, wantVersion: 2},
+		{name: "Join link", length: 80, level: qrcode.Medium, wantVersion: 5},
+		{name: "Largest", length: 2331, level: qrcode.Medium, wantVersion: 40},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := qrcode.Encode(strings.Repeat("a", tt.length), tt.level)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+			if code.Version != tt.wantVersion {
+				t.Errorf("Encode() version = %d, want %d", code.Version, tt.wantVersion)
+			}
+			if want := 17 + 4*tt.wantVersion; code.Size != want {
+				t.Errorf("Encode() size = %d, want %d", code.Size, want)
+			}
+		})
+	}
+
+	if _, err := qrcode.Encode(strings.Repeat("a", 2332), qrcode.Medium); !errors.Is(err, qrcode.ErrTooLong) {
+		t.Errorf("Encode() of too much text error = %v, want %v", err, qrcode.ErrTooLong)
+	}
+}
+
+func TestQRCodePatterns(t *testing.T) {
+	for _, level := range []string{"L", "M", "Q", "H"} {
+		t.Run(level, func(t *testing.T) {
+			l, _ := qrcode.ParseLevel(level)
+			code, err := qrcode.Encode("https://example.com/?queue=default", l)
+			if err != nil {
+				t.Fatalf("Encode() error = %v", err)
+			}
+
+			// Finder patterns: dark rings around a dark 3×3 centre
+			for _, corner := range [][2]int{{0, 0}, {code.Size - 7, 0}, {0, code.Size - 7}} {
+				for dy := range 7 {
+					for dx := range 7 {
+						ring := max(abs(dx-3), abs(dy-3))
+						if got := code.Dark(corner[0]+dx, corner[1]+dy); got != (ring != 2) {
+							t.Fatalf("module (%d, %d) dark = %v in finder at %v", corner[0]+dx, corner[1]+dy, got, corner)
+						}
+					}
+				}
+			}
+
+			// Format information next to the top left finder, most significant bit first
+			var format int
+			for _, m := range [][2]int{{0, 8}, {1, 8}, {2, 8}, {3, 8}, {4, 8}, {5, 8}, {7, 8}, {8, 8}, {8, 7}, {8, 5}, {8, 4}, {8, 3}, {8, 2}, {8, 1}, {8, 0}} {
+				format <<= 1
+				if code.Dark(m[0], m[1]) {
+					format |= 1
+				}
+			}
+			format ^= 0x5412
+			wantLevel := map[string]int{"L": 1, "M": 0, "Q": 3, "H": 2}[level]
+			if got := format >> 13; got != wantLevel {
+				t.Errorf("format level bits = %d, want %d", got, wantLevel)
+			}
+			rem := format >> 10
+			for range 10 {
+				rem = rem<<1 ^ (rem>>9)*0x537
+			}
+			if rem&0x3FF != format&0x3FF {
+				t.Errorf("format information %015b has a bad checksum", format)
+			}
+		})
+	}
+}
+
+func TestQRCodeRender(t *testing.T) {
+	code, err := qrcode.Encode("hello", qrcode.Medium)
+	if err != nil {
+		t.Fatalf("Encode() error = %v", err)
+	}
+
+	data, err := code.PNG(4)
+	if err != nil {
+		t.Fatalf("PNG() error = %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode() error = %v", err)
+	}
+	if want := (code.Size + 2*qrcode.QuietZone) * 4; img.Bounds().Dx() != want || img.Bounds().Dy() != want {
+		t.Errorf("PNG() size = %v, want %dx%d", img.Bounds().Size(), want, want)
+	}
+	if _, _, _, a := img.At(0, 0).RGBA(); a == 0 {
+		t.Error("PNG() quiet zone is transparent, want white")
+	}
+
+	if _, err := code.PNG(0); err == nil {
+		t.Error("PNG(0) error = nil, want an error")
+	}
+
+	svg := string(code.SVG())
+	if !strings.HasPrefix(svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 29 29"`) {
+		t.Errorf("SVG() = %.80q, want a 29×29 viewBox", svg)
+	}
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"wait-to-go/queueing"
+)
+
+func testSchedule() queueing.Schedule {
+	weekday := []queueing.OpeningHours{{Open: "09:00", Close: "17:00"}}
+	return queueing.Schedule{
+		Timezone: "America/New_York",
+		Weekly: map[string][]queueing.OpeningHours{
+			"monday":    weekday,
+			"tuesday":   weekday,
+			"wednesday": weekday,
+			"thursday":  weekday,
+			"friday":    weekday,
+			"saturday":  {{Open: "10:00", Close: "12:00"}, {Open: "13:00", Close: "15:00"}},
+			"sunday":    {{Open: "09:00", Close: "17:00"}, {Open: "20:00", Close: "24:00"}},
+		},
+	}
+}
+
+func newYork(t *testing.T, year int, month time.Month, day, hour, minute int) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation() error = %v", err)
+	}
+	return time.Date(year, month, day, hour, minute, 0, 0, loc)
+}
+
+func TestScheduleOpenAt(t *testing.T) {
+	schedule := testSchedule()
+	// Tuesday 4 March 2025
+	holidays := []queueing.Holiday{{Date: "2025-03-04", Reason: "Staff training"}}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{name: "Monday morning", at: newYork(t, 2025, time.March, 3, 10, 0), want: true},
+		{name: "Before opening", at: newYork(t, 2025, time.March, 3, 8, 59), want: false},
+		{name: "Last minute", at: newYork(t, 2025, time.March, 3, 16, 59), want: true},
+		{name: "Closing time", at: newYork(t, 2025, time.March, 3, 17, 0), want: false},
+		{name: "Given in UTC", at: time.Date(2025, time.March, 3, 14, 30, 0, 0, time.UTC), want: true},
+		{name: "Holiday", at: newYork(t, 2025, time.March, 4, 10, 0), want: false},
+		{name: "Day after the holiday", at: newYork(t, 2025, time.March, 5, 10, 0), want: true},
+		{name: "Saturday lunch break", at: newYork(t, 2025, time.March, 8, 12, 30), want: false},
+		{name: "Saturday afternoon", at: newYork(t, 2025, time.March, 8, 13, 0), want: true},
+		{name: "Sunday evening until midnight", at: newYork(t, 2025, time.March, 9, 23, 59), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := schedule.OpenAt(tt.at, holidays); got != tt.want {
+				t.Errorf("OpenAt(%v) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleNextChange(t *testing.T) {
+	schedule := testSchedule()
+	holidays := []queueing.Holiday{{Date: "2025-03-04", Reason: "Staff training"}}
+
+	tests := []struct {
+		name     string
+		schedule queueing.Schedule
+		holidays []queueing.Holiday
+		from     time.Time
+		want     time.Time
+	}{
+		{
+			name:     "Opens later today",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.March, 3, 8, 0),
+			want:     newYork(t, 2025, time.March, 3, 9, 0),
+		},
+		{
+			name:     "Closes later today",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.March, 3, 10, 0),
+			want:     newYork(t, 2025, time.March, 3, 17, 0),
+		},
+		{
+			name:     "Exactly at opening looks for the close",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.March, 3, 9, 0),
+			want:     newYork(t, 2025, time.March, 3, 17, 0),
+		},
+		{
+			name:     "Skips a holiday",
+			schedule: schedule,
+			holidays: holidays,
+			from:     newYork(t, 2025, time.March, 3, 18, 0),
+			want:     newYork(t, 2025, time.March, 5, 9, 0),
+		},
+		{
+			name:     "Holiday closes at midnight",
+			schedule: queueing.Schedule{Timezone: "America/New_York", Weekly: map[string][]queueing.OpeningHours{"monday": {{Open: "00:00", Close: "24:00"}}, "tuesday": {{Open: "00:00", Close: "24:00"}}}},
+			holidays: holidays,
+			from:     newYork(t, 2025, time.March, 3, 12, 0),
+			want:     newYork(t, 2025, time.March, 4, 0, 0),
+		},
+		{
+			name:     "Lunch break",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.March, 8, 11, 0),
+			want:     newYork(t, 2025, time.March, 8, 12, 0),
+		},
+		{
+			name:     "Opening on the day clocks go forward",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.March, 9, 8, 0),
+			want:     time.Date(2025, time.March, 9, 13, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Midnight close on the day clocks go forward",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.March, 9, 21, 0),
+			want:     time.Date(2025, time.March, 10, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Opening on the day clocks go back",
+			schedule: schedule,
+			from:     newYork(t, 2025, time.November, 2, 8, 0),
+			want:     time.Date(2025, time.November, 2, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "Never changes",
+			schedule: queueing.Schedule{Timezone: "America/New_York"},
+			from:     newYork(t, 2025, time.March, 3, 8, 0),
+			want:     time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schedule.NextChange(tt.from, tt.holidays)
+			if !got.Equal(tt.want) {
+				t.Errorf("NextChange(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduleValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		schedule   queueing.Schedule
+		wantFields []string
+	}{
+		{
+			name:     "Valid",
+			schedule: testSchedule(),
+		},
+		{
+			name:       "Unknown time zone",
+			schedule:   queueing.Schedule{Timezone: "Mars/Olympus_Mons"},
+			wantFields: []string{"timezone"},
+		},
+		{
+			name:       "Missing time zone",
+			schedule:   queueing.Schedule{},
+			wantFields: []string{"timezone"},
+		},
+		{
+			name:       "Unknown day",
+			schedule:   queueing.Schedule{Timezone: "UTC", Weekly: map[string][]queueing.OpeningHours{"funday": {{Open: "09:00", Close: "17:00"}}}},
+			wantFields: []string{"weekly"},
+		},
+		{
+			name:       "Closes before it opens",
+			schedule:   queueing.Schedule{Timezone: "UTC", Weekly: map[string][]queueing.OpeningHours{"monday": {{Open: "17:00", Close: "09:00"}}}},
+			wantFields: []string{"weekly.monday"},
+		},
+		{
+			name:       "Not a time",
+			schedule:   queueing.Schedule{Timezone: "UTC", Weekly: map[string][]queueing.OpeningHours{"friday": {{Open: "9am", Close: "17:00"}}}},
+			wantFields: []string{"weekly.friday"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.schedule.Validate()
+			if len(fields) != len(tt.wantFields) {
+				t.Fatalf("Validate() = %v, want errors for %v", fields, tt.wantFields)
+			}
+			for _, field := range tt.wantFields {
+				if _, ok := fields[field]; !ok {
+					t.Errorf("Validate() = %v, want an error for %s", fields, field)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"wait-to-go/queueing"
+)
+
+var allStatuses = []string{
+	queueing.StatusPending,
+	queueing.StatusBooked,
+	queueing.StatusWaitlisted,
+	queueing.StatusWaiting,
+	queueing.StatusNotified,
+	queueing.StatusServed,
+	queueing.StatusCancelled,
+	queueing.StatusNoShow,
+}
+
+func TestStatusTransitions(t *testing.T) {
+	allowed := map[string][]string{
+		queueing.StatusPending:    {queueing.StatusWaiting, queueing.StatusWaitlisted, queueing.StatusBooked, queueing.StatusCancelled},
+		queueing.StatusBooked:     {queueing.StatusWaiting, queueing.StatusNoShow, queueing.StatusCancelled},
+		queueing.StatusWaitlisted: {queueing.StatusWaiting, queueing.StatusCancelled},
+		queueing.StatusWaiting:    {queueing.StatusNotified, queueing.StatusCancelled},
+		queueing.StatusNotified:   {queueing.StatusServed, queueing.StatusNoShow, queueing.StatusCancelled},
+		// Final statuses
+		queueing.StatusServed:    nil,
+		queueing.StatusCancelled: nil,
+		queueing.StatusNoShow:    nil,
+	}
+
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			want := false
+			for _, s := range allowed[from] {
+				want = want || s == to
+			}
+			if got := queueing.CanTransition(from, to); got != want {
+				t.Errorf("CanTransition(%s, %s) = %v, want %v", from, to, got, want)
+			}
+		}
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		wantErr bool
+	}{
+		{name: "Call a waiting entry", from: queueing.StatusWaiting, to: queueing.StatusNotified},
+		{name: "Promote from the waitlist", from: queueing.StatusWaitlisted, to: queueing.StatusWaiting},
+		{name: "Admit an appointment", from: queueing.StatusBooked, to: queueing.StatusWaiting},
+		{name: "Serve without calling", from: queueing.StatusWaiting, to: queueing.StatusServed, wantErr: true},
+		{name: "Rejoin after being served", from: queueing.StatusServed, to: queueing.StatusWaiting, wantErr: true},
+		{name: "Cancel twice", from: queueing.StatusCancelled, to: queueing.StatusCancelled, wantErr: true},
+		{name: "Unknown status", from: "lost", to: queueing.StatusWaiting, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := queueing.CheckTransition(queueing.Entry{ID: 7, Status: tt.from}, tt.to)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CheckTransition() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr {
+				return
+			}
+
+			if !errors.Is(err, queueing.ErrInvalidTransition) {
+				t.Errorf("CheckTransition() error = %v, want it to match ErrInvalidTransition", err)
+			}
+			var transition *queueing.TransitionError
+			if !errors.As(err, &transition) {
+				t.Fatalf("CheckTransition() error = %T, want *TransitionError", err)
+			}
+			if transition.ID != 7 || transition.From != tt.from || transition.To != tt.to {
+				t.Errorf("TransitionError = %+v, want {7 %s %s}", *transition, tt.from, tt.to)
+			}
+		})
+	}
+}
+
+func TestRecoverableStatuses(t *testing.T) {
+	want := map[string]bool{
+		queueing.StatusBooked:     true,
+		queueing.StatusWaitlisted: true,
+		queueing.StatusWaiting:    true,
+		queueing.StatusNotified:   true,
+	}
+
+	for _, status := range allStatuses {
+		if got := slices.Contains(queueing.RecoverableStatuses, status); got != want[status] {
+			t.Errorf("%s recoverable = %v, want %v", status, got, want[status])
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package tests
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"wait-to-go/auth"
+	"wait-to-go/webhook"
+)
+
+// webhookStore serves a fixed list of subscriptions and keeps the delivery
+// log in memory
+type webhookStore struct {
+	hooks      []webhook.Webhook
+	mu         sync.Mutex
+	deliveries []webhook.Delivery
+}
+
+func (s *webhookStore) ActiveWebhooks() ([]webhook.Webhook, error) {
+	return s.hooks, nil
+}
+
+func (s *webhookStore) SaveDelivery(delivery webhook.Delivery) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deliveries = append(s.deliveries, delivery)
+	return nil
+}
+
+// receiver is a webhook endpoint answering with statuses in turn, repeating
+// the last, and recording each request
+type receiver struct {
+	*httptest.Server
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+	times    []time.Time
+}
+
+func newReceiver(t *testing.T, statuses ...int) *receiver {
+	rec := &receiver{statuses: statuses}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests = append(rec.requests, r)
+		rec.bodies = append(rec.bodies, body)
+		rec.times = append(rec.times, time.Now())
+		w.WriteHeader(rec.statuses[min(len(rec.requests), len(rec.statuses))-1])
+	}))
+	t.Cleanup(rec.Close)
+	return rec
+}
+
+func newDispatcher(store webhook.Store) *webhook.Dispatcher {
+	d := webhook.NewDispatcher(store)
+	d.MaxAttempts = 3
+	d.Backoff = 20 * time.Millisecond
+	return d
+}
+
+func TestWebhookSignature(t *testing.T) {
+	rec := newReceiver(t, http.StatusOK)
+	hook := webhook.Webhook{ID: 1, URL: rec.URL, Secret: "hook-secret"}
+	payload := []byte(`{"type":"entry.joined"}`)
+
+	delivery := newDispatcher(&webhookStore{}).Attempt(hook, "entry.joined", payload, 1)
+	if !delivery.Success {
+		t.Fatalf("Attempt() = %+v, want success", delivery)
+	}
+
+	req := rec.requests[0]
+	if got := req.Header.Get(webhook.EventHeader); got != "entry.joined" {
+		t.Errorf("%s = %q, want entry.joined", webhook.EventHeader, got)
+	}
+	timestamp := req.Header.Get(webhook.TimestampHeader)
+	signature := req.Header.Get(webhook.SignatureHeader)
+	if !auth.VerifyTimestamped([]byte(hook.Secret), rec.bodies[0], timestamp, signature, time.Now()) {
+		t.Errorf("signature %q with timestamp %q does not verify", signature, timestamp)
+	}
+	if auth.VerifyTimestamped([]byte(hook.Secret), rec.bodies[0], timestamp, signature, time.Now().Add(time.Hour)) {
+		t.Error("signature still verifies an hour later, so a delivery could be replayed")
+	}
+	if auth.VerifySignature([]byte(hook.Secret), rec.bodies[0], signature) {
+		t.Error("signature covers only the body, without the timestamp")
+	}
+}
+
+func TestWebhookEventFilter(t *testing.T) {
+	all := newReceiver(t, http.StatusOK)
+	joins := newReceiver(t, http.StatusOK)
+	served := newReceiver(t, http.StatusOK)
+	store := &webhookStore{hooks: []webhook.Webhook{
+		{ID: 1, URL: all.URL},
+		{ID: 2, URL: joins.URL, Events: []string{"entry.joined"}},
+		{ID: 3, URL: served.URL, Events: []string{"entry.served", "entry.no_show"}},
+	}}
+
+	newDispatcher(store).Send("entry.joined", []byte(`{}`))
+
+	for _, tt := range []struct {
+		name string
+		rec  *receiver
+		want int
+	}{
+		{name: "No filters", rec: all, want: 1},
+		{name: "Matching filter", rec: joins, want: 1},
+		{name: "Other events", rec: served, want: 0},
+	} {
+		if got := len(tt.rec.requests); got != tt.want {
+			t.Errorf("%s: got %d requests, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	tests := []struct {
+		name        string
+		statuses    []int
+		wantOK      bool
+		wantLog     []int
+		wantSuccess []bool
+	}{
+		{
+			name:        "First attempt succeeds",
+			statuses:    []int{http.StatusNoContent},
+			wantOK:      true,
+			wantLog:     []int{http.StatusNoContent},
+			wantSuccess: []bool{true},
+		},
+		{
+			name:        "Succeeds on a retry",
+			statuses:    []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantOK:      true,
+			wantLog:     []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK},
+			wantSuccess: []bool{false, false, true},
+		},
+		{
+			name:        "Gives up after MaxAttempts",
+			statuses:    []int{http.StatusServiceUnavailable},
+			wantOK:      false,
+			wantLog:     []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable},
+			wantSuccess: []bool{false, false, false},
+		},
+		{
+			name:        "Redirects count as failures",
+			statuses:    []int{http.StatusNotModified, http.StatusOK},
+			wantOK:      true,
+			wantLog:     []int{http.StatusNotModified, http.StatusOK},
+			wantSuccess: []bool{false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := newReceiver(t, tt.statuses...)
+			store := &webhookStore{}
+			d := newDispatcher(store)
+
+			if ok := d.Deliver(webhook.Webhook{ID: 7, URL: rec.URL}, "entry.served", []byte(`{}`)); ok != tt.wantOK {
+				t.Errorf("Deliver() = %v, want %v", ok, tt.wantOK)
+			}
+
+			var statuses, attempts []int
+			var success []bool
+			for _, delivery := range store.deliveries {
+				statuses = append(statuses, delivery.StatusCode)
+				attempts = append(attempts, delivery.Attempt)
+				success = append(success, delivery.Success)
+				if delivery.WebhookID != 7 || delivery.Event != "entry.served" || delivery.Payload != `{}` {
+					t.Errorf("delivery log entry %+v is for the wrong webhook or event", delivery)
+				}
+				if !delivery.Success && delivery.Error == "" {
+					t.Errorf("failed delivery %d logged no error", delivery.Attempt)
+				}
+			}
+			if !slices.Equal(statuses, tt.wantLog) {
+				t.Errorf("logged statuses %v, want %v", statuses, tt.wantLog)
+			}
+			if !slices.Equal(success, tt.wantSuccess) {
+				t.Errorf("logged success %v, want %v", success, tt.wantSuccess)
+			}
+			for i, attempt := range attempts {
+				if attempt != i+1 {
+					t.Errorf("logged attempts %v, want them numbered from 1", attempts)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestWebhookBackoffDoubles(t *testing.T) {
+	rec := newReceiver(t, http.StatusInternalServerError)
+	d := newDispatcher(&webhookStore{})
+
+	d.Deliver(webhook.Webhook{URL: rec.URL}, "entry.served", []byte(`{}`))
+
+	if len(rec.times) != d.MaxAttempts {
+		t.Fatalf("got %d attempts, want %d", len(rec.times), d.MaxAttempts)
+	}
+	wait := d.Backoff
+	for i := 1; i < len(rec.times); i++ {
+		if gap := rec.times[i].Sub(rec.times[i-1]); gap < wait {
+			t.Errorf("retry %d came after %s, want at least %s", i, gap, wait)
+		}
+		wait *= 2
+	}
+}
+
+func TestWebhookNetworkError(t *testing.T) {
+	rec := newReceiver(t, http.StatusOK)
+	url := rec.URL
+	rec.Close()
+
+	store := &webhookStore{}
+	delivery := newDispatcher(store).Attempt(webhook.Webhook{URL: url}, "entry.served", []byte(`{}`), 1)
+	if delivery.Success || delivery.StatusCode != 0 || delivery.Error == "" {
+		t.Errorf("Attempt() to a closed server = %+v, want a failure with an error and no status", delivery)
+	}
+	if len(store.deliveries) != 1 {
+		t.Errorf("logged %d deliveries, want 1", len(store.deliveries))
+	}
+}
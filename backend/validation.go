@@ -0,0 +1,6 @@
+package main
+
+import "wait-to-go/queueing"
+
+// Field validation lives in queueing
+type FieldErrors = queueing.FieldErrors
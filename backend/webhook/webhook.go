@@ -0,0 +1,162 @@
+// Package webhook delivers queue events to subscribed URLs. Each delivery is
+// signed with the subscription's secret, retried with exponential backoff and
+// recorded in a delivery log.
+package webhook
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"wait-to-go/auth"
+)
+
+// Headers sent with every delivery. The signature covers
+// "<timestamp>.<body>", so receivers can refuse old deliveries being replayed.
+const (
+	EventHeader     = "X-Webhook-Event"
+	SignatureHeader = "X-Webhook-Signature"
+	TimestampHeader = "X-Webhook-Timestamp"
+)
+
+type Webhook struct {
+	ID        int       `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Wants reports whether the subscription filters allow an event. No filters means every event.
+func (h Webhook) Wants(eventType string) bool {
+	return len(h.Events) == 0 || slices.Contains(h.Events, eventType)
+}
+
+// Delivery is one attempt to deliver an event, as kept in the delivery log
+type Delivery struct {
+	ID         int       `json:"id"`
+	WebhookID  int       `json:"webhookId"`
+	Event      string    `json:"event"`
+	Payload    string    `json:"payload"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"statusCode"`
+	Success    bool      `json:"success"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
+
+// Store loads the subscriptions and keeps the delivery log
+type Store interface {
+	ActiveWebhooks() ([]Webhook, error)
+	SaveDelivery(delivery Delivery) error
+}
+
+type Dispatcher struct {
+	Store       Store
+	Client      *http.Client
+	MaxAttempts int
+	// Backoff is the wait before the first retry; it doubles after each one
+	Backoff time.Duration
+}
+
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		Store:       store,
+		Client:      &http.Client{Timeout: 10 * time.Second},
+		MaxAttempts: 5,
+		Backoff:     2 * time.Second,
+	}
+}
+
+// Dispatch sends an event in the background
+func (d *Dispatcher) Dispatch(eventType string, payload []byte) {
+	go d.Send(eventType, payload)
+}
+
+// Send delivers an event to every active subscription that wants it, and
+// returns once each delivery has succeeded or given up
+func (d *Dispatcher) Send(eventType string, payload []byte) {
+	hooks, err := d.Store.ActiveWebhooks()
+	if err != nil {
+		log.Printf("Warning: failed to load webhooks: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, hook := range hooks {
+		if hook.Wants(eventType) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				d.Deliver(hook, eventType, payload)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+// Deliver posts the payload, retrying failures with exponential backoff, and
+// reports whether it got through
+func (d *Dispatcher) Deliver(hook Webhook, eventType string, payload []byte) bool {
+	wait := d.Backoff
+	for attempt := 1; attempt <= d.MaxAttempts; attempt++ {
+		if d.Attempt(hook, eventType, payload, attempt).Success {
+			return true
+		}
+		if attempt < d.MaxAttempts {
+			time.Sleep(wait)
+			wait *= 2
+		}
+	}
+	log.Printf("Warning: giving up on %s delivery to webhook %d after %d attempts", eventType, hook.ID, d.MaxAttempts)
+	return false
+}
+
+// Attempt makes a single signed delivery and records it in the delivery log
+func (d *Dispatcher) Attempt(hook Webhook, eventType string, payload []byte, attempt int) Delivery {
+	now := time.Now()
+	delivery := Delivery{
+		WebhookID: hook.ID,
+		Event:     eventType,
+		Payload:   string(payload),
+		Attempt:   attempt,
+		CreatedAt: now,
+	}
+
+	req, err := http.NewRequest("POST", hook.URL, bytes.NewReader(payload))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(EventHeader, eventType)
+		req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(auth.SignTimestamped([]byte(hook.Secret), now.Unix(), payload)))
+
+		var resp *http.Response
+		resp, err = d.Client.Do(req)
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+			resp.Body.Close()
+			delivery.StatusCode = resp.StatusCode
+			if resp.StatusCode >= 300 {
+				err = fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+			}
+		}
+	}
+
+	delivery.Success = err == nil
+	if err != nil {
+		delivery.Error = err.Error()
+	}
+
+	if err := d.Store.SaveDelivery(delivery); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	return delivery
+}
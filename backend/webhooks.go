@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"wait-to-go/apierror"
+	"wait-to-go/webhook"
+)
+
+// EventWebhookTest is only sent by the test-fire endpoint
+const EventWebhookTest = "webhook.test"
+
+// webhookEvents lists the events a subscription may filter on
+var webhookEvents = []string{
+	EventJoined,
+	EventBooked,
+	EventCheckedIn,
+	EventArrived,
+	EventWaitlisted,
+	EventPromoted,
+	EventUpdated,
+	EventNotified,
+	EventServed,
+	EventNoShow,
+	EventCancelled,
+	EventDelayed,
+	EventMoved,
+	EventConfirmed,
+	EventCleared,
+	EventOpened,
+	EventPaused,
+	EventClosed,
+}
+
+// Subscriptions and their deliveries live in webhook
+type (
+	Webhook         = webhook.Webhook
+	WebhookDelivery = webhook.Delivery
+)
+
+// webhookStore keeps subscriptions and the delivery log in Postgres
+type webhookStore struct {
+	db *sql.DB
+}
+
+func (s webhookStore) ActiveWebhooks() ([]Webhook, error) {
+	return getWebhooks(s.db, true)
+}
+
+func (s webhookStore) SaveDelivery(delivery WebhookDelivery) error {
+	return insertWebhookDelivery(s.db, delivery)
+}
+
+// publishWebhook is subscribed to queue events and delivers them in the background
+func (a *App) publishWebhook(event QueueEvent) {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Warning: failed to encode %s event: %v", event.Type, err)
+		return
+	}
+	a.webhooks.Dispatch(event.Type, payload)
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (a *App) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := getWebhooks(a.db, false)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get webhooks", http.StatusInternalServerError)
+		return
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hooks)
+}
+
+// handleCreateWebhook subscribes a URL: {"url", "events", "secret"}
+func (a *App) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var hook Webhook
+	if err := json.NewDecoder(r.Body).Decode(&hook); err != nil {
+		apierror.Error(w, r, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(hook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		apierror.ValidationError(w, r, map[string]string{"url": "URL must be an absolute http or https URL"})
+		return
+	}
+	for _, event := range hook.Events {
+		if !slices.Contains(webhookEvents, event) {
+			apierror.ValidationError(w, r, map[string]string{"events": "Unknown event: " + event})
+			return
+		}
+	}
+
+	if hook.Secret == "" {
+		if hook.Secret, err = generateWebhookSecret(); err != nil {
+			apierror.Error(w, r, "Failed to generate secret", http.StatusInternalServerError)
+			return
+		}
+	}
+	hook.Active = true
+	hook.CreatedAt = time.Now()
+
+	hook.ID, err = insertWebhook(a.db, hook)
+	if err != nil {
+		apierror.Error(w, r, "Failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	// The secret is only returned once, at creation
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(hook)
+}
+
+// webhookID parses the {id} path value, writing a 400 when it is malformed
+func webhookID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		apierror.Error(w, r, "Invalid ID format", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+func (a *App) handleDeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	if err := deleteWebhook(a.db, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Webhook not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to delete webhook", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
+}
+
+// handleTestWebhook sends a webhook.test event right away and returns the delivery
+func (a *App) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	hook, err := getWebhookByID(a.db, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Error(w, r, "Webhook not found", http.StatusNotFound)
+		} else {
+			apierror.Error(w, r, "Failed to get webhook", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	payload, _ := json.Marshal(QueueEvent{Type: EventWebhookTest, Time: time.Now()})
+	delivery := a.webhooks.Attempt(hook, EventWebhookTest, payload, 1)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(delivery)
+}
+
+func (a *App) handleWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, ok := webhookID(w, r)
+	if !ok {
+		return
+	}
+
+	deliveries, err := getWebhookDeliveries(a.db, id, 50)
+	if err != nil {
+		apierror.Error(w, r, "Failed to get deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
@@ -1,8 +1,45 @@
+// APIError carries the backend's error envelope:
+// {"error": {"code", "message", "details", "requestId"}}
+class APIError extends Error {
+    constructor(message, { status, code, details, requestId } = {}) {
+        super(message);
+        this.name = 'APIError';
+        this.status = status;
+        this.code = code;
+        this.details = details || {};
+        this.requestId = requestId;
+    }
+
+    static async fromResponse(response, fallbackMessage) {
+        let body = {};
+        try {
+            body = (await response.json()).error || {};
+        } catch (e) {
+            // Not a JSON error body (e.g. a proxy error page)
+        }
+
+        return new APIError(body.message || `${fallbackMessage}: ${response.statusText}`, {
+            status: response.status,
+            code: body.code,
+            details: body.details,
+            requestId: body.requestId || response.headers.get('X-Request-ID'),
+        });
+    }
+}
+
 class API {
-    constructor(baseURL = 'http://localhost:8080') {
+    constructor(baseURL = 'http://localhost:8080/api/v1') {
         this.baseURL = baseURL;
         this.token = localStorage.getItem('auth_token');
         this.adminKey = localStorage.getItem('admin_key');
+
+        // Tells this browser apart from other staff using the same admin key,
+        // so it can hold its own counter
+        this.adminSession = localStorage.getItem('admin_session');
+        if (!this.adminSession) {
+            this.adminSession = crypto.randomUUID();
+            localStorage.setItem('admin_session', this.adminSession);
+        }
     }
 
     setToken(token) {
@@ -22,17 +59,46 @@ class API {
         localStorage.removeItem('admin_key');
     }
 
+    // joinHeaders are the headers for joining or booking, with the poster
+    // token when the page was opened from a poster's QR code
+    joinHeaders() {
+        const headers = {
+            'Content-Type': 'application/json',
+        };
+        const posterToken = sessionStorage.getItem('poster_token');
+        if (posterToken) {
+            headers['X-Poster-Token'] = posterToken;
+        }
+        return headers;
+    }
+
     async joinQueue(data) {
-        const response = await fetch(`${this.baseURL}/join`, {
+        const response = await fetch(`${this.baseURL}/queues/default/entries`, {
             method: 'POST',
-            headers: {
-                'Content-Type': 'application/json',
-            },
+            headers: this.joinHeaders(),
+            body: JSON.stringify(data),
+        });
+
+        if (!response.ok) {
+            throw await APIError.fromResponse(response, 'Failed to join queue');
+        }
+
+        const result = await response.json();
+        if (result.token) {
+            this.setToken(result.token);
+        }
+        return result;
+    }
+
+    async bookAppointment(data) {
+        const response = await fetch(`${this.baseURL}/queues/default/appointments`, {
+            method: 'POST',
+            headers: this.joinHeaders(),
             body: JSON.stringify(data),
         });
 
         if (!response.ok) {
-            throw new Error(`Failed to join queue: ${response.statusText}`);
+            throw await APIError.fromResponse(response, 'Failed to book appointment');
         }
 
         const result = await response.json();
@@ -42,12 +108,67 @@ class API {
         return result;
     }
 
+    async checkIn(id) {
+        if (!this.token) {
+            throw new Error('Authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/entries/${id}:checkIn`, {
+            method: 'POST',
+            headers: {
+                'Authorization': `Bearer ${this.token}`,
+            },
+        });
+
+        if (!response.ok) {
+            throw await APIError.fromResponse(response, 'Failed to check in');
+        }
+
+        return response.json();
+    }
+
+    // arrive confirms the customer is on site with the code from the QR code.
+    // The entry ID is read from the customer's token.
+    async arrive(code) {
+        if (!this.token) {
+            throw new Error('Authentication required');
+        }
+        const { id } = JSON.parse(atob(this.token.split('.')[1].replace(/-/g, '+').replace(/_/g, '/')));
+
+        const response = await fetch(`${this.baseURL}/entries/${id}:arrive`, {
+            method: 'POST',
+            headers: {
+                'Content-Type': 'application/json',
+                'Authorization': `Bearer ${this.token}`,
+            },
+            body: JSON.stringify({ code }),
+        });
+
+        if (!response.ok) {
+            throw await APIError.fromResponse(response, 'Failed to confirm arrival');
+        }
+
+        return response.json();
+    }
+
+    async getQueueInfo() {
+        const response = await fetch(`${this.baseURL}/queues/default`, {
+            method: 'GET',
+        });
+
+        if (!response.ok) {
+            throw await APIError.fromResponse(response, 'Failed to get queue');
+        }
+
+        return response.json();
+    }
+
     async checkStatus(id) {
         if (!this.token) {
             throw new Error('Authentication required');
         }
 
-        const response = await fetch(`${this.baseURL}/status/${id}`, {
+        const response = await fetch(`${this.baseURL}/entries/${id}`, {
             method: 'GET',
             headers: {
                 'Authorization': `Bearer ${this.token}`,
@@ -58,7 +179,7 @@ class API {
             if (response.status === 401) {
                 this.clearAuth();
             }
-            throw new Error(`Failed to check status: ${response.statusText}`);
+            throw await APIError.fromResponse(response, 'Failed to check status');
         }
 
         return response.json();
@@ -69,10 +190,50 @@ class API {
             throw new Error('Admin authentication required');
         }
 
-        const response = await fetch(`${this.baseURL}/queue`, {
-            method: 'GET',
+        // The list is paged; follow nextCursor until every entry is loaded
+        const entries = [];
+        let cursor = '';
+        do {
+            const params = new URLSearchParams({ status: 'waiting,notified', limit: '200' });
+            if (cursor) {
+                params.set('cursor', cursor);
+            }
+
+            const response = await fetch(`${this.baseURL}/queues/default/entries?${params}`, {
+                method: 'GET',
+                headers: {
+                    'X-API-Key': this.adminKey,
+                },
+            });
+
+            if (!response.ok) {
+                if (response.status === 401) {
+                    this.clearAuth();
+                }
+                throw await APIError.fromResponse(response, 'Failed to get queue');
+            }
+
+            const page = await response.json();
+            entries.push(...page.entries);
+            cursor = page.nextCursor || '';
+        } while (cursor);
+
+        return entries;
+    }
+
+    // Each click gets its own Idempotency-Key; pass the same key to retry
+    // a click without calling a second customer
+    async notifyNext(idempotencyKey = crypto.randomUUID()) {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/queues/default/entries:next`, {
+            method: 'POST',
             headers: {
                 'X-API-Key': this.adminKey,
+                'X-Admin-Session': this.adminSession,
+                'Idempotency-Key': idempotencyKey,
             },
         });
 
@@ -80,29 +241,32 @@ class API {
             if (response.status === 401) {
                 this.clearAuth();
             }
-            throw new Error(`Failed to get queue: ${response.statusText}`);
+            throw await APIError.fromResponse(response, 'Failed to notify next');
         }
 
         return response.json();
     }
 
-    async notifyNext() {
+    async notifyEntry(id, reason = '') {
         if (!this.adminKey) {
             throw new Error('Admin authentication required');
         }
 
-        const response = await fetch(`${this.baseURL}/next`, {
+        const response = await fetch(`${this.baseURL}/entries/${id}:notify`, {
             method: 'POST',
             headers: {
+                'Content-Type': 'application/json',
                 'X-API-Key': this.adminKey,
+                'X-Admin-Session': this.adminSession,
             },
+            body: JSON.stringify({ reason }),
         });
 
         if (!response.ok) {
             if (response.status === 401) {
                 this.clearAuth();
             }
-            throw new Error(`Failed to notify next: ${response.statusText}`);
+            throw await APIError.fromResponse(response, 'Failed to notify entry');
         }
 
         return response.json();
@@ -113,20 +277,113 @@ class API {
             throw new Error('Admin authentication required');
         }
 
-        const response = await fetch(`${this.baseURL}/serve`, {
+        const response = await fetch(`${this.baseURL}/entries/${entry.id}:serve`, {
+            method: 'POST',
+            headers: {
+                'X-API-Key': this.adminKey,
+                'X-Admin-Session': this.adminSession,
+            },
+        });
+
+        if (!response.ok) {
+            if (response.status === 401) {
+                this.clearAuth();
+            }
+            throw await APIError.fromResponse(response, 'Failed to mark as served');
+        }
+
+        return response.json();
+    }
+
+    async getCounters() {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/counters`, {
+            method: 'GET',
+            headers: {
+                'X-API-Key': this.adminKey,
+                'X-Admin-Session': this.adminSession,
+            },
+        });
+
+        if (!response.ok) {
+            if (response.status === 401) {
+                this.clearAuth();
+            }
+            throw await APIError.fromResponse(response, 'Failed to get counters');
+        }
+
+        return response.json();
+    }
+
+    async claimCounter(id) {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/counters/${id}:claim`, {
+            method: 'POST',
+            headers: {
+                'X-API-Key': this.adminKey,
+                'X-Admin-Session': this.adminSession,
+            },
+        });
+
+        if (!response.ok) {
+            if (response.status === 401) {
+                this.clearAuth();
+            }
+            throw await APIError.fromResponse(response, 'Failed to claim counter');
+        }
+
+        return response.json();
+    }
+
+    async releaseCounter(id) {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/counters/${id}:release`, {
+            method: 'POST',
+            headers: {
+                'X-API-Key': this.adminKey,
+                'X-Admin-Session': this.adminSession,
+            },
+        });
+
+        if (!response.ok) {
+            if (response.status === 401) {
+                this.clearAuth();
+            }
+            throw await APIError.fromResponse(response, 'Failed to release counter');
+        }
+
+        return response.json();
+    }
+
+    // setQueueState runs one of the queue actions: open, pause or close
+    async setQueueState(action, message = '') {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/queues/default:${action}`, {
             method: 'POST',
             headers: {
                 'Content-Type': 'application/json',
                 'X-API-Key': this.adminKey,
             },
-            body: JSON.stringify(entry),
+            body: JSON.stringify({ message }),
         });
 
         if (!response.ok) {
             if (response.status === 401) {
                 this.clearAuth();
             }
-            throw new Error(`Failed to mark as served: ${response.statusText}`);
+            throw await APIError.fromResponse(response, 'Failed to change queue state');
         }
 
         return response.json();
@@ -137,8 +394,8 @@ class API {
             throw new Error('Admin authentication required');
         }
 
-        const response = await fetch(`${this.baseURL}/clear`, {
-            method: 'POST',
+        const response = await fetch(`${this.baseURL}/queues/default/entries`, {
+            method: 'DELETE',
             headers: {
                 'X-API-Key': this.adminKey,
             },
@@ -148,11 +405,60 @@ class API {
             if (response.status === 401) {
                 this.clearAuth();
             }
-            throw new Error(`Failed to clear queue: ${response.statusText}`);
+            throw await APIError.fromResponse(response, 'Failed to clear queue');
         }
 
         return response.json();
     }
+
+    // getPosterQRCode returns the join link as an SVG QR code, signed if the
+    // server requires posters
+    async getPosterQRCode() {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/queues/default/qrcode?format=svg`, {
+            headers: {
+                'X-API-Key': this.adminKey,
+            },
+        });
+
+        if (!response.ok) {
+            if (response.status === 401) {
+                this.clearAuth();
+            }
+            throw await APIError.fromResponse(response, 'Failed to get QR code');
+        }
+
+        return response.blob();
+    }
+
+    // getArrivalQRCode returns the current arrival code as an SVG QR code and
+    // the time it rotates
+    async getArrivalQRCode() {
+        if (!this.adminKey) {
+            throw new Error('Admin authentication required');
+        }
+
+        const response = await fetch(`${this.baseURL}/queues/default/arrivalCode?format=svg`, {
+            headers: {
+                'X-API-Key': this.adminKey,
+            },
+        });
+
+        if (!response.ok) {
+            if (response.status === 401) {
+                this.clearAuth();
+            }
+            throw await APIError.fromResponse(response, 'Failed to get arrival code');
+        }
+
+        return {
+            image: await response.blob(),
+            expiresAt: new Date(response.headers.get('X-Arrival-Expires')),
+        };
+    }
 }
 
 // Create a global API instance
@@ -13,11 +13,17 @@ class UI {
         // Forms
         this.joinForm = document.getElementById('joinForm');
         this.statusForm = document.getElementById('statusForm');
+        this.checkInBtn = document.getElementById('checkInBtn');
 
         // Admin elements
         this.nextInQueue = document.getElementById('nextInQueue');
         this.clearQueue = document.getElementById('clearQueue');
+        this.posterQRCode = document.getElementById('posterQRCode');
+        this.arrivalQRCode = document.getElementById('arrivalQRCode');
         this.queueEntries = document.getElementById('queueEntries');
+        this.counterSelect = document.getElementById('counterSelect');
+        this.queueStateSelect = document.getElementById('queueStateSelect');
+        this.myCounterId = null;
 
         // Toast
         this.toast = document.getElementById('toast');
@@ -35,6 +41,70 @@ class UI {
 
         // Bind event listeners
         this.bindEvents();
+        this.loadQueueInfo();
+        this.confirmArrival();
+        this.rememberPoster();
+    }
+
+    // rememberPoster keeps the token from a poster's QR code, which adds
+    // ?poster=<token>, to send when joining
+    rememberPoster() {
+        const params = new URLSearchParams(window.location.search);
+        const token = params.get('poster');
+        if (!token) {
+            return;
+        }
+        sessionStorage.setItem('poster_token', token);
+        params.delete('poster');
+        history.replaceState(null, '', `${window.location.pathname}${params.size ? `?${params}` : ''}`);
+    }
+
+    // confirmArrival handles the page being opened from the on-site QR code,
+    // which adds ?arrival=<code>
+    async confirmArrival() {
+        const params = new URLSearchParams(window.location.search);
+        const code = params.get('arrival');
+        if (!code) {
+            return;
+        }
+        params.delete('arrival');
+        history.replaceState(null, '', `${window.location.pathname}${params.size ? `?${params}` : ''}`);
+
+        try {
+            const result = await api.arrive(code);
+            this.showToast(result.position > 0
+                ? `Thanks, you're checked in. You're number ${result.position} in the queue`
+                : "Thanks, you're checked in");
+        } catch (error) {
+            if (error.message === 'Authentication required') {
+                this.showToast('Join the queue on this phone first, then scan the code again', true);
+            } else {
+                this.showToast(error.message, true);
+            }
+        }
+    }
+
+    // loadQueueInfo says if the queue is not taking joins and offers its
+    // service types on the join form, if it has any
+    async loadQueueInfo() {
+        try {
+            const queue = await api.getQueueInfo();
+            this.showQueueState(queue);
+            if (queue.serviceTypes.length === 0) {
+                return;
+            }
+
+            const select = document.getElementById('serviceType');
+            queue.serviceTypes.forEach(type => {
+                const option = document.createElement('option');
+                option.value = type;
+                option.textContent = type.replace(/[_-]/g, ' ');
+                select.appendChild(option);
+            });
+            document.getElementById('serviceTypeGroup').classList.remove('hidden');
+        } catch (error) {
+            // Joining still works with the default service type
+        }
     }
 
     bindEvents() {
@@ -46,10 +116,62 @@ class UI {
         // Forms
         this.joinForm.addEventListener('submit', this.handleJoinQueue.bind(this));
         this.statusForm.addEventListener('submit', this.handleStatusCheck.bind(this));
+        this.checkInBtn.addEventListener('click', this.handleCheckIn.bind(this));
 
         // Admin controls
         this.nextInQueue.addEventListener('click', this.handleNext.bind(this));
         this.clearQueue.addEventListener('click', this.handleClearQueue.bind(this));
+        this.posterQRCode.addEventListener('click', this.handlePosterQRCode.bind(this));
+        this.arrivalQRCode.addEventListener('click', this.handleArrivalQRCode.bind(this));
+        this.counterSelect.addEventListener('change', this.handleClaimCounter.bind(this));
+        this.queueStateSelect.addEventListener('change', this.handleQueueState.bind(this));
+    }
+
+    showQueueState(queue) {
+        const notice = document.getElementById('queueNotice');
+        this.queueStateSelect.value = { open: 'open', paused: 'pause', closed: 'close' }[queue.state];
+        if (queue.state === 'open') {
+            notice.classList.add('hidden');
+            return;
+        }
+
+        let text = queue.message || (queue.state === 'paused'
+            ? 'The queue is not taking new customers right now.'
+            : 'The queue is closed.');
+        if (queue.opensAt) {
+            text += ` It opens again at ${new Date(queue.opensAt).toLocaleString()}.`;
+        }
+        notice.textContent = text;
+        notice.classList.remove('hidden');
+    }
+
+    async handleQueueState() {
+        const action = this.queueStateSelect.value;
+        let message = '';
+        if (action !== 'open') {
+            message = prompt('Message for customers who try to join (optional):');
+            if (message === null) {
+                this.refreshQueueState();
+                return;
+            }
+        }
+
+        try {
+            const queue = await api.setQueueState(action, message);
+            this.showQueueState(queue);
+            this.showToast(`Queue is ${queue.state}`);
+        } catch (error) {
+            this.showToast(error.message, true);
+            this.refreshQueueState();
+        }
+    }
+
+    async refreshQueueState() {
+        try {
+            this.showQueueState(await api.getQueueInfo());
+        } catch (error) {
+            this.showToast(error.message, true);
+        }
     }
 
     showSection(section) {
@@ -77,6 +199,7 @@ class UI {
                 this.adminSection.classList.remove('hidden');
                 this.adminBtn.classList.add('active');
                 this.refreshQueueList();
+                this.refreshCounters();
                 break;
         }
     }
@@ -112,19 +235,52 @@ class UI {
             firstName: formData.get('firstName'),
             lastName: formData.get('lastName'),
             email: formData.get('email'),
-            phoneNumber: formData.get('phoneNumber')
+            phoneNumber: formData.get('phoneNumber'),
+            partySize: parseInt(formData.get('partySize'), 10) || 1
         };
+        if (formData.get('serviceType')) {
+            data.serviceType = formData.get('serviceType');
+        }
 
         try {
+            if (formData.get('appointmentAt')) {
+                data.appointmentAt = new Date(formData.get('appointmentAt')).toISOString();
+                const result = await api.bookAppointment(data);
+                if (result.status === 'existing') {
+                    this.showExistingEntry(result);
+                    return;
+                }
+                this.showToast(`Appointment booked for ${new Date(result.appointmentAt).toLocaleString()}. Check in when you arrive. Your ID is: ${result.id}`);
+                event.target.reset();
+                return;
+            }
+
             const result = await api.joinQueue(data);
-            this.showToast(`Successfully joined queue. Your ID is: ${result.id}`);
+            if (result.status === 'existing') {
+                this.showExistingEntry(result);
+            } else if (result.status === 'waitlisted') {
+                this.showToast(`The queue is full, so you're number ${result.waitlistPosition} on the waitlist. We'll tell you when you're in. Your ID is: ${result.id}`);
+            } else {
+                this.showToast(`Successfully joined queue. Your ID is: ${result.id}`);
+            }
             event.target.reset();
             this.refreshQueueList();
         } catch (error) {
-            this.showToast(error.message, true);
+            // Validation errors list the problem with each field
+            const fieldMessages = Object.values(error.details || {});
+            this.showToast(fieldMessages.length ? fieldMessages.join(' ') : error.message, true);
         }
     }
 
+    // showExistingEntry explains how to get back to the entry a phone already
+    // has. The token for it only comes from session recovery.
+    showExistingEntry(result) {
+        const next = result.expiresIn
+            ? "We've texted that phone a code to get back to your place."
+            : 'Use session recovery with your phone number to get back to your place.';
+        this.showToast(`This phone number is already in line with ID ${result.id}. ${next}`, true);
+    }
+
     async handleStatusCheck(event) {
         event.preventDefault();
         const id = document.getElementById('queueId').value;
@@ -137,8 +293,13 @@ class UI {
             statusResult.classList.remove('hidden');
             statusMessage.textContent = `Status: ${result.entry.status}
                 Name: ${result.entry.firstName} ${result.entry.lastName}
-                Position in Queue: ${result.position}
-                Join Time: ${new Date(result.entry.joinTime).toLocaleString()}`;
+                ${result.waitlistPosition ? `Position on Waitlist: ${result.waitlistPosition}` : `Position in Queue: ${result.position}`}
+                Party Size: ${result.entry.partySize}
+                Join Time: ${new Date(result.entry.joinTime).toLocaleString()}${result.entry.arrivedAt ? `
+                Arrived: ${new Date(result.entry.arrivedAt).toLocaleTimeString()}` : ''}${result.entry.appointmentAt ? `
+                Appointment: ${new Date(result.entry.appointmentAt).toLocaleString()}` : ''}${result.entry.counter ? `
+                Go to: ${result.entry.counter}` : ''}`;
+            this.checkInBtn.classList.toggle('hidden', result.entry.status !== 'booked' || Boolean(result.entry.checkedInAt));
         } catch (error) {
             if (error.message === 'Authentication required') {
                 this.showToast('Please join the queue first to get a token', true);
@@ -148,6 +309,22 @@ class UI {
         }
     }
 
+    async handleCheckIn() {
+        const id = document.getElementById('queueId').value;
+
+        try {
+            const result = await api.checkIn(id);
+            this.checkInBtn.classList.add('hidden');
+            if (result.position > 0) {
+                this.showToast(`You're checked in and number ${result.position} in the queue`);
+            } else {
+                this.showToast(`You're checked in. You'll join the queue at ${new Date(result.entry.appointmentAt).toLocaleTimeString()}`);
+            }
+        } catch (error) {
+            this.showToast(error.message, true);
+        }
+    }
+
     async handleAdminLogin(event) {
         event.preventDefault();
         const apiKey = document.getElementById('adminKey').value;
@@ -158,6 +335,7 @@ class UI {
             this.showToast('Admin login successful');
             this.showAdminUI();
             this.refreshQueueList();
+            this.refreshCounters();
             event.target.reset();
         } catch (error) {
             api.clearAuth();
@@ -174,8 +352,9 @@ class UI {
 
     async handleNext() {
         try {
-            await api.notifyNext();
-            this.showToast('Next person notified');
+            const result = await api.notifyNext();
+            const counter = result.entry.counter;
+            this.showToast(counter ? `Next person sent to ${counter}` : 'Next person notified');
             this.refreshQueueList();
         } catch (error) {
             if (error.message === 'Admin authentication required') {
@@ -203,11 +382,17 @@ class UI {
                         <strong>${entry.firstName} ${entry.lastName}</strong>
                         <br>
                         <small>Joined: ${new Date(entry.joinTime).toLocaleString()}</small>
+                        ${entry.arrivedAt ? `<br><small>Arrived: ${new Date(entry.arrivedAt).toLocaleTimeString()}</small>` : ''}
+                        ${entry.counter ? `<br><small>Called to: ${entry.counter}</small>` : ''}
                     </div>
                     <div>
+                        ${entry.status === 'notified' ? `
                         <button onclick="ui.handleMarkServed(${entry.id})" class="secondary-btn">
                             Mark Served
-                        </button>
+                        </button>` : `
+                        <button onclick="ui.handleNotifyEntry(${entry.id})" class="secondary-btn">
+                            Call Now
+                        </button>`}
                     </div>
                 `;
                 this.queueEntries.appendChild(div);
@@ -220,6 +405,66 @@ class UI {
         }
     }
 
+    async refreshCounters() {
+        try {
+            const counters = await api.getCounters();
+            this.counterSelect.innerHTML = '<option value="">No counter</option>';
+            this.myCounterId = null;
+
+            counters.forEach(counter => {
+                const option = document.createElement('option');
+                option.value = counter.id;
+                option.textContent = counter.claimed && !counter.mine ? `${counter.name} (in use)` : counter.name;
+                option.disabled = counter.claimed && !counter.mine;
+                option.selected = counter.mine;
+                if (counter.mine) {
+                    this.myCounterId = counter.id;
+                }
+                this.counterSelect.appendChild(option);
+            });
+        } catch (error) {
+            if (error.message === 'Admin authentication required') {
+                this.hideAdminUI();
+            }
+            this.showToast(error.message, true);
+        }
+    }
+
+    async handleClaimCounter() {
+        const id = this.counterSelect.value;
+
+        try {
+            if (id) {
+                const counter = await api.claimCounter(id);
+                this.showToast(`You are at ${counter.name}`);
+            } else if (this.myCounterId) {
+                await api.releaseCounter(this.myCounterId);
+                this.showToast('Counter released');
+            }
+        } catch (error) {
+            this.showToast(error.message, true);
+        }
+        this.refreshCounters();
+    }
+
+    async handleNotifyEntry(id) {
+        const reason = prompt('Reason for calling out of turn (optional):');
+        if (reason === null) {
+            return;
+        }
+
+        try {
+            await api.notifyEntry(id, reason);
+            this.showToast('Customer notified');
+            this.refreshQueueList();
+        } catch (error) {
+            if (error.message === 'Admin authentication required') {
+                this.hideAdminUI();
+            }
+            this.showToast(error.message, true);
+        }
+    }
+
     async handleMarkServed(id) {
         try {
             await api.markServed({ id });
@@ -249,6 +494,55 @@ class UI {
             this.showToast(error.message, true);
         }
     }
+
+    // handlePosterQRCode opens the join QR code in a new tab to print
+    async handlePosterQRCode() {
+        const tab = window.open('', '_blank');
+        try {
+            const image = await api.getPosterQRCode();
+            tab.location = URL.createObjectURL(image);
+        } catch (error) {
+            tab.close();
+            if (error.message === 'Admin authentication required') {
+                this.hideAdminUI();
+            }
+            this.showToast(error.message, true);
+        }
+    }
+
+    // handleArrivalQRCode opens a tab to show on site with the QR code
+    // customers scan when they arrive, replacing it each time the code rotates
+    async handleArrivalQRCode() {
+        const tab = window.open('', '_blank');
+        tab.document.title = 'Scan when you arrive';
+        tab.document.body.style.margin = '0';
+        const image = tab.document.createElement('img');
+        image.alt = 'Arrival QR code';
+        image.style.cssText = 'width: 100vw; height: 100vh; object-fit: contain;';
+        tab.document.body.appendChild(image);
+
+        const refresh = async () => {
+            if (tab.closed) {
+                return;
+            }
+            try {
+                const code = await api.getArrivalQRCode();
+                if (image.src) {
+                    URL.revokeObjectURL(image.src);
+                }
+                image.src = URL.createObjectURL(code.image);
+                // Fetch the next code just after this one rotates
+                setTimeout(refresh, Math.max(code.expiresAt - Date.now(), 0) + 1000);
+            } catch (error) {
+                tab.close();
+                if (error.message === 'Admin authentication required') {
+                    this.hideAdminUI();
+                }
+                this.showToast(error.message, true);
+            }
+        };
+        refresh();
+    }
 }
 
 // Create a global UI instance